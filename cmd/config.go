@@ -0,0 +1,94 @@
+/**
+ * This work is licensed under Apache License, Version 2.0 or later.
+ * Please read and understand latest version of Licence.
+ */
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/kazimsarikaya/assesmentbarkinrl/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var (
+	showOutput      string
+	validateCommand string
+
+	// commandValidations are the checks the commands run on startup
+	commandValidations = map[string]func(config.Config) error{
+		"server":  config.Config.ValidateServer,
+		"watcher": config.Config.Validate,
+		"all":     config.Config.ValidateServer,
+	}
+
+	configCmd = &cobra.Command{
+		Use:   "config",
+		Short: "Inspect the configuration",
+		Long: `Inspect the configuration built from flags, APP_ prefixed environment variables,
+the config file and the defaults, in this order of precedence.`,
+	}
+
+	configValidateCmd = &cobra.Command{
+		Use:   "validate",
+		Short: "Validate the configuration",
+		Long: `Validate the configuration as the given command would on startup and print all
+problems. The watcher does not need the OIDC options the server requires.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			validate, ok := commandValidations[validateCommand]
+			if !ok {
+				fmt.Fprintf(os.Stderr, "unknown command %q, expected server, watcher or all\n", validateCommand)
+				os.Exit(2)
+			}
+
+			if err := validate(config.GetConfig()); err != nil {
+				fmt.Fprintln(os.Stderr, "invalid config:")
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+
+			fmt.Printf("config is valid for %s\n", validateCommand)
+		},
+	}
+
+	configShowCmd = &cobra.Command{
+		Use:   "show",
+		Short: "Show the effective configuration",
+		Long:  `Show the effective value of every option and where it came from, secrets are redacted.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			settings := config.Settings(cmd.Flags())
+
+			if showOutput == "json" {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+
+				if err := enc.Encode(settings); err != nil {
+					fmt.Fprintln(os.Stderr, err)
+					os.Exit(1)
+				}
+
+				return
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "NAME\tVALUE\tSOURCE\tENV")
+
+			for _, s := range settings {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", s.Name, s.Value, s.Source, s.Env)
+			}
+
+			w.Flush()
+		},
+	}
+)
+
+func init() {
+	configShowCmd.Flags().StringVarP(&showOutput, "output", "o", "text", "Output format: text or json")
+	configValidateCmd.Flags().StringVar(&validateCommand, "command", "server", "Command to validate the configuration for: server, watcher or all")
+
+	configCmd.AddCommand(configValidateCmd)
+	configCmd.AddCommand(configShowCmd)
+}
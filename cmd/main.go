@@ -6,13 +6,20 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"os"
 	"os/signal"
+	"slices"
+	"syscall"
+	"time"
 
 	"github.com/kazimsarikaya/assesmentbarkinrl/internal/config"
 	"github.com/kazimsarikaya/assesmentbarkinrl/internal/logger"
+	"github.com/kazimsarikaya/assesmentbarkinrl/internal/metrics"
+	"github.com/kazimsarikaya/assesmentbarkinrl/internal/scheduler"
+	"github.com/kazimsarikaya/assesmentbarkinrl/internal/tracing"
 	"github.com/kazimsarikaya/assesmentbarkinrl/internal/webserver"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -33,10 +40,11 @@ var (
 		Short: "Start the app server",
 		Long:  `Start the app server with the specified options`,
 		Run: func(cmd *cobra.Command, args []string) {
-			err := cmdServer()
+			err := cmdServer(cmd.Context())
 
 			if err != nil {
 				slog.Error("Error starting server", "error", err)
+				os.Exit(1)
 			}
 		},
 	}
@@ -57,15 +65,39 @@ var (
 		Short: "Start the configmap watcher",
 		Long:  `Start the configmap watcher for template replacement and apply.`,
 		Run: func(cmd *cobra.Command, args []string) {
-			err := webserver.WatchConfigMaps()
+			err := cmdWatcher(cmd.Context())
+
 			if err != nil {
 				slog.Error("Error running watcher", "error", err)
+				os.Exit(1)
+			}
+		},
+	}
+
+	allCmd = &cobra.Command{
+		Use:     "all",
+		Aliases: []string{"run"},
+		Short:   "Start the app server and the configmap watcher",
+		Long: `Start the app server and the configmap watcher in one process. They share the
+Kubernetes client, informer cache, metrics and health endpoints.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			err := cmdAll(cmd.Context())
+
+			if err != nil {
+				slog.Error("Error running app", "error", err)
+				os.Exit(1)
 			}
 		},
 	}
 )
 
 func initConfig() {
+	config.BindEnv()
+
+	if cfgFile == "" {
+		cfgFile = viper.GetString("config")
+	}
+
 	if cfgFile != "" {
 		// Use config file from the flag.
 		viper.SetConfigFile(cfgFile)
@@ -79,10 +111,8 @@ func initConfig() {
 		viper.SetConfigType("yaml")
 	}
 
-	viper.AutomaticEnv()
-
 	if err := viper.ReadInConfig(); err == nil {
-		fmt.Println("Using config file:", viper.ConfigFileUsed())
+		fmt.Fprintln(os.Stderr, "Using config file:", viper.ConfigFileUsed())
 	}
 
 	config.GetConfigBuilder().SyncConfig()
@@ -103,60 +133,241 @@ func init() {
 
 	cb := config.GetConfigBuilder()
 
-	cb.BuildCommandlineFlags(rootCmd, serverCmd)
+	cb.BuildCommandlineFlags(rootCmd, serverCmd, watcherCmd)
+
+	// the flags are shared, so these commands see the same options
+	allCmd.Flags().AddFlagSet(serverCmd.Flags())
+	allCmd.Flags().AddFlagSet(watcherCmd.Flags())
+	configValidateCmd.Flags().AddFlagSet(serverCmd.Flags())
+	configValidateCmd.Flags().AddFlagSet(watcherCmd.Flags())
+	configShowCmd.Flags().AddFlagSet(serverCmd.Flags())
+	configShowCmd.Flags().AddFlagSet(watcherCmd.Flags())
 
 	rootCmd.AddCommand(serverCmd)
 
 	rootCmd.AddCommand(versionCmd)
 
 	rootCmd.AddCommand(watcherCmd)
+
+	rootCmd.AddCommand(allCmd)
+
+	rootCmd.AddCommand(configCmd)
 }
 
-func cmdServer() error {
-	config := config.GetConfig()
+// setupTracing installs the configured trace exporter. The returned function
+// flushes the pending spans.
+func setupTracing() (func(), error) {
+	cfg := config.GetConfig()
+
+	shutdown, err := tracing.Setup(context.Background(), tracing.Options{
+		ServiceName:    "app",
+		ServiceVersion: cfg.GetVersion(),
+		Exporter:       cfg.GetTracingExporter(),
+		Endpoint:       cfg.GetTracingEndpoint(),
+		Insecure:       cfg.GetTracingInsecure(),
+		File:           cfg.GetTracingFile(),
+		SampleRatio:    cfg.GetTracingSampleRatio(),
+	})
+	if err != nil {
+		return nil, err
+	}
 
-	slog.Info("config", "server_port", config.GetServerPort())
-	slog.Info("config", "debug", config.GetDebug())
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if err := shutdown(ctx); err != nil {
+			slog.Error("Error shutting down tracing", "error", err)
+		}
+	}, nil
+}
+
+// startProcess sets up what the server and the watcher share: the log format
+// and levels, tracing, the metrics and probe endpoints and the scheduler of maintenance
+// jobs. The returned function stops them.
+func startProcess(ctx context.Context) (func(), error) {
+	cfg := config.GetConfig()
 
-	if config.GetDebug() {
+	if err := logger.SetFormat(cfg.GetLogFormat()); err != nil {
+		return nil, err
+	}
+
+	slog.Info("config", "debug", cfg.GetDebug(), "log_format", cfg.GetLogFormat())
+
+	setLogLevel(cfg)
+
+	config.Subscribe(func(_, next config.Config, changed []string) {
+		if slices.Contains(changed, "debug") || slices.Contains(changed, "logLevels") {
+			setLogLevel(next)
+		}
+	})
+
+	if err := config.WatchConfigFile(ctx); err != nil {
+		return nil, err
+	}
+
+	shutdownTracing, err := setupTracing()
+	if err != nil {
+		return nil, err
+	}
+
+	metricsSrv, err := metrics.Serve(cfg.GetMetricsPort(), webserver.HealthChecker().Routes())
+	if err != nil {
+		shutdownTracing()
+		return nil, err
+	}
+
+	scheduler.Default().Register(scheduler.Job{
+		Name:     "config_reload",
+		Interval: func() time.Duration { return config.GetConfig().GetConfigReloadInterval() },
+		Jitter:   func() float64 { return config.GetConfig().GetJobJitter() },
+		Run:      func(context.Context) error { return config.Reload() },
+	})
+
+	scheduler.Default().Start(ctx)
+
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.GetWait())
+		defer cancel()
+
+		metrics.Shutdown(ctx, metricsSrv)
+		shutdownTracing()
+	}, nil
+}
+
+// setLogLevel applies the debug option and the module levels to the logger.
+// Levels changed at runtime stay until they expire.
+func setLogLevel(cfg config.Config) {
+	if cfg.GetDebug() {
 		logger.LogLevel.Set(slog.LevelDebug)
+	} else {
+		logger.LogLevel.Set(slog.LevelInfo)
+	}
+
+	modules, err := logger.ParseModuleLevels(cfg.GetLogLevels())
+	if err != nil {
+		slog.Error("Invalid module log levels", "error", err)
+		return
 	}
 
-	srv, err := webserver.StartWebServer()
+	logger.ModuleLevels().SetModules(modules)
+}
+
+// runServer runs the web server until ctx is cancelled, then drains it.
+func runServer(ctx context.Context) error {
+	config := config.GetConfig()
+
+	slog.Info("config", "server_port", config.GetServerPort())
+
+	srv, err := webserver.StartWebServer(ctx)
 
 	if err != nil {
 		return err
 	}
 
-	c := make(chan os.Signal, 1)
+	// Block until SIGINT or SIGTERM cancels the root context.
+	<-ctx.Done()
 
-	// We'll accept graceful shutdowns when quit via SIGINT (Ctrl+C)
-	// SIGKILL, SIGQUIT or SIGTERM (Ctrl+/) will not be caught.
-	signal.Notify(c, os.Interrupt)
-
-	// Block until we receive our signal.
-	<-c
+	slog.Info("Shutting down, draining requests", "wait", config.GetWait())
 
 	// Create a deadline to wait for.
-	ctx, cancel := context.WithTimeout(context.Background(), config.GetWait())
+	drainCtx, cancel := context.WithTimeout(context.Background(), config.GetWait())
 	defer cancel()
 
-	// Doesn't block if no connections, but will otherwise wait
-	// until the timeout deadline.
-	err = srv.Shutdown(ctx)
+	// Doesn't block if no connections, but will otherwise wait until the
+	// deadline and then cancel the requests still running.
+	err = webserver.ShutdownWebServer(drainCtx, srv)
 
 	if err != nil {
 		slog.Error("Server forced to shutdown", "error", err)
 	}
-	// Optionally, you could run srv.Shutdown in a goroutine and block on
-	// <-ctx.Done() if your application should wait for other services
-	// to finalize based on context cancellation.
+
 	slog.Info("Shutting down")
 	return nil
 }
 
+// cmdServer runs the web server until ctx is cancelled.
+func cmdServer(ctx context.Context) error {
+	if err := config.GetConfig().ValidateServer(); err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
+
+	config.SetValidation(config.Config.ValidateServer)
+
+	stop, err := startProcess(ctx)
+	if err != nil {
+		return err
+	}
+	defer stop()
+
+	return runServer(ctx)
+}
+
+// cmdWatcher runs the watcher until ctx is cancelled.
+func cmdWatcher(ctx context.Context) error {
+	if err := config.GetConfig().Validate(); err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
+
+	stop, err := startProcess(ctx)
+	if err != nil {
+		return err
+	}
+	defer stop()
+
+	return webserver.WatchConfigMaps(ctx)
+}
+
+// cmdAll runs the web server and the watcher until ctx is cancelled. When one
+// of them stops, on error too, the other one is stopped as well.
+func cmdAll(ctx context.Context) error {
+	if err := config.GetConfig().ValidateServer(); err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
+
+	config.SetValidation(config.Config.ValidateServer)
+
+	stop, err := startProcess(ctx)
+	if err != nil {
+		return err
+	}
+	defer stop()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	components := []func(ctx context.Context) error{runServer, webserver.WatchConfigMaps}
+	errs := make(chan error, len(components))
+
+	for _, run := range components {
+		go func() {
+			err := run(ctx)
+			cancel()
+			errs <- err
+		}()
+	}
+
+	var result error
+
+	for range components {
+		result = errors.Join(result, <-errs)
+	}
+
+	return result
+}
+
 func main() {
-	if err := rootCmd.Execute(); err != nil {
+	// Cancelled on SIGINT (Ctrl+C) and on SIGTERM sent by Kubernetes, a
+	// second signal kills the process.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	if err := rootCmd.ExecuteContext(ctx); err != nil {
 		slog.Error("cannot execute", "command", rootCmd.Use, "error", err)
 		os.Exit(1)
 	}
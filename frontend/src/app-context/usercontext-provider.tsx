@@ -6,6 +6,7 @@ import * as React from "react";
 import { useState, useEffect, useRef } from "react";
 import { useAuth } from "react-oidc-context";
 
+import { ApiError, Me } from "../types";
 import {
   AppContext,
   UserContract,
@@ -168,7 +169,7 @@ export const AppContextProvider: React.FunctionComponent<Props> = (
       .then((response) => response.json())
       .then((data) => {
         if (data.error) {
-          throw new Error(data.error);
+          throw new Error((data.error as ApiError).message);
         }
 
         updateData({ version: data });
@@ -181,6 +182,37 @@ export const AppContextProvider: React.FunctionComponent<Props> = (
           },
         });
       });
+
+    if (SSO_ENABLED) {
+      fetch("/api", {
+        method: "POST",
+        headers: {
+          ...headers,
+          "Content-Type": "application/json",
+        },
+        body: JSON.stringify({ action: "get_me" }),
+      })
+        .then((response) => response.json())
+        .then((me: Me & { error?: ApiError }) => {
+          if (me.error) {
+            throw new Error(me.error.message);
+          }
+
+          updateUser({
+            username: me.username,
+            isAdmin: me.is_admin,
+            permissions: me.permissions,
+          });
+        })
+        .catch((error) => {
+          updatePage({
+            errorMessage: {
+              title: "Error while getting user",
+              message: error.message,
+            },
+          });
+        });
+    }
   }, [auth]);
 
   return (
@@ -8,6 +8,7 @@ import { Version } from "../types";
 export interface UserContract {
   username: string;
   isAdmin: boolean;
+  permissions?: string[];
 }
 
 export interface DataContract {
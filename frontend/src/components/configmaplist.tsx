@@ -3,9 +3,13 @@ import { useAuth } from "react-oidc-context";
 
 type ConfigMap = {
   name: string;
+  namespace: string;
   data?: Record<string, string>;
 };
 
+// ConfigMaps are listed from all namespaces, names are unique per namespace
+const configMapKey = (cm: ConfigMap) => cm.namespace + "/" + cm.name;
+
 const ConfigMapList: React.FC = () => {
   const [configMaps, setConfigMaps] = useState<ConfigMap[]>([]);
   const [loading, setLoading] = useState(true);
@@ -34,7 +38,7 @@ const ConfigMapList: React.FC = () => {
   }, [auth.user]);
 
   // Fetch single configmap data for editing
-  const handleEdit = (name: string) => {
+  const handleEdit = (cm: ConfigMap) => {
     if (!auth.user?.access_token) return;
     fetch("/api", {
       method: "POST",
@@ -42,16 +46,20 @@ const ConfigMapList: React.FC = () => {
         "Content-Type": "application/json",
         Authorization: "Bearer " + auth.user.access_token,
       },
-      body: JSON.stringify({ action: "get_configmap", name }),
+      body: JSON.stringify({
+        action: "get_configmap",
+        name: cm.name,
+        namespace: cm.namespace,
+      }),
     })
       .then((res) => res.json())
       .then((data) => {
-        setEditing(name);
+        setEditing(configMapKey(cm));
         setEditValue(JSON.stringify(data.data, null, 2));
       });
   };
 
-  const handleSave = (name: string) => {
+  const handleSave = (cm: ConfigMap) => {
     if (!auth.user?.access_token) return;
     fetch("/api", {
       method: "POST",
@@ -61,7 +69,8 @@ const ConfigMapList: React.FC = () => {
       },
       body: JSON.stringify({
         action: "update_configmap",
-        name,
+        name: cm.name,
+        namespace: cm.namespace,
         data: JSON.parse(editValue),
       }),
     }).then(() => {
@@ -70,7 +79,7 @@ const ConfigMapList: React.FC = () => {
     });
   };
 
-  const handleDelete = (name: string) => {
+  const handleDelete = (cm: ConfigMap) => {
     if (!auth.user?.access_token) return;
     fetch("/api", {
       method: "POST",
@@ -80,9 +89,14 @@ const ConfigMapList: React.FC = () => {
       },
       body: JSON.stringify({
         action: "delete_configmap",
-        name,
+        name: cm.name,
+        namespace: cm.namespace,
       }),
-    }).then(() => setConfigMaps(configMaps.filter((c) => c.name !== name)));
+    }).then(() =>
+      setConfigMaps(
+        configMaps.filter((c) => configMapKey(c) !== configMapKey(cm)),
+      ),
+    );
   };
 
   if (loading) return <div>Loading...</div>;
@@ -92,21 +106,21 @@ const ConfigMapList: React.FC = () => {
       <h2>ConfigMaps</h2>
       <ul>
         {configMaps.map((cm) => (
-          <li key={cm.name}>
-            {cm.name}
+          <li key={configMapKey(cm)}>
+            {configMapKey(cm)}
             <button
-              onClick={() => handleEdit(cm.name)}
+              onClick={() => handleEdit(cm)}
               style={{ marginLeft: "10px" }}
             >
               Edit
             </button>
             <button
-              onClick={() => handleDelete(cm.name)}
+              onClick={() => handleDelete(cm)}
               style={{ marginLeft: "10px" }}
             >
               Delete
             </button>
-            {editing === cm.name && (
+            {editing === configMapKey(cm) && (
               <div>
                 <textarea
                   rows={8}
@@ -116,7 +130,7 @@ const ConfigMapList: React.FC = () => {
                   style={{ display: "block", marginTop: "10px" }}
                 />
                 <button
-                  onClick={() => handleSave(cm.name)}
+                  onClick={() => handleSave(cm)}
                   style={{ marginTop: "5px" }}
                 >
                   Save
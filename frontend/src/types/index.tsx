@@ -7,3 +7,27 @@ export interface Version {
   build_time: string;
   go_version: string;
 }
+
+export interface Me {
+  subject: string;
+  username: string;
+  email?: string;
+  groups: string[];
+  roles: string[];
+  is_admin: boolean;
+  permissions: string[];
+}
+
+export interface FieldError {
+  field: string;
+  message: string;
+}
+
+export interface ApiError {
+  status: number;
+  code: string;
+  message: string;
+  details?: Record<string, string>;
+  field_errors?: FieldError[];
+  request_id?: string;
+}
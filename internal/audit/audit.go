@@ -0,0 +1,205 @@
+/**
+ * This work is licensed under Apache License, Version 2.0 or later.
+ * Please read and understand latest version of Licence.
+ */
+package audit
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+	OutcomeDenied  = "denied"
+)
+
+// Record is a single audited API call.
+type Record struct {
+	Time          time.Time `json:"time"`
+	Subject       string    `json:"subject,omitempty"`
+	Username      string    `json:"username"`
+	Action        string    `json:"action"`
+	Namespace     string    `json:"namespace,omitempty"`
+	Name          string    `json:"name,omitempty"`
+	PayloadDigest string    `json:"payload_digest"`
+	Outcome       string    `json:"outcome"`
+	Status        int       `json:"status"`
+	Latency       float64   `json:"latency_ms"`
+}
+
+// Filter selects records from the store. Empty fields match everything.
+type Filter struct {
+	Username  string
+	Action    string
+	Namespace string
+	Name      string
+	Outcome   string
+	Since     time.Time
+	Limit     int
+}
+
+func (f Filter) match(r Record) bool {
+	return (f.Username == "" || f.Username == r.Username) &&
+		(f.Action == "" || f.Action == r.Action) &&
+		(f.Namespace == "" || f.Namespace == r.Namespace) &&
+		(f.Name == "" || f.Name == r.Name) &&
+		(f.Outcome == "" || f.Outcome == r.Outcome) &&
+		(f.Since.IsZero() || !r.Time.Before(f.Since))
+}
+
+// Store keeps the most recent records in a fixed size ring.
+type Store struct {
+	mu      sync.RWMutex
+	records []Record
+	next    int
+	full    bool
+}
+
+func NewStore(size int) *Store {
+	if size <= 0 {
+		size = 1
+	}
+
+	return &Store{records: make([]Record, size)}
+}
+
+func (s *Store) Add(r Record) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.records[s.next] = r
+	s.next = (s.next + 1) % len(s.records)
+
+	if s.next == 0 {
+		s.full = true
+	}
+}
+
+// Query returns matching records, newest first.
+func (s *Store) Query(f Filter) []Record {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	count := s.next
+	if s.full {
+		count = len(s.records)
+	}
+
+	result := []Record{}
+
+	for i := 1; i <= count; i++ {
+		r := s.records[(s.next-i+len(s.records))%len(s.records)]
+
+		if !f.match(r) {
+			continue
+		}
+
+		result = append(result, r)
+
+		if f.Limit > 0 && len(result) >= f.Limit {
+			break
+		}
+	}
+
+	return result
+}
+
+// Auditor writes records to a dedicated slog sink and keeps them queryable.
+type Auditor struct {
+	logger *slog.Logger
+	store  *Store
+	closer io.Closer
+}
+
+var (
+	mu       sync.RWMutex
+	_auditor = &Auditor{
+		logger: slog.New(slog.NewJSONHandler(os.Stdout, nil)),
+		store:  NewStore(1000),
+	}
+)
+
+// Setup replaces the default auditor. sink is one of stdout, file or none.
+func Setup(sink string, file string, maxSizeMB int, maxBackups int, storeSize int) error {
+	var writer io.Writer
+	var closer io.Closer
+
+	switch sink {
+	case "stdout", "":
+		writer = os.Stdout
+	case "file":
+		if file == "" {
+			return fmt.Errorf("audit file is not set")
+		}
+
+		rf, err := NewRotatingFile(file, int64(maxSizeMB)*1024*1024, maxBackups)
+		if err != nil {
+			return fmt.Errorf("failed to open audit file: %w", err)
+		}
+
+		writer = rf
+		closer = rf
+	case "none":
+		writer = io.Discard
+	default:
+		return fmt.Errorf("unknown audit sink: %s", sink)
+	}
+
+	auditor := &Auditor{
+		logger: slog.New(slog.NewJSONHandler(writer, nil)),
+		store:  NewStore(storeSize),
+		closer: closer,
+	}
+
+	mu.Lock()
+	old := _auditor
+	_auditor = auditor
+	mu.Unlock()
+
+	if old.closer != nil {
+		if err := old.closer.Close(); err != nil {
+			slog.Error("Error closing audit sink", "error", err)
+		}
+	}
+
+	return nil
+}
+
+func getAuditor() *Auditor {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	return _auditor
+}
+
+// Log writes the record to the audit sink and the store.
+func Log(ctx context.Context, r Record) {
+	a := getAuditor()
+
+	a.store.Add(r)
+
+	a.logger.LogAttrs(ctx, slog.LevelInfo, "audit",
+		slog.Time("audit_time", r.Time),
+		slog.String("subject", r.Subject),
+		slog.String("username", r.Username),
+		slog.String("action", r.Action),
+		slog.String("namespace", r.Namespace),
+		slog.String("name", r.Name),
+		slog.String("payload_digest", r.PayloadDigest),
+		slog.String("outcome", r.Outcome),
+		slog.Int("status", r.Status),
+		slog.Float64("latency_ms", r.Latency),
+	)
+}
+
+// Query returns matching records from the store, newest first.
+func Query(f Filter) []Record {
+	return getAuditor().store.Query(f)
+}
@@ -0,0 +1,178 @@
+/**
+ * This work is licensed under Apache License, Version 2.0 or later.
+ * Please read and understand latest version of Licence.
+ */
+package audit
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func names(records []Record) []string {
+	result := []string{}
+	for _, r := range records {
+		result = append(result, r.Name)
+	}
+
+	return result
+}
+
+func TestStoreQuery(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	s := NewStore(4)
+
+	// six records in a ring of four, the first two are overwritten
+	for i, name := range []string{"a", "b", "c", "d", "e", "f"} {
+		outcome := OutcomeSuccess
+		if i%2 == 1 {
+			outcome = OutcomeFailure
+		}
+
+		s.Add(Record{
+			Time:      base.Add(time.Duration(i) * time.Minute),
+			Username:  "user-" + string(rune('0'+i%3)),
+			Action:    "update_configmap",
+			Namespace: "default",
+			Name:      name,
+			Outcome:   outcome,
+		})
+	}
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   []string
+	}{
+		{name: "all newest first", filter: Filter{}, want: []string{"f", "e", "d", "c"}},
+		{name: "limit", filter: Filter{Limit: 2}, want: []string{"f", "e"}},
+		{name: "outcome", filter: Filter{Outcome: OutcomeFailure}, want: []string{"f", "d"}},
+		{name: "username", filter: Filter{Username: "user-2"}, want: []string{"f", "c"}},
+		{name: "name", filter: Filter{Name: "d"}, want: []string{"d"}},
+		{name: "overwritten", filter: Filter{Name: "a"}, want: []string{}},
+		{name: "since", filter: Filter{Since: base.Add(4 * time.Minute)}, want: []string{"f", "e"}},
+		{name: "namespace", filter: Filter{Namespace: "other"}, want: []string{}},
+		{name: "combined", filter: Filter{Outcome: OutcomeSuccess, Action: "update_configmap", Limit: 1}, want: []string{"e"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := names(s.Query(tt.filter)); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Query = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStoreNotFull(t *testing.T) {
+	s := NewStore(0)
+
+	if got := names(s.Query(Filter{})); len(got) != 0 {
+		t.Fatalf("empty store returned %v", got)
+	}
+
+	s.Add(Record{Name: "a"})
+	s.Add(Record{Name: "b"})
+
+	// a size below one keeps the last record
+	if got := names(s.Query(Filter{})); !reflect.DeepEqual(got, []string{"b"}) {
+		t.Fatalf("Query = %v, want [b]", got)
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(buf)
+}
+
+func write(t *testing.T, rf *RotatingFile, s string) {
+	t.Helper()
+
+	if _, err := rf.Write([]byte(s)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+
+	rf, err := NewRotatingFile(path, 10, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rf.Close()
+
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		write(t, rf, line)
+	}
+
+	want := map[string]string{
+		path:        "fourth\n",
+		path + ".1": "third\n",
+		path + ".2": "second\n",
+	}
+
+	for file, content := range want {
+		if got := readFile(t, file); got != content {
+			t.Errorf("%s = %q, want %q", filepath.Base(file), got, content)
+		}
+	}
+
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("more than two backups kept: %v", err)
+	}
+}
+
+func TestRotatingFileWithoutBackups(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+
+	if err := os.WriteFile(path, []byte("existing\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	rf, err := NewRotatingFile(path, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rf.Close()
+
+	// the size of the existing file counts
+	write(t, rf, "next\n")
+
+	if got := readFile(t, path); got != "next\n" {
+		t.Fatalf("file = %q, want %q", got, "next\n")
+	}
+}
+
+func TestRotatingFileRenameFailure(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+
+	// a non-empty directory where the backup goes makes the rename fail
+	if err := os.MkdirAll(filepath.Join(path+".1", "blocked"), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	rf, err := NewRotatingFile(path, 10, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rf.Close()
+
+	write(t, rf, "first\n")
+	write(t, rf, "second\n")
+	write(t, rf, "third\n")
+
+	if got := readFile(t, path); got != "first\nsecond\nthird\n" {
+		t.Fatalf("file = %q, records were lost", got)
+	}
+}
@@ -6,6 +6,7 @@ package config
 
 import (
 	"log/slog"
+	"sync/atomic"
 	"time"
 
 	"github.com/spf13/cobra"
@@ -19,11 +20,13 @@ var (
 )
 
 type ConfigBuilder interface {
-	BuildCommandlineFlags(rootCmd *cobra.Command, serverCmd *cobra.Command)
+	BuildCommandlineFlags(rootCmd, serverCmd, watcherCmd *cobra.Command)
 	SyncConfig()
 }
 
 type Config interface {
+	Validate() error
+	ValidateServer() error
 	GetServerPort() int
 	GetDebug() bool
 	GetWait() time.Duration
@@ -32,6 +35,66 @@ type Config interface {
 	GetLocalStaticPath() string
 	GetKubeCAFile() string
 	GetKubeApiServer() string
+	GetJwksCacheTTL() time.Duration
+	GetJwksStaleTTL() time.Duration
+	GetJwksMinRefreshInterval() time.Duration
+	GetOidcAllowedAlgs() []string
+	GetAdminGroup() string
+	GetKubeImpersonate() bool
+	GetKubeImpersonateUserPrefix() string
+	GetKubeImpersonateGroupPrefix() string
+	GetAuditSink() string
+	GetAuditFile() string
+	GetAuditMaxSize() int
+	GetAuditMaxBackups() int
+	GetAuditStoreSize() int
+	GetSseHeartbeat() time.Duration
+	GetSseHistorySize() int
+	GetApiMaxBodySize() int64
+	GetRateLimitReadRPS() float64
+	GetRateLimitReadBurst() int
+	GetRateLimitWriteRPS() float64
+	GetRateLimitWriteBurst() int
+	GetApiAllowMutatingGet() bool
+	GetAllowedOrigins() []string
+	GetIdempotencyStore() string
+	GetIdempotencyTTL() time.Duration
+	GetIdempotencyNamespace() string
+	GetIdempotencyConfigMap() string
+	GetBatchMaxItems() int
+	GetBatchConcurrency() int
+	GetTracingExporter() string
+	GetTracingEndpoint() string
+	GetTracingInsecure() bool
+	GetTracingFile() string
+	GetTracingSampleRatio() float64
+	GetMetricsPort() int
+	GetCheckpointNamespace() string
+	GetCheckpointConfigMap() string
+	GetTlsCertFile() string
+	GetTlsKeyFile() string
+	GetTlsClientCAFile() string
+	GetHttpRedirectPort() int
+	GetJobJitter() float64
+	GetConfigReloadInterval() time.Duration
+	GetJwksRefreshInterval() time.Duration
+	GetCredentialCheckInterval() time.Duration
+	GetCredentialExpiryWarning() time.Duration
+	GetOrphanGCInterval() time.Duration
+	GetWatcherNamespaces() []string
+	GetWatcherTemplateNamespace() string
+	GetWatcherTemplateConfigMap() string
+	GetWatcherTemplateKey() string
+	GetWatcherResyncPeriod() time.Duration
+	GetWatcherWorkers() int
+	GetLogFormat() string
+	GetLogLevels() []string
+	GetLogLevelTimeout() time.Duration
+	GetLogLevelMaxTimeout() time.Duration
+	GetRateLimitIPRPS() float64
+	GetRateLimitIPBurst() int
+	GetTrustedProxies() []string
+	GetWatcherServiceAccount() string
 	GetVersion() string
 	GetBuildTime() string
 	GetGoVersion() string
@@ -41,40 +104,111 @@ type config struct {
 	serverPort      int
 	debug           bool
 	wait            time.Duration
-	rotateTimer     *time.Timer
 	oidcIssuer      string
 	oidcAudience    string
 	localStaticPath string
 	kubeCAFile      string
 	kubeApiServer   string
+
+	jwksCacheTTL           time.Duration
+	jwksStaleTTL           time.Duration
+	jwksMinRefreshInterval time.Duration
+	oidcAllowedAlgs        []string
+	adminGroup             string
+
+	kubeImpersonate            bool
+	kubeImpersonateUserPrefix  string
+	kubeImpersonateGroupPrefix string
+
+	auditSink       string
+	auditFile       string
+	auditMaxSize    int
+	auditMaxBackups int
+	auditStoreSize  int
+
+	sseHeartbeat   time.Duration
+	sseHistorySize int
+
+	apiMaxBodySize      int64
+	rateLimitReadRPS    float64
+	rateLimitReadBurst  int
+	rateLimitWriteRPS   float64
+	rateLimitWriteBurst int
+
+	apiAllowMutatingGet bool
+	allowedOrigins      []string
+
+	idempotencyStore     string
+	idempotencyTTL       time.Duration
+	idempotencyNamespace string
+	idempotencyConfigMap string
+
+	batchMaxItems    int
+	batchConcurrency int
+
+	tracingExporter    string
+	tracingEndpoint    string
+	tracingInsecure    bool
+	tracingFile        string
+	tracingSampleRatio float64
+
+	metricsPort int
+
+	checkpointNamespace string
+	checkpointConfigMap string
+
+	tlsCertFile      string
+	tlsKeyFile       string
+	tlsClientCAFile  string
+	httpRedirectPort int
+
+	jobJitter               float64
+	configReloadInterval    time.Duration
+	jwksRefreshInterval     time.Duration
+	credentialCheckInterval time.Duration
+	credentialExpiryWarning time.Duration
+	orphanGCInterval        time.Duration
+
+	watcherNamespaces        []string
+	watcherTemplateNamespace string
+	watcherTemplateConfigMap string
+	watcherTemplateKey       string
+	watcherResyncPeriod      time.Duration
+	watcherWorkers           int
+
+	logFormat          string
+	logLevels          []string
+	logLevelTimeout    time.Duration
+	logLevelMaxTimeout time.Duration
+
+	rateLimitIPRPS   float64
+	rateLimitIPBurst int
+
+	trustedProxies []string
+
+	watcherServiceAccount string
 }
 
 var (
+	// _config is bound to the command line flags
 	_config *config = nil
+	// current is the snapshot published by SyncConfig
+	current atomic.Pointer[config]
 )
 
 func getConfigSingleton() *config {
 	if _config == nil {
-
-		rt := time.NewTimer(1 * time.Hour)
-
-		go func() {
-			for {
-				<-rt.C
-
-				rt.Reset(1 * time.Hour)
-			}
-		}()
-
-		_config = &config{
-			rotateTimer: rt,
-		}
+		_config = &config{}
 	}
 
 	return _config
 }
 
 func GetConfig() Config {
+	if c := current.Load(); c != nil {
+		return c
+	}
+
 	return getConfigSingleton()
 }
 
@@ -82,7 +216,10 @@ func GetConfigBuilder() ConfigBuilder {
 	return getConfigSingleton()
 }
 
-func (c *config) BuildCommandlineFlags(rootCmd *cobra.Command, serverCmd *cobra.Command) {
+// BuildCommandlineFlags registers the options as flags. Options shared by all
+// commands are persistent flags of rootCmd, the others belong to the command
+// using them.
+func (c *config) BuildCommandlineFlags(rootCmd, serverCmd, watcherCmd *cobra.Command) {
 	rootCmd.PersistentFlags().BoolVarP(&c.debug, "debug", "d", false, "Enable debug mode")
 
 	err := viper.BindPFlag("debug", rootCmd.PersistentFlags().Lookup("debug"))
@@ -102,8 +239,8 @@ func (c *config) BuildCommandlineFlags(rootCmd *cobra.Command, serverCmd *cobra.
 
 	viper.SetDefault("serverPort", 8080)
 
-	serverCmd.Flags().DurationVarP(&c.wait, "wait", "w", 0, "Time to wait before shutting down")
-	err = viper.BindPFlag("wait", serverCmd.Flags().Lookup("wait"))
+	rootCmd.PersistentFlags().DurationVarP(&c.wait, "wait", "w", 0, "Time to drain in-flight requests and reconciles on shutdown")
+	err = viper.BindPFlag("wait", rootCmd.PersistentFlags().Lookup("wait"))
 
 	if err != nil {
 		slog.Error("Error binding wait flag", "error", err)
@@ -138,8 +275,8 @@ func (c *config) BuildCommandlineFlags(rootCmd *cobra.Command, serverCmd *cobra.
 
 	viper.SetDefault("localStaticPath", "")
 
-	serverCmd.Flags().StringVarP(&c.kubeCAFile, "kubeCAFile", "", "", "Kubernetes CA file")
-	err = viper.BindPFlag("kubeCAFile", serverCmd.Flags().Lookup("kubeCAFile"))
+	rootCmd.PersistentFlags().StringVarP(&c.kubeCAFile, "kubeCAFile", "", "", "Kubernetes CA file")
+	err = viper.BindPFlag("kubeCAFile", rootCmd.PersistentFlags().Lookup("kubeCAFile"))
 
 	if err != nil {
 		slog.Error("Error binding kubeCAFile flag", "error", err)
@@ -147,17 +284,567 @@ func (c *config) BuildCommandlineFlags(rootCmd *cobra.Command, serverCmd *cobra.
 
 	viper.SetDefault("kubeCAFile", "")
 
-	serverCmd.Flags().StringVarP(&c.kubeApiServer, "kubeApiServer", "", "", "Kubernetes API server")
-	err = viper.BindPFlag("kubeApiServer", serverCmd.Flags().Lookup("kubeApiServer"))
+	rootCmd.PersistentFlags().StringVarP(&c.kubeApiServer, "kubeApiServer", "", "", "Kubernetes API server")
+	err = viper.BindPFlag("kubeApiServer", rootCmd.PersistentFlags().Lookup("kubeApiServer"))
 
 	if err != nil {
 		slog.Error("Error binding kubeApiServer flag", "error", err)
 	}
 
 	viper.SetDefault("kubeApiServer", "")
+
+	serverCmd.Flags().DurationVarP(&c.jwksCacheTTL, "jwksCacheTTL", "", 0, "How long OIDC discovery and JWKS are cached")
+	err = viper.BindPFlag("jwksCacheTTL", serverCmd.Flags().Lookup("jwksCacheTTL"))
+
+	if err != nil {
+		slog.Error("Error binding jwksCacheTTL flag", "error", err)
+	}
+
+	viper.SetDefault("jwksCacheTTL", 15*time.Minute)
+
+	serverCmd.Flags().DurationVarP(&c.jwksStaleTTL, "jwksStaleTTL", "", 0, "How long an expired JWKS is served while the issuer is unreachable")
+	err = viper.BindPFlag("jwksStaleTTL", serverCmd.Flags().Lookup("jwksStaleTTL"))
+
+	if err != nil {
+		slog.Error("Error binding jwksStaleTTL flag", "error", err)
+	}
+
+	viper.SetDefault("jwksStaleTTL", 1*time.Hour)
+
+	serverCmd.Flags().DurationVarP(&c.jwksMinRefreshInterval, "jwksMinRefreshInterval", "", 0, "Minimum interval between JWKS refreshes triggered by unknown key ids")
+	err = viper.BindPFlag("jwksMinRefreshInterval", serverCmd.Flags().Lookup("jwksMinRefreshInterval"))
+
+	if err != nil {
+		slog.Error("Error binding jwksMinRefreshInterval flag", "error", err)
+	}
+
+	viper.SetDefault("jwksMinRefreshInterval", 30*time.Second)
+
+	serverCmd.Flags().StringSliceVarP(&c.oidcAllowedAlgs, "oidcAllowedAlgs", "", nil, "Token signing algorithms accepted from the OIDC issuer")
+	err = viper.BindPFlag("oidcAllowedAlgs", serverCmd.Flags().Lookup("oidcAllowedAlgs"))
+
+	if err != nil {
+		slog.Error("Error binding oidcAllowedAlgs flag", "error", err)
+	}
+
+	viper.SetDefault("oidcAllowedAlgs", supportedAlgs)
+
+	serverCmd.Flags().StringVarP(&c.adminGroup, "adminGroup", "", "", "OIDC group whose members may call admin-only actions")
+	err = viper.BindPFlag("adminGroup", serverCmd.Flags().Lookup("adminGroup"))
+
+	if err != nil {
+		slog.Error("Error binding adminGroup flag", "error", err)
+	}
+
+	viper.SetDefault("adminGroup", "admins")
+
+	serverCmd.Flags().BoolVarP(&c.kubeImpersonate, "kubeImpersonate", "", false, "Call the Kubernetes API as the OIDC user and groups")
+	err = viper.BindPFlag("kubeImpersonate", serverCmd.Flags().Lookup("kubeImpersonate"))
+
+	if err != nil {
+		slog.Error("Error binding kubeImpersonate flag", "error", err)
+	}
+
+	viper.SetDefault("kubeImpersonate", false)
+
+	serverCmd.Flags().StringVarP(&c.kubeImpersonateUserPrefix, "kubeImpersonateUserPrefix", "", "", "Prefix added to impersonated usernames")
+	err = viper.BindPFlag("kubeImpersonateUserPrefix", serverCmd.Flags().Lookup("kubeImpersonateUserPrefix"))
+
+	if err != nil {
+		slog.Error("Error binding kubeImpersonateUserPrefix flag", "error", err)
+	}
+
+	viper.SetDefault("kubeImpersonateUserPrefix", "")
+
+	serverCmd.Flags().StringVarP(&c.kubeImpersonateGroupPrefix, "kubeImpersonateGroupPrefix", "", "", "Prefix added to impersonated groups")
+	err = viper.BindPFlag("kubeImpersonateGroupPrefix", serverCmd.Flags().Lookup("kubeImpersonateGroupPrefix"))
+
+	if err != nil {
+		slog.Error("Error binding kubeImpersonateGroupPrefix flag", "error", err)
+	}
+
+	viper.SetDefault("kubeImpersonateGroupPrefix", "")
+
+	serverCmd.Flags().StringVarP(&c.auditSink, "auditSink", "", "", "Audit log sink: stdout, file or none")
+	err = viper.BindPFlag("auditSink", serverCmd.Flags().Lookup("auditSink"))
+
+	if err != nil {
+		slog.Error("Error binding auditSink flag", "error", err)
+	}
+
+	viper.SetDefault("auditSink", "stdout")
+
+	serverCmd.Flags().StringVarP(&c.auditFile, "auditFile", "", "", "Audit log file used when the sink is file")
+	err = viper.BindPFlag("auditFile", serverCmd.Flags().Lookup("auditFile"))
+
+	if err != nil {
+		slog.Error("Error binding auditFile flag", "error", err)
+	}
+
+	viper.SetDefault("auditFile", "")
+
+	serverCmd.Flags().IntVarP(&c.auditMaxSize, "auditMaxSize", "", 0, "Maximum audit log file size in megabytes before rotation")
+	err = viper.BindPFlag("auditMaxSize", serverCmd.Flags().Lookup("auditMaxSize"))
+
+	if err != nil {
+		slog.Error("Error binding auditMaxSize flag", "error", err)
+	}
+
+	viper.SetDefault("auditMaxSize", 100)
+
+	serverCmd.Flags().IntVarP(&c.auditMaxBackups, "auditMaxBackups", "", 0, "Number of rotated audit log files to keep")
+	err = viper.BindPFlag("auditMaxBackups", serverCmd.Flags().Lookup("auditMaxBackups"))
+
+	if err != nil {
+		slog.Error("Error binding auditMaxBackups flag", "error", err)
+	}
+
+	viper.SetDefault("auditMaxBackups", 5)
+
+	serverCmd.Flags().IntVarP(&c.auditStoreSize, "auditStoreSize", "", 0, "Number of audit records kept in memory for get_audit_log")
+	err = viper.BindPFlag("auditStoreSize", serverCmd.Flags().Lookup("auditStoreSize"))
+
+	if err != nil {
+		slog.Error("Error binding auditStoreSize flag", "error", err)
+	}
+
+	viper.SetDefault("auditStoreSize", 1000)
+
+	serverCmd.Flags().DurationVarP(&c.sseHeartbeat, "sseHeartbeat", "", 0, "Interval of heartbeat comments on the event stream")
+	err = viper.BindPFlag("sseHeartbeat", serverCmd.Flags().Lookup("sseHeartbeat"))
+
+	if err != nil {
+		slog.Error("Error binding sseHeartbeat flag", "error", err)
+	}
+
+	viper.SetDefault("sseHeartbeat", 15*time.Second)
+
+	serverCmd.Flags().IntVarP(&c.sseHistorySize, "sseHistorySize", "", 0, "Number of cluster events kept for resuming event streams")
+	err = viper.BindPFlag("sseHistorySize", serverCmd.Flags().Lookup("sseHistorySize"))
+
+	if err != nil {
+		slog.Error("Error binding sseHistorySize flag", "error", err)
+	}
+
+	viper.SetDefault("sseHistorySize", 1000)
+
+	serverCmd.Flags().Int64VarP(&c.apiMaxBodySize, "apiMaxBodySize", "", 0, "Maximum size of an API request body in bytes")
+	err = viper.BindPFlag("apiMaxBodySize", serverCmd.Flags().Lookup("apiMaxBodySize"))
+
+	if err != nil {
+		slog.Error("Error binding apiMaxBodySize flag", "error", err)
+	}
+
+	viper.SetDefault("apiMaxBodySize", 1<<20)
+
+	serverCmd.Flags().Float64VarP(&c.rateLimitReadRPS, "rateLimitReadRPS", "", 0, "Requests per second allowed per client for read actions, 0 disables the limit")
+	err = viper.BindPFlag("rateLimitReadRPS", serverCmd.Flags().Lookup("rateLimitReadRPS"))
+
+	if err != nil {
+		slog.Error("Error binding rateLimitReadRPS flag", "error", err)
+	}
+
+	viper.SetDefault("rateLimitReadRPS", 10.0)
+
+	serverCmd.Flags().IntVarP(&c.rateLimitReadBurst, "rateLimitReadBurst", "", 0, "Burst size per client for read actions")
+	err = viper.BindPFlag("rateLimitReadBurst", serverCmd.Flags().Lookup("rateLimitReadBurst"))
+
+	if err != nil {
+		slog.Error("Error binding rateLimitReadBurst flag", "error", err)
+	}
+
+	viper.SetDefault("rateLimitReadBurst", 20)
+
+	serverCmd.Flags().Float64VarP(&c.rateLimitWriteRPS, "rateLimitWriteRPS", "", 0, "Requests per second allowed per client for mutating actions, 0 disables the limit")
+	err = viper.BindPFlag("rateLimitWriteRPS", serverCmd.Flags().Lookup("rateLimitWriteRPS"))
+
+	if err != nil {
+		slog.Error("Error binding rateLimitWriteRPS flag", "error", err)
+	}
+
+	viper.SetDefault("rateLimitWriteRPS", 1.0)
+
+	serverCmd.Flags().IntVarP(&c.rateLimitWriteBurst, "rateLimitWriteBurst", "", 0, "Burst size per client for mutating actions")
+	err = viper.BindPFlag("rateLimitWriteBurst", serverCmd.Flags().Lookup("rateLimitWriteBurst"))
+
+	if err != nil {
+		slog.Error("Error binding rateLimitWriteBurst flag", "error", err)
+	}
+
+	viper.SetDefault("rateLimitWriteBurst", 5)
+
+	serverCmd.Flags().BoolVarP(&c.apiAllowMutatingGet, "apiAllowMutatingGet", "", false, "Allow mutating API actions to be called with GET")
+	err = viper.BindPFlag("apiAllowMutatingGet", serverCmd.Flags().Lookup("apiAllowMutatingGet"))
+
+	if err != nil {
+		slog.Error("Error binding apiAllowMutatingGet flag", "error", err)
+	}
+
+	viper.SetDefault("apiAllowMutatingGet", false)
+
+	serverCmd.Flags().StringSliceVarP(&c.allowedOrigins, "allowedOrigins", "", nil, "Origins allowed to send form or query based API calls besides the server itself")
+	err = viper.BindPFlag("allowedOrigins", serverCmd.Flags().Lookup("allowedOrigins"))
+
+	if err != nil {
+		slog.Error("Error binding allowedOrigins flag", "error", err)
+	}
+
+	viper.SetDefault("allowedOrigins", []string{})
+
+	serverCmd.Flags().StringVarP(&c.idempotencyStore, "idempotencyStore", "", "", "Store of idempotency keys: memory, configmap or none")
+	err = viper.BindPFlag("idempotencyStore", serverCmd.Flags().Lookup("idempotencyStore"))
+
+	if err != nil {
+		slog.Error("Error binding idempotencyStore flag", "error", err)
+	}
+
+	viper.SetDefault("idempotencyStore", "memory")
+
+	serverCmd.Flags().DurationVarP(&c.idempotencyTTL, "idempotencyTTL", "", 0, "How long the response of an idempotency key is replayed")
+	err = viper.BindPFlag("idempotencyTTL", serverCmd.Flags().Lookup("idempotencyTTL"))
+
+	if err != nil {
+		slog.Error("Error binding idempotencyTTL flag", "error", err)
+	}
+
+	viper.SetDefault("idempotencyTTL", 24*time.Hour)
+
+	serverCmd.Flags().StringVarP(&c.idempotencyNamespace, "idempotencyNamespace", "", "", "Namespace of the idempotency ConfigMap")
+	err = viper.BindPFlag("idempotencyNamespace", serverCmd.Flags().Lookup("idempotencyNamespace"))
+
+	if err != nil {
+		slog.Error("Error binding idempotencyNamespace flag", "error", err)
+	}
+
+	viper.SetDefault("idempotencyNamespace", "default")
+
+	serverCmd.Flags().StringVarP(&c.idempotencyConfigMap, "idempotencyConfigMap", "", "", "Name of the idempotency ConfigMap, it holds up to 900KiB of responses and evicts the entries expiring first beyond that")
+	err = viper.BindPFlag("idempotencyConfigMap", serverCmd.Flags().Lookup("idempotencyConfigMap"))
+
+	if err != nil {
+		slog.Error("Error binding idempotencyConfigMap flag", "error", err)
+	}
+
+	viper.SetDefault("idempotencyConfigMap", "idempotency-keys")
+
+	serverCmd.Flags().IntVarP(&c.batchMaxItems, "batchMaxItems", "", 0, "Maximum number of sub-actions of a batch call")
+	err = viper.BindPFlag("batchMaxItems", serverCmd.Flags().Lookup("batchMaxItems"))
+
+	if err != nil {
+		slog.Error("Error binding batchMaxItems flag", "error", err)
+	}
+
+	viper.SetDefault("batchMaxItems", 100)
+
+	serverCmd.Flags().IntVarP(&c.batchConcurrency, "batchConcurrency", "", 0, "Number of sub-actions of a batch call run concurrently")
+	err = viper.BindPFlag("batchConcurrency", serverCmd.Flags().Lookup("batchConcurrency"))
+
+	if err != nil {
+		slog.Error("Error binding batchConcurrency flag", "error", err)
+	}
+
+	viper.SetDefault("batchConcurrency", 4)
+
+	rootCmd.PersistentFlags().StringVarP(&c.tracingExporter, "tracingExporter", "", "", "Trace exporter: none, otlp or file")
+	err = viper.BindPFlag("tracingExporter", rootCmd.PersistentFlags().Lookup("tracingExporter"))
+
+	if err != nil {
+		slog.Error("Error binding tracingExporter flag", "error", err)
+	}
+
+	viper.SetDefault("tracingExporter", "none")
+
+	rootCmd.PersistentFlags().StringVarP(&c.tracingEndpoint, "tracingEndpoint", "", "", "OTLP/HTTP endpoint of the otlp trace exporter")
+	err = viper.BindPFlag("tracingEndpoint", rootCmd.PersistentFlags().Lookup("tracingEndpoint"))
+
+	if err != nil {
+		slog.Error("Error binding tracingEndpoint flag", "error", err)
+	}
+
+	viper.SetDefault("tracingEndpoint", "localhost:4318")
+
+	rootCmd.PersistentFlags().BoolVarP(&c.tracingInsecure, "tracingInsecure", "", false, "Use plain HTTP for the otlp trace exporter")
+	err = viper.BindPFlag("tracingInsecure", rootCmd.PersistentFlags().Lookup("tracingInsecure"))
+
+	if err != nil {
+		slog.Error("Error binding tracingInsecure flag", "error", err)
+	}
+
+	viper.SetDefault("tracingInsecure", false)
+
+	rootCmd.PersistentFlags().StringVarP(&c.tracingFile, "tracingFile", "", "", "File of the file trace exporter")
+	err = viper.BindPFlag("tracingFile", rootCmd.PersistentFlags().Lookup("tracingFile"))
+
+	if err != nil {
+		slog.Error("Error binding tracingFile flag", "error", err)
+	}
+
+	viper.SetDefault("tracingFile", "traces.json")
+
+	rootCmd.PersistentFlags().Float64VarP(&c.tracingSampleRatio, "tracingSampleRatio", "", 0, "Ratio of traces sampled when the caller did not decide")
+	err = viper.BindPFlag("tracingSampleRatio", rootCmd.PersistentFlags().Lookup("tracingSampleRatio"))
+
+	if err != nil {
+		slog.Error("Error binding tracingSampleRatio flag", "error", err)
+	}
+
+	viper.SetDefault("tracingSampleRatio", 1.0)
+
+	rootCmd.PersistentFlags().IntVarP(&c.metricsPort, "metricsPort", "", 0, "Port of the Prometheus /metrics endpoint, 0 disables it")
+	err = viper.BindPFlag("metricsPort", rootCmd.PersistentFlags().Lookup("metricsPort"))
+
+	if err != nil {
+		slog.Error("Error binding metricsPort flag", "error", err)
+	}
+
+	viper.SetDefault("metricsPort", 9090)
+
+	watcherCmd.Flags().StringVarP(&c.checkpointNamespace, "checkpointNamespace", "", "", "Namespace of the ConfigMap keeping the watcher teardown checkpoints")
+	err = viper.BindPFlag("checkpointNamespace", watcherCmd.Flags().Lookup("checkpointNamespace"))
+
+	if err != nil {
+		slog.Error("Error binding checkpointNamespace flag", "error", err)
+	}
+
+	viper.SetDefault("checkpointNamespace", "default")
+
+	watcherCmd.Flags().StringVarP(&c.checkpointConfigMap, "checkpointConfigMap", "", "", "Name of the ConfigMap keeping the watcher teardown checkpoints")
+	err = viper.BindPFlag("checkpointConfigMap", watcherCmd.Flags().Lookup("checkpointConfigMap"))
+
+	if err != nil {
+		slog.Error("Error binding checkpointConfigMap flag", "error", err)
+	}
+
+	viper.SetDefault("checkpointConfigMap", "watcher-checkpoints")
+
+	serverCmd.Flags().StringVarP(&c.tlsCertFile, "tlsCertFile", "", "", "TLS certificate file, the server serves plaintext when empty")
+	err = viper.BindPFlag("tlsCertFile", serverCmd.Flags().Lookup("tlsCertFile"))
+
+	if err != nil {
+		slog.Error("Error binding tlsCertFile flag", "error", err)
+	}
+
+	viper.SetDefault("tlsCertFile", "")
+
+	serverCmd.Flags().StringVarP(&c.tlsKeyFile, "tlsKeyFile", "", "", "TLS private key file")
+	err = viper.BindPFlag("tlsKeyFile", serverCmd.Flags().Lookup("tlsKeyFile"))
+
+	if err != nil {
+		slog.Error("Error binding tlsKeyFile flag", "error", err)
+	}
+
+	viper.SetDefault("tlsKeyFile", "")
+
+	serverCmd.Flags().StringVarP(&c.tlsClientCAFile, "tlsClientCAFile", "", "", "CA bundle verifying client certificates, clients must present one when set")
+	err = viper.BindPFlag("tlsClientCAFile", serverCmd.Flags().Lookup("tlsClientCAFile"))
+
+	if err != nil {
+		slog.Error("Error binding tlsClientCAFile flag", "error", err)
+	}
+
+	viper.SetDefault("tlsClientCAFile", "")
+
+	serverCmd.Flags().IntVarP(&c.httpRedirectPort, "httpRedirectPort", "", 0, "Port of a plaintext listener redirecting to HTTPS, 0 disables it")
+	err = viper.BindPFlag("httpRedirectPort", serverCmd.Flags().Lookup("httpRedirectPort"))
+
+	if err != nil {
+		slog.Error("Error binding httpRedirectPort flag", "error", err)
+	}
+
+	viper.SetDefault("httpRedirectPort", 0)
+
+	rootCmd.PersistentFlags().Float64VarP(&c.jobJitter, "jobJitter", "", 0, "Random delay added to scheduled jobs as a fraction of their interval")
+	err = viper.BindPFlag("jobJitter", rootCmd.PersistentFlags().Lookup("jobJitter"))
+
+	if err != nil {
+		slog.Error("Error binding jobJitter flag", "error", err)
+	}
+
+	viper.SetDefault("jobJitter", 0.1)
+
+	rootCmd.PersistentFlags().DurationVarP(&c.configReloadInterval, "configReloadInterval", "", 0, "Interval of re-reading the config file, 0 disables it")
+	err = viper.BindPFlag("configReloadInterval", rootCmd.PersistentFlags().Lookup("configReloadInterval"))
+
+	if err != nil {
+		slog.Error("Error binding configReloadInterval flag", "error", err)
+	}
+
+	viper.SetDefault("configReloadInterval", 5*time.Minute)
+
+	serverCmd.Flags().DurationVarP(&c.jwksRefreshInterval, "jwksRefreshInterval", "", 0, "Interval of refreshing the JWKS ahead of its expiry, 0 disables it")
+	err = viper.BindPFlag("jwksRefreshInterval", serverCmd.Flags().Lookup("jwksRefreshInterval"))
+
+	if err != nil {
+		slog.Error("Error binding jwksRefreshInterval flag", "error", err)
+	}
+
+	viper.SetDefault("jwksRefreshInterval", 10*time.Minute)
+
+	rootCmd.PersistentFlags().DurationVarP(&c.credentialCheckInterval, "credentialCheckInterval", "", 0, "Interval of checking the expiry of the TLS and Kubernetes client certificates, 0 disables it")
+	err = viper.BindPFlag("credentialCheckInterval", rootCmd.PersistentFlags().Lookup("credentialCheckInterval"))
+
+	if err != nil {
+		slog.Error("Error binding credentialCheckInterval flag", "error", err)
+	}
+
+	viper.SetDefault("credentialCheckInterval", time.Hour)
+
+	rootCmd.PersistentFlags().DurationVarP(&c.credentialExpiryWarning, "credentialExpiryWarning", "", 0, "Remaining validity below which a certificate check fails")
+	err = viper.BindPFlag("credentialExpiryWarning", rootCmd.PersistentFlags().Lookup("credentialExpiryWarning"))
+
+	if err != nil {
+		slog.Error("Error binding credentialExpiryWarning flag", "error", err)
+	}
+
+	viper.SetDefault("credentialExpiryWarning", 7*24*time.Hour)
+
+	watcherCmd.Flags().DurationVarP(&c.orphanGCInterval, "orphanGCInterval", "", 0, "Interval of tearing down cluster resources whose ConfigMap is gone, 0 disables it")
+	err = viper.BindPFlag("orphanGCInterval", watcherCmd.Flags().Lookup("orphanGCInterval"))
+
+	if err != nil {
+		slog.Error("Error binding orphanGCInterval flag", "error", err)
+	}
+
+	viper.SetDefault("orphanGCInterval", 10*time.Minute)
+
+	watcherCmd.Flags().StringSliceVarP(&c.watcherNamespaces, "watcherNamespaces", "", nil, "Namespaces watched for cluster ConfigMaps, all namespaces when empty")
+	err = viper.BindPFlag("watcherNamespaces", watcherCmd.Flags().Lookup("watcherNamespaces"))
+
+	if err != nil {
+		slog.Error("Error binding watcherNamespaces flag", "error", err)
+	}
+
+	viper.SetDefault("watcherNamespaces", []string{})
+
+	watcherCmd.Flags().StringVarP(&c.watcherTemplateNamespace, "watcherTemplateNamespace", "", "", "Namespace of the cluster template ConfigMap")
+	err = viper.BindPFlag("watcherTemplateNamespace", watcherCmd.Flags().Lookup("watcherTemplateNamespace"))
+
+	if err != nil {
+		slog.Error("Error binding watcherTemplateNamespace flag", "error", err)
+	}
+
+	viper.SetDefault("watcherTemplateNamespace", "template-namespace")
+
+	watcherCmd.Flags().StringVarP(&c.watcherTemplateConfigMap, "watcherTemplateConfigMap", "", "", "Name of the cluster template ConfigMap")
+	err = viper.BindPFlag("watcherTemplateConfigMap", watcherCmd.Flags().Lookup("watcherTemplateConfigMap"))
+
+	if err != nil {
+		slog.Error("Error binding watcherTemplateConfigMap flag", "error", err)
+	}
+
+	viper.SetDefault("watcherTemplateConfigMap", "db-template")
+
+	watcherCmd.Flags().StringVarP(&c.watcherTemplateKey, "watcherTemplateKey", "", "", "Key of the cluster template in its ConfigMap")
+	err = viper.BindPFlag("watcherTemplateKey", watcherCmd.Flags().Lookup("watcherTemplateKey"))
+
+	if err != nil {
+		slog.Error("Error binding watcherTemplateKey flag", "error", err)
+	}
+
+	viper.SetDefault("watcherTemplateKey", "db.yaml")
+
+	watcherCmd.Flags().DurationVarP(&c.watcherResyncPeriod, "watcherResyncPeriod", "", 0, "Period after which all cluster ConfigMaps are reconciled again, 0 disables resyncs")
+	err = viper.BindPFlag("watcherResyncPeriod", watcherCmd.Flags().Lookup("watcherResyncPeriod"))
+
+	if err != nil {
+		slog.Error("Error binding watcherResyncPeriod flag", "error", err)
+	}
+
+	viper.SetDefault("watcherResyncPeriod", 0)
+
+	watcherCmd.Flags().IntVarP(&c.watcherWorkers, "watcherWorkers", "", 0, "Number of clusters reconciled concurrently")
+	err = viper.BindPFlag("watcherWorkers", watcherCmd.Flags().Lookup("watcherWorkers"))
+
+	if err != nil {
+		slog.Error("Error binding watcherWorkers flag", "error", err)
+	}
+
+	viper.SetDefault("watcherWorkers", 1)
+
+	rootCmd.PersistentFlags().StringVarP(&c.logFormat, "logFormat", "", "", "Log output format, one of pretty, json or logfmt")
+	err = viper.BindPFlag("logFormat", rootCmd.PersistentFlags().Lookup("logFormat"))
+
+	if err != nil {
+		slog.Error("Error binding logFormat flag", "error", err)
+	}
+
+	viper.SetDefault("logFormat", "pretty")
+
+	rootCmd.PersistentFlags().StringSliceVarP(&c.logLevels, "logLevels", "", nil, "Log levels of modules as module=level, like webserver=debug")
+	err = viper.BindPFlag("logLevels", rootCmd.PersistentFlags().Lookup("logLevels"))
+
+	if err != nil {
+		slog.Error("Error binding logLevels flag", "error", err)
+	}
+
+	viper.SetDefault("logLevels", []string{})
+
+	serverCmd.Flags().DurationVarP(&c.logLevelTimeout, "logLevelTimeout", "", 0, "Default duration of log level changes made at runtime")
+	err = viper.BindPFlag("logLevelTimeout", serverCmd.Flags().Lookup("logLevelTimeout"))
+
+	if err != nil {
+		slog.Error("Error binding logLevelTimeout flag", "error", err)
+	}
+
+	viper.SetDefault("logLevelTimeout", 15*time.Minute)
+
+	serverCmd.Flags().DurationVarP(&c.logLevelMaxTimeout, "logLevelMaxTimeout", "", 0, "Maximum duration of log level changes made at runtime")
+	err = viper.BindPFlag("logLevelMaxTimeout", serverCmd.Flags().Lookup("logLevelMaxTimeout"))
+
+	if err != nil {
+		slog.Error("Error binding logLevelMaxTimeout flag", "error", err)
+	}
+
+	viper.SetDefault("logLevelMaxTimeout", 4*time.Hour)
+
+	serverCmd.Flags().Float64VarP(&c.rateLimitIPRPS, "rateLimitIPRPS", "", 0, "Requests per second allowed per remote address before authentication, 0 disables the limit")
+	err = viper.BindPFlag("rateLimitIPRPS", serverCmd.Flags().Lookup("rateLimitIPRPS"))
+
+	if err != nil {
+		slog.Error("Error binding rateLimitIPRPS flag", "error", err)
+	}
+
+	viper.SetDefault("rateLimitIPRPS", 50.0)
+
+	serverCmd.Flags().IntVarP(&c.rateLimitIPBurst, "rateLimitIPBurst", "", 0, "Burst size per remote address before authentication")
+	err = viper.BindPFlag("rateLimitIPBurst", serverCmd.Flags().Lookup("rateLimitIPBurst"))
+
+	if err != nil {
+		slog.Error("Error binding rateLimitIPBurst flag", "error", err)
+	}
+
+	viper.SetDefault("rateLimitIPBurst", 100)
+
+	serverCmd.Flags().StringSliceVarP(&c.trustedProxies, "trustedProxies", "", nil, "CIDRs of the reverse proxies whose X-Forwarded-For and X-Real-IP headers are honoured, other peers are keyed by their socket address")
+	err = viper.BindPFlag("trustedProxies", serverCmd.Flags().Lookup("trustedProxies"))
+
+	if err != nil {
+		slog.Error("Error binding trustedProxies flag", "error", err)
+	}
+
+	viper.SetDefault("trustedProxies", []string{})
+
+	watcherCmd.Flags().StringVarP(&c.watcherServiceAccount, "watcherServiceAccount", "", "", "Service account of the cluster pods, the serviceAccount key of a cluster ConfigMap overrides it")
+	err = viper.BindPFlag("watcherServiceAccount", watcherCmd.Flags().Lookup("watcherServiceAccount"))
+
+	if err != nil {
+		slog.Error("Error binding watcherServiceAccount flag", "error", err)
+	}
+
+	viper.SetDefault("watcherServiceAccount", "default")
 }
 
+// SyncConfig loads the options from viper into a new snapshot and publishes
+// it. Readers keep the snapshot they already hold, so a reload never changes
+// options in the middle of a request.
 func (c *config) SyncConfig() {
+	next := &config{}
+	next.load()
+
+	current.Store(next)
+}
+
+func (c *config) load() {
 	c.debug = viper.GetBool("debug")
 	c.serverPort = viper.GetInt("serverPort")
 	c.wait = viper.GetDuration("wait")
@@ -166,6 +853,66 @@ func (c *config) SyncConfig() {
 	c.localStaticPath = viper.GetString("localStaticPath")
 	c.kubeCAFile = viper.GetString("kubeCAFile")
 	c.kubeApiServer = viper.GetString("kubeApiServer")
+	c.jwksCacheTTL = viper.GetDuration("jwksCacheTTL")
+	c.jwksStaleTTL = viper.GetDuration("jwksStaleTTL")
+	c.jwksMinRefreshInterval = viper.GetDuration("jwksMinRefreshInterval")
+	c.oidcAllowedAlgs = viper.GetStringSlice("oidcAllowedAlgs")
+	c.adminGroup = viper.GetString("adminGroup")
+	c.kubeImpersonate = viper.GetBool("kubeImpersonate")
+	c.kubeImpersonateUserPrefix = viper.GetString("kubeImpersonateUserPrefix")
+	c.kubeImpersonateGroupPrefix = viper.GetString("kubeImpersonateGroupPrefix")
+	c.auditSink = viper.GetString("auditSink")
+	c.auditFile = viper.GetString("auditFile")
+	c.auditMaxSize = viper.GetInt("auditMaxSize")
+	c.auditMaxBackups = viper.GetInt("auditMaxBackups")
+	c.auditStoreSize = viper.GetInt("auditStoreSize")
+	c.sseHeartbeat = viper.GetDuration("sseHeartbeat")
+	c.sseHistorySize = viper.GetInt("sseHistorySize")
+	c.apiMaxBodySize = viper.GetInt64("apiMaxBodySize")
+	c.rateLimitReadRPS = viper.GetFloat64("rateLimitReadRPS")
+	c.rateLimitReadBurst = viper.GetInt("rateLimitReadBurst")
+	c.rateLimitWriteRPS = viper.GetFloat64("rateLimitWriteRPS")
+	c.rateLimitWriteBurst = viper.GetInt("rateLimitWriteBurst")
+	c.apiAllowMutatingGet = viper.GetBool("apiAllowMutatingGet")
+	c.allowedOrigins = viper.GetStringSlice("allowedOrigins")
+	c.idempotencyStore = viper.GetString("idempotencyStore")
+	c.idempotencyTTL = viper.GetDuration("idempotencyTTL")
+	c.idempotencyNamespace = viper.GetString("idempotencyNamespace")
+	c.idempotencyConfigMap = viper.GetString("idempotencyConfigMap")
+	c.batchMaxItems = viper.GetInt("batchMaxItems")
+	c.batchConcurrency = viper.GetInt("batchConcurrency")
+	c.tracingExporter = viper.GetString("tracingExporter")
+	c.tracingEndpoint = viper.GetString("tracingEndpoint")
+	c.tracingInsecure = viper.GetBool("tracingInsecure")
+	c.tracingFile = viper.GetString("tracingFile")
+	c.tracingSampleRatio = viper.GetFloat64("tracingSampleRatio")
+	c.metricsPort = viper.GetInt("metricsPort")
+	c.checkpointNamespace = viper.GetString("checkpointNamespace")
+	c.checkpointConfigMap = viper.GetString("checkpointConfigMap")
+	c.tlsCertFile = viper.GetString("tlsCertFile")
+	c.tlsKeyFile = viper.GetString("tlsKeyFile")
+	c.tlsClientCAFile = viper.GetString("tlsClientCAFile")
+	c.httpRedirectPort = viper.GetInt("httpRedirectPort")
+	c.jobJitter = viper.GetFloat64("jobJitter")
+	c.configReloadInterval = viper.GetDuration("configReloadInterval")
+	c.jwksRefreshInterval = viper.GetDuration("jwksRefreshInterval")
+	c.credentialCheckInterval = viper.GetDuration("credentialCheckInterval")
+	c.credentialExpiryWarning = viper.GetDuration("credentialExpiryWarning")
+	c.orphanGCInterval = viper.GetDuration("orphanGCInterval")
+	c.watcherNamespaces = viper.GetStringSlice("watcherNamespaces")
+	c.watcherTemplateNamespace = viper.GetString("watcherTemplateNamespace")
+	c.watcherTemplateConfigMap = viper.GetString("watcherTemplateConfigMap")
+	c.watcherTemplateKey = viper.GetString("watcherTemplateKey")
+	c.watcherResyncPeriod = viper.GetDuration("watcherResyncPeriod")
+	c.watcherWorkers = viper.GetInt("watcherWorkers")
+	c.logFormat = viper.GetString("logFormat")
+	c.logLevels = viper.GetStringSlice("logLevels")
+	c.logLevelTimeout = viper.GetDuration("logLevelTimeout")
+	c.logLevelMaxTimeout = viper.GetDuration("logLevelMaxTimeout")
+	c.rateLimitIPRPS = viper.GetFloat64("rateLimitIPRPS")
+	c.rateLimitIPBurst = viper.GetInt("rateLimitIPBurst")
+	c.trustedProxies = viper.GetStringSlice("trustedProxies")
+	c.watcherServiceAccount = viper.GetString("watcherServiceAccount")
 }
 
 func (c *config) GetServerPort() int {
@@ -200,6 +947,246 @@ func (c *config) GetKubeApiServer() string {
 	return c.kubeApiServer
 }
 
+func (c *config) GetJwksCacheTTL() time.Duration {
+	return c.jwksCacheTTL
+}
+
+func (c *config) GetJwksStaleTTL() time.Duration {
+	return c.jwksStaleTTL
+}
+
+func (c *config) GetJwksMinRefreshInterval() time.Duration {
+	return c.jwksMinRefreshInterval
+}
+
+func (c *config) GetOidcAllowedAlgs() []string {
+	return c.oidcAllowedAlgs
+}
+
+func (c *config) GetAdminGroup() string {
+	return c.adminGroup
+}
+
+func (c *config) GetKubeImpersonate() bool {
+	return c.kubeImpersonate
+}
+
+func (c *config) GetKubeImpersonateUserPrefix() string {
+	return c.kubeImpersonateUserPrefix
+}
+
+func (c *config) GetKubeImpersonateGroupPrefix() string {
+	return c.kubeImpersonateGroupPrefix
+}
+
+func (c *config) GetAuditSink() string {
+	return c.auditSink
+}
+
+func (c *config) GetAuditFile() string {
+	return c.auditFile
+}
+
+func (c *config) GetAuditMaxSize() int {
+	return c.auditMaxSize
+}
+
+func (c *config) GetAuditMaxBackups() int {
+	return c.auditMaxBackups
+}
+
+func (c *config) GetAuditStoreSize() int {
+	return c.auditStoreSize
+}
+
+func (c *config) GetSseHeartbeat() time.Duration {
+	return c.sseHeartbeat
+}
+
+func (c *config) GetSseHistorySize() int {
+	return c.sseHistorySize
+}
+
+func (c *config) GetApiMaxBodySize() int64 {
+	return c.apiMaxBodySize
+}
+
+func (c *config) GetRateLimitReadRPS() float64 {
+	return c.rateLimitReadRPS
+}
+
+func (c *config) GetRateLimitReadBurst() int {
+	return c.rateLimitReadBurst
+}
+
+func (c *config) GetRateLimitWriteRPS() float64 {
+	return c.rateLimitWriteRPS
+}
+
+func (c *config) GetRateLimitWriteBurst() int {
+	return c.rateLimitWriteBurst
+}
+
+func (c *config) GetApiAllowMutatingGet() bool {
+	return c.apiAllowMutatingGet
+}
+
+func (c *config) GetAllowedOrigins() []string {
+	return c.allowedOrigins
+}
+
+func (c *config) GetIdempotencyStore() string {
+	return c.idempotencyStore
+}
+
+func (c *config) GetIdempotencyTTL() time.Duration {
+	return c.idempotencyTTL
+}
+
+func (c *config) GetIdempotencyNamespace() string {
+	return c.idempotencyNamespace
+}
+
+func (c *config) GetIdempotencyConfigMap() string {
+	return c.idempotencyConfigMap
+}
+
+func (c *config) GetBatchMaxItems() int {
+	return c.batchMaxItems
+}
+
+func (c *config) GetBatchConcurrency() int {
+	return c.batchConcurrency
+}
+
+func (c *config) GetTracingExporter() string {
+	return c.tracingExporter
+}
+
+func (c *config) GetTracingEndpoint() string {
+	return c.tracingEndpoint
+}
+
+func (c *config) GetTracingInsecure() bool {
+	return c.tracingInsecure
+}
+
+func (c *config) GetTracingFile() string {
+	return c.tracingFile
+}
+
+func (c *config) GetTracingSampleRatio() float64 {
+	return c.tracingSampleRatio
+}
+
+func (c *config) GetMetricsPort() int {
+	return c.metricsPort
+}
+
+func (c *config) GetCheckpointNamespace() string {
+	return c.checkpointNamespace
+}
+
+func (c *config) GetCheckpointConfigMap() string {
+	return c.checkpointConfigMap
+}
+
+func (c *config) GetTlsCertFile() string {
+	return c.tlsCertFile
+}
+
+func (c *config) GetTlsKeyFile() string {
+	return c.tlsKeyFile
+}
+
+func (c *config) GetTlsClientCAFile() string {
+	return c.tlsClientCAFile
+}
+
+func (c *config) GetHttpRedirectPort() int {
+	return c.httpRedirectPort
+}
+
+func (c *config) GetJobJitter() float64 {
+	return c.jobJitter
+}
+
+func (c *config) GetConfigReloadInterval() time.Duration {
+	return c.configReloadInterval
+}
+
+func (c *config) GetJwksRefreshInterval() time.Duration {
+	return c.jwksRefreshInterval
+}
+
+func (c *config) GetCredentialCheckInterval() time.Duration {
+	return c.credentialCheckInterval
+}
+
+func (c *config) GetCredentialExpiryWarning() time.Duration {
+	return c.credentialExpiryWarning
+}
+
+func (c *config) GetOrphanGCInterval() time.Duration {
+	return c.orphanGCInterval
+}
+
+func (c *config) GetWatcherNamespaces() []string {
+	return c.watcherNamespaces
+}
+
+func (c *config) GetWatcherTemplateNamespace() string {
+	return c.watcherTemplateNamespace
+}
+
+func (c *config) GetWatcherTemplateConfigMap() string {
+	return c.watcherTemplateConfigMap
+}
+
+func (c *config) GetWatcherTemplateKey() string {
+	return c.watcherTemplateKey
+}
+
+func (c *config) GetWatcherResyncPeriod() time.Duration {
+	return c.watcherResyncPeriod
+}
+
+func (c *config) GetWatcherWorkers() int {
+	return c.watcherWorkers
+}
+
+func (c *config) GetLogFormat() string {
+	return c.logFormat
+}
+
+func (c *config) GetLogLevels() []string {
+	return c.logLevels
+}
+
+func (c *config) GetLogLevelTimeout() time.Duration {
+	return c.logLevelTimeout
+}
+
+func (c *config) GetLogLevelMaxTimeout() time.Duration {
+	return c.logLevelMaxTimeout
+}
+
+func (c *config) GetRateLimitIPRPS() float64 {
+	return c.rateLimitIPRPS
+}
+
+func (c *config) GetRateLimitIPBurst() int {
+	return c.rateLimitIPBurst
+}
+
+func (c *config) GetTrustedProxies() []string {
+	return c.trustedProxies
+}
+
+func (c *config) GetWatcherServiceAccount() string {
+	return c.watcherServiceAccount
+}
+
 func (c *config) GetVersion() string {
 	return version
 }
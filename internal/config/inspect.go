@@ -0,0 +1,194 @@
+/**
+ * This work is licensed under Apache License, Version 2.0 or later.
+ * Please read and understand latest version of Licence.
+ */
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"reflect"
+	"strings"
+	"unicode"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// EnvPrefix is prepended to the environment variables of the options, like
+// APP_OIDC_ISSUER for oidcIssuer.
+const EnvPrefix = "APP"
+
+const redacted = "REDACTED"
+
+// legacyEnv are the environment variables deployments set before the options
+// got the APP_ prefix. They are read when the prefixed variable is not set.
+var legacyEnv = map[string][]string{
+	"serverPort": {"SERVER_PORT"},
+	"debug":      {"SERVER_DEBUG"},
+	"wait":       {"SERVER_WAIT"},
+	"oidcIssuer": {"OIDC_ISSUER_URL"},
+}
+
+// Sources of an option value, in order of precedence.
+const (
+	SourceFlag    = "flag"
+	SourceEnv     = "env"
+	SourceFile    = "file"
+	SourceDefault = "default"
+)
+
+// Setting is the effective value of an option and where it came from.
+type Setting struct {
+	Name   string `json:"name"`
+	Env    string `json:"env"`
+	Value  string `json:"value"`
+	Source string `json:"source"`
+}
+
+// optionNames returns the option names, the fields of config are named after
+// them.
+func optionNames() []string {
+	t := reflect.TypeOf(config{})
+
+	names := make([]string, 0, t.NumField()+1)
+	names = append(names, "config")
+
+	for i := 0; i < t.NumField(); i++ {
+		names = append(names, t.Field(i).Name)
+	}
+
+	return names
+}
+
+// EnvName returns the environment variable of an option: oidcIssuer becomes
+// APP_OIDC_ISSUER and tlsClientCAFile APP_TLS_CLIENT_CA_FILE.
+func EnvName(name string) string {
+	runes := []rune(name)
+
+	var b strings.Builder
+
+	b.WriteString(EnvPrefix)
+	b.WriteRune('_')
+
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) {
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				b.WriteRune('_')
+			}
+		}
+
+		b.WriteRune(unicode.ToUpper(r))
+	}
+
+	return b.String()
+}
+
+// envNames returns the environment variables of an option in order of
+// precedence, the prefixed one first.
+func envNames(name string) []string {
+	return append([]string{EnvName(name)}, legacyEnv[name]...)
+}
+
+// BindEnv binds every option to its prefixed environment variable and its
+// legacy ones.
+func BindEnv() {
+	for _, name := range optionNames() {
+		envs := envNames(name)
+
+		if err := viper.BindEnv(append([]string{name}, envs...)...); err != nil {
+			slog.Error("Error binding environment variable", "option", name, "error", err)
+		}
+
+		for _, env := range envs[1:] {
+			if _, ok := os.LookupEnv(env); ok {
+				slog.Warn("Deprecated environment variable", "env", env, "use", envs[0])
+			}
+		}
+	}
+}
+
+// Settings returns the effective options of the current config. flags are the
+// command line flags of the command, secrets are redacted.
+func Settings(flags *pflag.FlagSet) []Setting {
+	value := reflect.ValueOf(GetConfig().(*config)).Elem()
+
+	settings := make([]Setting, 0, value.NumField())
+
+	for i := 0; i < value.NumField(); i++ {
+		name := value.Type().Field(i).Name
+
+		settings = append(settings, Setting{
+			Name:   name,
+			Env:    EnvName(name),
+			Value:  redact(name, fmt.Sprint(value.Field(i))),
+			Source: source(flags, name),
+		})
+	}
+
+	return settings
+}
+
+func source(flags *pflag.FlagSet, name string) string {
+	if flag := flags.Lookup(name); flag != nil && flag.Changed {
+		return SourceFlag
+	}
+
+	for _, env := range envNames(name) {
+		if _, ok := os.LookupEnv(env); ok {
+			return SourceEnv
+		}
+	}
+
+	if viper.InConfig(name) {
+		return SourceFile
+	}
+
+	return SourceDefault
+}
+
+// redact hides values of secret options and credentials embedded in URLs.
+func redact(name, value string) string {
+	lower := strings.ToLower(name)
+
+	for _, secret := range []string{"secret", "password", "token"} {
+		if strings.Contains(lower, secret) && value != "" {
+			return redacted
+		}
+	}
+
+	if u, err := url.Parse(value); err == nil && u.User != nil {
+		u.User = url.User(redacted)
+		return u.String()
+	}
+
+	return value
+}
+
+// ValidateServer checks the options like Validate and the ones the server
+// cannot run without.
+func (c *config) ValidateServer() error {
+	var errs []error
+
+	if err := c.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if c.oidcIssuer == "" {
+		errs = append(errs, errors.New("oidcIssuer is required"))
+	} else if u, err := url.Parse(c.oidcIssuer); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		errs = append(errs, fmt.Errorf("oidcIssuer %q is not an http(s) URL", c.oidcIssuer))
+	}
+
+	if c.oidcAudience == "" {
+		errs = append(errs, errors.New("oidcAudience is required"))
+	}
+
+	return errors.Join(errs...)
+}
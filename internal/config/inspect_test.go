@@ -0,0 +1,117 @@
+/**
+ * This work is licensed under Apache License, Version 2.0 or later.
+ * Please read and understand latest version of Licence.
+ */
+package config
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+func TestEnvName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: "debug", want: "APP_DEBUG"},
+		{name: "serverPort", want: "APP_SERVER_PORT"},
+		{name: "oidcIssuer", want: "APP_OIDC_ISSUER"},
+		{name: "tlsClientCAFile", want: "APP_TLS_CLIENT_CA_FILE"},
+		{name: "jwksCacheTTL", want: "APP_JWKS_CACHE_TTL"},
+		{name: "kubeApiServer", want: "APP_KUBE_API_SERVER"},
+		{name: "watcherTemplateConfigMap", want: "APP_WATCHER_TEMPLATE_CONFIG_MAP"},
+		{name: "s3Bucket", want: "APP_S3_BUCKET"},
+		{name: "s3ABucket", want: "APP_S3_A_BUCKET"},
+	}
+
+	for _, tt := range tests {
+		if got := EnvName(tt.name); got != tt.want {
+			t.Errorf("EnvName(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRedact(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{name: "oidcClientSecret", value: "s3cr3t", want: redacted},
+		{name: "dbPassword", value: "pw", want: redacted},
+		{name: "apiToken", value: "t", want: redacted},
+		{name: "apiToken", value: "", want: ""},
+		{name: "tracingEndpoint", value: "https://user:pw@collector:4318/v1/traces", want: "https://REDACTED@collector:4318/v1/traces"},
+		{name: "oidcIssuer", value: "https://keycloak/realms/master", want: "https://keycloak/realms/master"},
+		{name: "tracingEndpoint", value: "localhost:4318", want: "localhost:4318"},
+		{name: "auditFile", value: "/var/log/audit.log", want: "/var/log/audit.log"},
+	}
+
+	for _, tt := range tests {
+		if got := redact(tt.name, tt.value); got != tt.want {
+			t.Errorf("redact(%q, %q) = %q, want %q", tt.name, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestSource(t *testing.T) {
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	flags.Int("serverPort", 0, "")
+	flags.Int("metricsPort", 0, "")
+	flags.String("auditFile", "", "")
+
+	if err := flags.Parse([]string{"--serverPort", "8080"}); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("APP_SERVER_PORT", "8081")
+	t.Setenv("APP_WAIT", "5s")
+	t.Setenv("SERVER_DEBUG", "true")
+
+	viper.SetConfigType("yaml")
+	t.Cleanup(viper.Reset)
+
+	if err := viper.ReadConfig(strings.NewReader("auditFile: /var/log/audit.log\nwait: 1s\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: "serverPort", want: SourceFlag},
+		{name: "wait", want: SourceEnv},
+		{name: "debug", want: SourceEnv},
+		{name: "auditFile", want: SourceFile},
+		{name: "metricsPort", want: SourceDefault},
+		{name: "oidcIssuer", want: SourceDefault},
+	}
+
+	for _, tt := range tests {
+		if got := source(flags, tt.name); got != tt.want {
+			t.Errorf("source(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestBindEnvLegacy(t *testing.T) {
+	t.Cleanup(viper.Reset)
+
+	t.Setenv("SERVER_PORT", "8082")
+	t.Setenv("OIDC_ISSUER_URL", "https://legacy")
+	t.Setenv("APP_OIDC_ISSUER", "https://current")
+
+	BindEnv()
+
+	if got := viper.GetInt("serverPort"); got != 8082 {
+		t.Errorf("serverPort = %d, want 8082 from SERVER_PORT", got)
+	}
+
+	if got := viper.GetString("oidcIssuer"); got != "https://current" {
+		t.Errorf("oidcIssuer = %q, want the APP_OIDC_ISSUER value", got)
+	}
+}
@@ -0,0 +1,262 @@
+/**
+ * This work is licensed under Apache License, Version 2.0 or later.
+ * Please read and understand latest version of Licence.
+ */
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/kazimsarikaya/assesmentbarkinrl/internal/logger"
+	"github.com/spf13/viper"
+)
+
+// Listener is notified after a reload changed options. changed holds the
+// option names, like "oidcAudience".
+type Listener func(old, new Config, changed []string)
+
+var (
+	reloadMu  sync.Mutex
+	listeners []Listener
+	// validate checks reloaded configs, see SetValidation
+	validate = Config.Validate
+)
+
+// restartKeys are options read once at startup, changing them has no effect
+// until a restart.
+var restartKeys = []string{
+	"serverPort", "metricsPort", "httpRedirectPort",
+	"tlsCertFile", "tlsKeyFile", "tlsClientCAFile",
+	"localStaticPath", "kubeCAFile", "kubeApiServer",
+	"auditSink", "auditFile", "auditMaxSize", "auditMaxBackups", "auditStoreSize",
+	"idempotencyStore", "idempotencyNamespace", "idempotencyConfigMap",
+	"tracingExporter", "tracingEndpoint", "tracingInsecure", "tracingFile", "tracingSampleRatio",
+	"checkpointNamespace", "checkpointConfigMap",
+	"watcherNamespaces", "watcherResyncPeriod", "watcherWorkers",
+	"logFormat",
+}
+
+// Subscribe adds a listener for config changes.
+func Subscribe(listener Listener) {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	listeners = append(listeners, listener)
+}
+
+// SetValidation sets the check of reloaded configs. It must be the check the
+// command validated its startup config with, like Config.ValidateServer for
+// the server, so a reload cannot publish a config the command would not start
+// with. Config.Validate is used by default.
+func SetValidation(check func(Config) error) {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	validate = check
+}
+
+// reloadDelay groups the file events of one write, editors truncate the file
+// before writing it.
+const reloadDelay = 500 * time.Millisecond
+
+// WatchConfigFile reloads the config whenever the config file changes, until
+// ctx is done. The directory is watched, a mounted ConfigMap is replaced by
+// swapping a symlink.
+func WatchConfigFile(ctx context.Context) error {
+	file := viper.ConfigFileUsed()
+	if file == "" {
+		return nil
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+
+	if err := watcher.Add(filepath.Dir(file)); err != nil {
+		watcher.Close()
+		return fmt.Errorf("failed to watch %s: %w", filepath.Dir(file), err)
+	}
+
+	go func() {
+		defer watcher.Close()
+
+		var reload <-chan time.Time
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+
+				// other files of the directory, except the symlink swapped
+				// on ConfigMap updates, are not of interest
+				if filepath.Clean(event.Name) != filepath.Clean(file) && filepath.Base(event.Name) != "..data" {
+					continue
+				}
+
+				slog.Debug("Config file event", "name", event.Name, "op", event.Op.String())
+
+				reload = time.After(reloadDelay)
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+
+				slog.Error("Error watching config file", "error", err)
+			case <-reload:
+				if err := Reload(); err != nil {
+					slog.Error("Config not reloaded", "error", err)
+				}
+			}
+		}
+	}()
+
+	slog.Info("Watching config file", "file", file)
+
+	return nil
+}
+
+// Reload reads the config file again and publishes the new options when they
+// are valid. The current options are kept otherwise.
+func Reload() error {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	if viper.ConfigFileUsed() != "" {
+		if err := viper.ReadInConfig(); err != nil {
+			return err
+		}
+	}
+
+	next := &config{}
+	next.load()
+
+	if err := validate(next); err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
+
+	old := GetConfig().(*config)
+
+	changed := diff(old, next)
+	if len(changed) == 0 {
+		return nil
+	}
+
+	current.Store(next)
+
+	slog.Info("Config reloaded", "changed", changed)
+
+	for _, key := range changed {
+		if slices.Contains(restartKeys, key) {
+			slog.Warn("Config option changed, it applies after a restart", "option", key)
+		}
+	}
+
+	for _, listener := range listeners {
+		listener(old, next, changed)
+	}
+
+	return nil
+}
+
+// diff returns the names of the options that differ. Fields are named after
+// their options.
+func diff(old, next *config) []string {
+	oldValue := reflect.ValueOf(old).Elem()
+	nextValue := reflect.ValueOf(next).Elem()
+
+	changed := []string{}
+
+	for i := 0; i < oldValue.NumField(); i++ {
+		if fmt.Sprint(oldValue.Field(i)) != fmt.Sprint(nextValue.Field(i)) {
+			changed = append(changed, oldValue.Type().Field(i).Name)
+		}
+	}
+
+	return changed
+}
+
+// Validate checks the options, all problems are reported at once.
+func (c *config) Validate() error {
+	var errs []error
+
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	validPort := func(port int) bool { return port >= 0 && port <= 65535 }
+
+	check(validPort(c.serverPort) && c.serverPort != 0, "serverPort %d is not a valid port", c.serverPort)
+	check(validPort(c.metricsPort), "metricsPort %d is not a valid port", c.metricsPort)
+	check(validPort(c.httpRedirectPort), "httpRedirectPort %d is not a valid port", c.httpRedirectPort)
+	check(c.wait >= 0, "wait must not be negative")
+
+	check((c.tlsCertFile == "") == (c.tlsKeyFile == ""), "tlsCertFile and tlsKeyFile must be set together")
+	check(c.tlsClientCAFile == "" || c.tlsCertFile != "", "tlsClientCAFile needs tlsCertFile")
+	check(c.httpRedirectPort == 0 || c.tlsCertFile != "", "httpRedirectPort needs tlsCertFile")
+
+	for _, alg := range c.oidcAllowedAlgs {
+		check(slices.Contains(supportedAlgs, alg), "oidcAllowedAlgs: unsupported algorithm %q", alg)
+	}
+
+	check(c.jwksCacheTTL >= 0 && c.jwksStaleTTL >= 0 && c.jwksMinRefreshInterval >= 0, "jwks durations must not be negative")
+
+	check(slices.Contains([]string{"", "stdout", "file", "none"}, c.auditSink), "auditSink %q is not one of stdout, file or none", c.auditSink)
+	check(c.auditSink != "file" || c.auditFile != "", "auditFile is required with the file audit sink")
+	check(slices.Contains([]string{"", "memory", "configmap", "none"}, c.idempotencyStore), "idempotencyStore %q is not one of memory, configmap or none", c.idempotencyStore)
+	check(slices.Contains([]string{"none", "otlp", "file"}, c.tracingExporter), "tracingExporter %q is not one of none, otlp or file", c.tracingExporter)
+
+	check(c.tracingSampleRatio >= 0 && c.tracingSampleRatio <= 1, "tracingSampleRatio must be between 0 and 1")
+	check(c.jobJitter >= 0 && c.jobJitter <= 1, "jobJitter must be between 0 and 1")
+
+	check(c.apiMaxBodySize > 0, "apiMaxBodySize must be positive")
+	check(c.rateLimitReadBurst >= 0 && c.rateLimitWriteBurst >= 0 && c.rateLimitIPBurst >= 0, "rate limit bursts must not be negative")
+	for _, cidr := range c.trustedProxies {
+		_, _, err := net.ParseCIDR(cidr)
+		check(err == nil, "trustedProxies: %q is not a CIDR", cidr)
+	}
+
+	check(c.batchMaxItems > 0, "batchMaxItems must be positive")
+	check(c.batchConcurrency > 0, "batchConcurrency must be positive")
+	check(c.sseHeartbeat > 0, "sseHeartbeat must be positive")
+	check(c.sseHistorySize >= 0, "sseHistorySize must not be negative")
+
+	check(slices.Contains(logger.Formats, c.logFormat), "logFormat %q is not one of %s", c.logFormat, strings.Join(logger.Formats, ", "))
+
+	if _, err := logger.ParseModuleLevels(c.logLevels); err != nil {
+		errs = append(errs, fmt.Errorf("logLevels: %w", err))
+	}
+
+	check(c.logLevelTimeout > 0 && c.logLevelTimeout <= c.logLevelMaxTimeout, "logLevelTimeout must be positive and at most logLevelMaxTimeout")
+
+	check(c.watcherWorkers > 0, "watcherWorkers must be positive")
+	check(c.watcherResyncPeriod >= 0, "watcherResyncPeriod must not be negative")
+	check(c.watcherTemplateNamespace != "" && c.watcherTemplateConfigMap != "" && c.watcherTemplateKey != "",
+		"watcherTemplateNamespace, watcherTemplateConfigMap and watcherTemplateKey are required")
+	check(c.watcherServiceAccount != "", "watcherServiceAccount is required")
+
+	check(c.configReloadInterval >= 0 && c.jwksRefreshInterval >= 0 && c.credentialCheckInterval >= 0 && c.orphanGCInterval >= 0,
+		"job intervals must not be negative")
+
+	return errors.Join(errs...)
+}
+
+// supportedAlgs are the token signing algorithms the server can verify.
+var supportedAlgs = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}
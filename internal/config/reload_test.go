@@ -0,0 +1,294 @@
+/**
+ * This work is licensed under Apache License, Version 2.0 or later.
+ * Please read and understand latest version of Licence.
+ */
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+// baseConfig passes Validate.
+const baseConfig = `
+serverPort: 8080
+oidcAudience: a
+logFormat: json
+logLevelTimeout: 10m
+logLevelMaxTimeout: 1h
+watcherWorkers: 1
+watcherTemplateNamespace: default
+watcherTemplateConfigMap: cluster-template
+watcherTemplateKey: template
+watcherServiceAccount: default
+apiMaxBodySize: 1048576
+batchMaxItems: 10
+batchConcurrency: 2
+sseHeartbeat: 15s
+tracingExporter: none
+`
+
+// configWith returns baseConfig with options replaced or added, like
+// configWith("serverPort: 9090").
+func configWith(options ...string) string {
+	lines := strings.Split(strings.TrimSpace(baseConfig), "\n")
+
+	for _, option := range options {
+		key, _, _ := strings.Cut(option, ":")
+
+		i := slices.IndexFunc(lines, func(line string) bool { return strings.HasPrefix(line, key+":") })
+		if i < 0 {
+			lines = append(lines, option)
+		} else {
+			lines[i] = option
+		}
+	}
+
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// setupReload loads content from a config file and restores the package
+// state when the test ends. It returns the path of the file.
+func setupReload(t *testing.T, content string) string {
+	t.Helper()
+
+	previous := current.Load()
+	previousListeners := listeners
+	previousValidate := validate
+
+	t.Cleanup(func() {
+		viper.Reset()
+		current.Store(previous)
+		listeners = previousListeners
+		validate = previousValidate
+	})
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfig(t, path, content)
+
+	viper.SetConfigFile(path)
+
+	if err := viper.ReadInConfig(); err != nil {
+		t.Fatal(err)
+	}
+
+	getConfigSingleton().SyncConfig()
+
+	return path
+}
+
+func writeConfig(t *testing.T, path, content string) {
+	t.Helper()
+
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// captureWarnings returns the options of the restart warnings logged until
+// the test ends.
+func captureWarnings(t *testing.T) func() []string {
+	t.Helper()
+
+	var buf bytes.Buffer
+
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, nil)))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+
+	return func() []string {
+		options := []string{}
+
+		for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+			var record struct {
+				Level  string `json:"level"`
+				Option string `json:"option"`
+			}
+
+			if json.Unmarshal([]byte(line), &record) == nil && record.Level == "WARN" && record.Option != "" {
+				options = append(options, record.Option)
+			}
+		}
+
+		return options
+	}
+}
+
+func TestReload(t *testing.T) {
+	tests := []struct {
+		name         string
+		content      string
+		wantErr      bool
+		wantChanged  []string
+		wantWarnings []string
+		wantAudience string
+	}{
+		{
+			name:         "unchanged",
+			content:      baseConfig,
+			wantAudience: "a",
+		},
+		{
+			name:         "reloadable option",
+			content:      configWith("oidcAudience: b"),
+			wantChanged:  []string{"oidcAudience"},
+			wantWarnings: []string{},
+			wantAudience: "b",
+		},
+		{
+			name:         "restart only options",
+			content:      configWith("oidcAudience: b", "metricsPort: 9090", "logFormat: logfmt"),
+			wantChanged:  []string{"oidcAudience", "metricsPort", "logFormat"},
+			wantWarnings: []string{"metricsPort", "logFormat"},
+			wantAudience: "b",
+		},
+		{
+			name:         "list option",
+			content:      configWith("trustedProxies: [10.0.0.0/8]"),
+			wantChanged:  []string{"trustedProxies"},
+			wantWarnings: []string{},
+			wantAudience: "a",
+		},
+		{
+			name:         "invalid",
+			content:      configWith("oidcAudience: b", "logFormat: xml", "trustedProxies: [10.0.0.1]"),
+			wantErr:      true,
+			wantAudience: "a",
+		},
+		{
+			name:         "unreadable",
+			content:      "serverPort: [",
+			wantErr:      true,
+			wantAudience: "a",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := setupReload(t, baseConfig)
+			warnings := captureWarnings(t)
+
+			if err := GetConfig().Validate(); err != nil {
+				t.Fatalf("base config is invalid: %v", err)
+			}
+
+			before := GetConfig()
+
+			var changed []string
+
+			calls := 0
+
+			Subscribe(func(old, next Config, keys []string) {
+				calls++
+				changed = keys
+
+				if old != before || next != GetConfig() {
+					t.Error("listener got the wrong snapshots")
+				}
+			})
+
+			writeConfig(t, path, tt.content)
+
+			err := Reload()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Reload error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if got := GetConfig().GetOidcAudience(); got != tt.wantAudience {
+				t.Fatalf("oidcAudience = %q, want %q", got, tt.wantAudience)
+			}
+
+			if tt.wantChanged == nil {
+				// rejected and unchanged configs keep the snapshot
+				if calls != 0 || GetConfig() != before {
+					t.Fatalf("snapshot replaced, %d listener calls", calls)
+				}
+
+				return
+			}
+
+			if calls != 1 {
+				t.Fatalf("listener calls = %d, want 1", calls)
+			}
+
+			slices.Sort(changed)
+			slices.Sort(tt.wantChanged)
+
+			if !slices.Equal(changed, tt.wantChanged) {
+				t.Fatalf("changed = %v, want %v", changed, tt.wantChanged)
+			}
+
+			got := warnings()
+			slices.Sort(got)
+			slices.Sort(tt.wantWarnings)
+
+			if !slices.Equal(got, tt.wantWarnings) {
+				t.Fatalf("restart warnings = %v, want %v", got, tt.wantWarnings)
+			}
+		})
+	}
+}
+
+func TestReloadValidation(t *testing.T) {
+	path := setupReload(t, baseConfig)
+
+	// the check set by the command is used instead of Validate
+	SetValidation(func(c Config) error {
+		if c.GetOidcIssuer() == "" {
+			return os.ErrInvalid
+		}
+
+		return nil
+	})
+
+	writeConfig(t, path, configWith("oidcAudience: b"))
+
+	if err := Reload(); err == nil {
+		t.Fatal("config without issuer reloaded")
+	}
+
+	writeConfig(t, path, configWith("oidcAudience: b", "oidcIssuer: https://issuer"))
+
+	if err := Reload(); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+
+	if got := GetConfig().GetOidcAudience(); got != "b" {
+		t.Fatalf("oidcAudience = %q, want b", got)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		old  *config
+		next *config
+		want []string
+	}{
+		{name: "equal", old: &config{serverPort: 1}, next: &config{serverPort: 1}, want: []string{}},
+		{name: "scalar", old: &config{serverPort: 1}, next: &config{serverPort: 2}, want: []string{"serverPort"}},
+		{
+			name: "lists",
+			old:  &config{allowedOrigins: []string{"https://a"}, trustedProxies: []string{}},
+			next: &config{allowedOrigins: []string{"https://a", "https://b"}},
+			want: []string{"allowedOrigins"},
+		},
+		{name: "several", old: &config{}, next: &config{debug: true, oidcIssuer: "https://issuer"}, want: []string{"debug", "oidcIssuer"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := diff(tt.old, tt.next); !slices.Equal(got, tt.want) {
+				t.Fatalf("diff = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
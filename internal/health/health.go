@@ -0,0 +1,202 @@
+/**
+ * This work is licensed under Apache License, Version 2.0 or later.
+ * Please read and understand latest version of Licence.
+ */
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const checkTimeout = 5 * time.Second
+
+const (
+	StatusOK     = "ok"
+	StatusFailed = "failed"
+)
+
+// Check is a named probe, a nil error means healthy.
+type Check struct {
+	Name  string
+	Check func(ctx context.Context) error
+}
+
+// Result is the outcome of a single check.
+type Result struct {
+	Name     string  `json:"name"`
+	Status   string  `json:"status"`
+	Error    string  `json:"error,omitempty"`
+	Duration float64 `json:"duration_ms"`
+}
+
+// Report is the body of a probe response.
+type Report struct {
+	Status string   `json:"status"`
+	Checks []Result `json:"checks"`
+}
+
+// Checker keeps the liveness and readiness checks of a process.
+type Checker struct {
+	mu        sync.RWMutex
+	liveness  []Check
+	readiness []Check
+	status    []Check
+	draining  atomic.Bool
+}
+
+// NewChecker returns a checker whose readiness fails once Drain is called.
+func NewChecker() *Checker {
+	c := &Checker{}
+	c.readiness = []Check{{Name: "shutdown", Check: c.checkDraining}}
+
+	return c
+}
+
+// Drain takes the process out of service while it shuts down.
+func (c *Checker) Drain() {
+	c.draining.Store(true)
+}
+
+func (c *Checker) checkDraining(context.Context) error {
+	if c.draining.Load() {
+		return errors.New("shutting down")
+	}
+
+	return nil
+}
+
+// AddLiveness adds a check that restarts the process when it fails.
+func (c *Checker) AddLiveness(check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.liveness = append(c.liveness, check)
+}
+
+// AddReadiness adds a check that takes the process out of service when it
+// fails.
+func (c *Checker) AddReadiness(check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.readiness = append(c.readiness, check)
+}
+
+// run runs the checks concurrently, each bounded by checkTimeout.
+func run(ctx context.Context, checks []Check) Report {
+	report := Report{Status: StatusOK, Checks: make([]Result, len(checks))}
+
+	var wg sync.WaitGroup
+
+	for i, check := range checks {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			checkCtx, cancel := context.WithTimeout(ctx, checkTimeout)
+			defer cancel()
+
+			start := time.Now()
+			err := check.Check(checkCtx)
+
+			result := Result{
+				Name:     check.Name,
+				Status:   StatusOK,
+				Duration: float64(time.Since(start).Microseconds()) / 1000,
+			}
+
+			if err != nil {
+				result.Status = StatusFailed
+				result.Error = err.Error()
+			}
+
+			report.Checks[i] = result
+		}()
+	}
+
+	wg.Wait()
+
+	for _, result := range report.Checks {
+		if result.Status != StatusOK {
+			report.Status = StatusFailed
+			break
+		}
+	}
+
+	return report
+}
+
+// AddStatus adds a check that is only reported by /healthz, for problems that
+// need attention but neither a restart nor taking the process out of service.
+func (c *Checker) AddStatus(check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.status = append(c.status, check)
+}
+
+func (c *Checker) handler(checks func() []Check) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		report := run(r.Context(), checks())
+
+		status := http.StatusOK
+		if report.Status != StatusOK {
+			status = http.StatusServiceUnavailable
+			slog.WarnContext(r.Context(), "Health check failed", "path", r.URL.Path, "checks", report.Checks)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+		w.WriteHeader(status)
+
+		if err := json.NewEncoder(w).Encode(report); err != nil {
+			slog.ErrorContext(r.Context(), "Error encoding response", "error", err)
+		}
+	}
+}
+
+func (c *Checker) livenessChecks() []Check {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return append([]Check{}, c.liveness...)
+}
+
+func (c *Checker) readinessChecks() []Check {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return append([]Check{}, c.readiness...)
+}
+
+func (c *Checker) allChecks() []Check {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	checks := append([]Check{}, c.liveness...)
+	checks = append(checks, c.readiness...)
+
+	return append(checks, c.status...)
+}
+
+// Routes returns the probe handlers: /livez runs the liveness checks,
+// /readyz the readiness checks and /healthz all of them, status checks
+// included.
+func (c *Checker) Routes() map[string]http.Handler {
+	return map[string]http.Handler{
+		"/livez":   c.handler(c.livenessChecks),
+		"/readyz":  c.handler(c.readinessChecks),
+		"/healthz": c.handler(c.allChecks),
+	}
+}
+
+// Ping is a liveness check that only proves the process serves requests.
+var Ping = Check{Name: "ping", Check: func(context.Context) error { return nil }}
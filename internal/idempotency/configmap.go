@@ -0,0 +1,162 @@
+/**
+ * This work is licensed under Apache License, Version 2.0 or later.
+ * Please read and understand latest version of Licence.
+ */
+package idempotency
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+)
+
+// ConfigMapStore keeps entries in a single ConfigMap so they are shared by
+// all replicas and survive restarts. ConfigMaps are limited to 1MiB, the
+// entries are kept within configMapDataLimit by evicting the entries closest
+// to expiry, so under load keys may be forgotten before the ttl. Entries above
+// maxConfigMapEntrySize are not stored.
+type ConfigMapStore struct {
+	client    kubernetes.Interface
+	namespace string
+	name      string
+}
+
+const (
+	// configMapDataLimit is the budget of the entries, below the 1MiB limit
+	// of a ConfigMap to leave room for its metadata
+	configMapDataLimit = 900 * 1024
+	// maxConfigMapEntrySize bounds an entry so one response cannot evict
+	// all others
+	maxConfigMapEntrySize = configMapDataLimit / 8
+)
+
+// ErrEntryTooLarge is returned by ConfigMapStore.Put for responses above
+// maxConfigMapEntrySize, their requests are not idempotent.
+var ErrEntryTooLarge = errors.New("idempotency entry is too large for the ConfigMap store")
+
+func NewConfigMapStore(client kubernetes.Interface, namespace, name string) *ConfigMapStore {
+	return &ConfigMapStore{client: client, namespace: namespace, name: name}
+}
+
+func (s *ConfigMapStore) Get(ctx context.Context, key string) (*Entry, error) {
+	cm, err := s.client.CoreV1().ConfigMaps(s.namespace).Get(ctx, s.name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	raw, ok := cm.Data[key]
+	if !ok {
+		return nil, nil
+	}
+
+	entry := &Entry{}
+	if err := json.Unmarshal([]byte(raw), entry); err != nil {
+		return nil, err
+	}
+
+	if entry.expired(time.Now()) {
+		return nil, nil
+	}
+
+	return entry, nil
+}
+
+func (s *ConfigMapStore) Put(ctx context.Context, key string, entry *Entry) error {
+	raw, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	if len(key)+len(raw) > maxConfigMapEntrySize {
+		return ErrEntryTooLarge
+	}
+
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		configMaps := s.client.CoreV1().ConfigMaps(s.namespace)
+
+		cm, err := configMaps.Get(ctx, s.name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			cm = &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: s.name, Namespace: s.namespace},
+				Data:       map[string]string{key: string(raw)},
+			}
+
+			_, err = configMaps.Create(ctx, cm, metav1.CreateOptions{})
+			if apierrors.IsAlreadyExists(err) {
+				return apierrors.NewConflict(corev1.Resource("configmaps"), s.name, err)
+			}
+
+			return err
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if cm.Data == nil {
+			cm.Data = map[string]string{}
+		}
+
+		fitEntries(cm.Data, key, string(raw), time.Now(), configMapDataLimit)
+
+		_, err = configMaps.Update(ctx, cm, metav1.UpdateOptions{})
+
+		return err
+	})
+}
+
+// fitEntries adds the entry to data after dropping the expired entries and,
+// while the entries exceed limit bytes, the entries expiring first.
+func fitEntries(data map[string]string, key, raw string, now time.Time, limit int) {
+	delete(data, key)
+
+	type stored struct {
+		key     string
+		size    int
+		expires time.Time
+	}
+
+	var entries []stored
+
+	size := len(key) + len(raw)
+
+	for k, v := range data {
+		old := &Entry{}
+		if json.Unmarshal([]byte(v), old) != nil || old.expired(now) {
+			delete(data, k)
+			continue
+		}
+
+		entries = append(entries, stored{key: k, size: len(k) + len(v), expires: old.Expires})
+		size += len(k) + len(v)
+	}
+
+	if size > limit {
+		slices.SortFunc(entries, func(a, b stored) int {
+			return a.expires.Compare(b.expires)
+		})
+
+		for _, e := range entries {
+			if size <= limit {
+				break
+			}
+
+			delete(data, e.key)
+			size -= e.size
+		}
+	}
+
+	data[key] = raw
+}
@@ -0,0 +1,131 @@
+/**
+ * This work is licensed under Apache License, Version 2.0 or later.
+ * Please read and understand latest version of Licence.
+ */
+package idempotency
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Entry is the first response of a request made with an idempotency key.
+type Entry struct {
+	PayloadDigest string      `json:"payload_digest"`
+	Status        int         `json:"status"`
+	Header        http.Header `json:"header,omitempty"`
+	Body          []byte      `json:"body,omitempty"`
+	Expires       time.Time   `json:"expires"`
+}
+
+func (e *Entry) expired(now time.Time) bool {
+	return !e.Expires.IsZero() && now.After(e.Expires)
+}
+
+// Store keeps entries until they expire.
+type Store interface {
+	// Get returns the entry of the key, nil when there is none.
+	Get(ctx context.Context, key string) (*Entry, error)
+	Put(ctx context.Context, key string, entry *Entry) error
+}
+
+// Key scopes a client supplied key to the caller and hashes it so it can be
+// used as a map or ConfigMap key.
+func Key(scope, key string) string {
+	sum := sha256.Sum256([]byte(scope + "\x00" + key))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// MemoryStore keeps entries in the process.
+type MemoryStore struct {
+	mu        sync.Mutex
+	entries   map[string]*Entry
+	lastSweep time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: map[string]*Entry{}}
+}
+
+func (s *MemoryStore) Get(_ context.Context, key string) (*Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[key]
+	if !ok || entry.expired(time.Now()) {
+		return nil, nil
+	}
+
+	return entry, nil
+}
+
+func (s *MemoryStore) Put(_ context.Context, key string, entry *Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+
+	if now.Sub(s.lastSweep) > time.Minute {
+		s.lastSweep = now
+
+		for k, e := range s.entries {
+			if e.expired(now) {
+				delete(s.entries, k)
+			}
+		}
+	}
+
+	s.entries[key] = entry
+
+	return nil
+}
+
+// Locker serializes requests with the same key inside the process, so a
+// duplicate waits for the first request instead of running concurrently.
+type Locker struct {
+	mu    sync.Mutex
+	locks map[string]*keyLock
+}
+
+type keyLock struct {
+	mu   sync.Mutex
+	refs int
+}
+
+func NewLocker() *Locker {
+	return &Locker{locks: map[string]*keyLock{}}
+}
+
+// Lock locks the key and returns the function that unlocks it.
+func (l *Locker) Lock(key string) func() {
+	l.mu.Lock()
+
+	lock, ok := l.locks[key]
+	if !ok {
+		lock = &keyLock{}
+		l.locks[key] = lock
+	}
+
+	lock.refs++
+
+	l.mu.Unlock()
+
+	lock.mu.Lock()
+
+	return func() {
+		lock.mu.Unlock()
+
+		l.mu.Lock()
+		defer l.mu.Unlock()
+
+		lock.refs--
+		if lock.refs == 0 {
+			delete(l.locks, key)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+/**
+ * This work is licensed under Apache License, Version 2.0 or later.
+ * Please read and understand latest version of Licence.
+ */
+package idempotency
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func rawEntry(t *testing.T, expires time.Time, bodySize int) string {
+	t.Helper()
+
+	raw, err := json.Marshal(&Entry{Status: 200, Body: []byte(strings.Repeat("x", bodySize)), Expires: expires})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(raw)
+}
+
+func keys(data map[string]string) []string {
+	result := []string{}
+	for k := range data {
+		result = append(result, k)
+	}
+
+	slices.Sort(result)
+
+	return result
+}
+
+func TestFitEntries(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	entry := func(expiresIn time.Duration) string {
+		return rawEntry(t, now.Add(expiresIn), 100)
+	}
+
+	// every entry takes len(key) + len(entry) bytes, "new" two more
+	size := len("a") + len(entry(time.Hour))
+
+	tests := []struct {
+		name  string
+		data  map[string]string
+		limit int
+		want  []string
+	}{
+		{
+			name:  "fits",
+			data:  map[string]string{"a": entry(time.Hour), "b": entry(time.Hour)},
+			limit: 10 * size,
+			want:  []string{"a", "b", "new"},
+		},
+		{
+			name:  "expired and malformed entries are dropped",
+			data:  map[string]string{"a": entry(-time.Minute), "b": "{", "c": entry(time.Hour)},
+			limit: 10 * size,
+			want:  []string{"c", "new"},
+		},
+		{
+			name:  "entries expiring first are evicted",
+			data:  map[string]string{"a": entry(3 * time.Hour), "b": entry(time.Hour), "c": entry(2 * time.Hour)},
+			limit: 3*size + 2,
+			want:  []string{"a", "c", "new"},
+		},
+		{
+			name:  "all older entries are evicted",
+			data:  map[string]string{"a": entry(time.Hour), "b": entry(time.Hour)},
+			limit: size + 2,
+			want:  []string{"new"},
+		},
+		{
+			name:  "same key is replaced",
+			data:  map[string]string{"new": entry(time.Hour), "a": entry(time.Hour)},
+			limit: 2*size + 2,
+			want:  []string{"a", "new"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fitEntries(tt.data, "new", entry(time.Hour), now, tt.limit)
+
+			if got := keys(tt.data); !slices.Equal(got, tt.want) {
+				t.Fatalf("keys = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfigMapStoreEntryTooLarge(t *testing.T) {
+	s := NewConfigMapStore(nil, "default", "idempotency")
+
+	// rejected before the client is used
+	err := s.Put(context.Background(), "key", &Entry{Body: make([]byte, maxConfigMapEntrySize)})
+	if err != ErrEntryTooLarge {
+		t.Fatalf("error = %v, want %v", err, ErrEntryTooLarge)
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore()
+
+	if entry, err := s.Get(ctx, "missing"); entry != nil || err != nil {
+		t.Fatalf("Get(missing) = %v, %v", entry, err)
+	}
+
+	if err := s.Put(ctx, "live", &Entry{Status: 201, Expires: time.Now().Add(time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Put(ctx, "expired", &Entry{Status: 201, Expires: time.Now().Add(-time.Second)}); err != nil {
+		t.Fatal(err)
+	}
+
+	if entry, _ := s.Get(ctx, "live"); entry == nil || entry.Status != 201 {
+		t.Fatalf("Get(live) = %v", entry)
+	}
+
+	if entry, _ := s.Get(ctx, "expired"); entry != nil {
+		t.Fatalf("Get(expired) = %v, want nil", entry)
+	}
+}
+
+func TestKeyScopes(t *testing.T) {
+	if Key("alice", "k") == Key("bob", "k") {
+		t.Fatal("same key of two scopes collides")
+	}
+
+	// the separator keeps the scope and the key apart
+	if Key("a", "bk") == Key("ab", "k") {
+		t.Fatal("scope and key are not separated")
+	}
+}
+
+func TestLocker(t *testing.T) {
+	l := NewLocker()
+
+	unlock := l.Lock("k")
+
+	acquired := make(chan struct{})
+
+	go func() {
+		defer l.Lock("k")()
+		close(acquired)
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("second Lock did not wait")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// other keys are not blocked
+	l.Lock("other")()
+
+	unlock()
+	<-acquired
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// the deferred unlock may still run
+	if len(l.locks) > 1 {
+		t.Fatalf("locks = %d, want released", len(l.locks))
+	}
+}
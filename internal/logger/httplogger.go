@@ -5,13 +5,86 @@
 package logger
 
 import (
+	"context"
 	"io"
 	"log/slog"
 	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
 
 	"github.com/gorilla/handlers"
 )
 
+// requestLogInfo carries values discovered while a request is handled, such as
+// the authenticated username, back to the access log formatter.
+type requestLogInfo struct {
+	mu       sync.Mutex
+	username string
+}
+
+type requestLogInfoKey struct{}
+
+// secretQueryParams are query parameters carrying credentials, like the
+// bearer token of EventSource clients, which cannot set headers.
+var secretQueryParams = []string{"access_token", "id_token"}
+
+// redactURI hides the values of secretQueryParams in a request URI.
+func redactURI(uri string) string {
+	path, rawQuery, ok := strings.Cut(uri, "?")
+	if !ok {
+		return uri
+	}
+
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		// not parsable, it may still contain a token
+		return path + "?REDACTED"
+	}
+
+	redacted := false
+
+	for _, param := range secretQueryParams {
+		if query.Has(param) {
+			query.Set(param, "REDACTED")
+			redacted = true
+		}
+	}
+
+	if !redacted {
+		return uri
+	}
+
+	return path + "?" + query.Encode()
+}
+
+func getRequestLogInfo(ctx context.Context) *requestLogInfo {
+	info, _ := ctx.Value(requestLogInfoKey{}).(*requestLogInfo)
+
+	return info
+}
+
+// SetRequestUsername records the authenticated username for the access log.
+func SetRequestUsername(ctx context.Context, username string) {
+	if info := getRequestLogInfo(ctx); info != nil {
+		info.mu.Lock()
+		info.username = username
+		info.mu.Unlock()
+	}
+}
+
+// HttpLoggingHandler wraps next with the access logger. The request context is
+// prepared before logging so that handlers can report the username.
+func HttpLoggingHandler(out io.Writer, next http.Handler) http.Handler {
+	lh := handlers.CustomLoggingHandler(out, next, HttpLogFormater)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), requestLogInfoKey{}, &requestLogInfo{})
+		lh.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
 func HttpLogFormater(writer io.Writer, params handlers.LogFormatterParams) {
 	req := params.Request
 
@@ -22,6 +95,14 @@ func HttpLogFormater(writer io.Writer, params handlers.LogFormatterParams) {
 		}
 	}
 
+	if info := getRequestLogInfo(req.Context()); info != nil {
+		info.mu.Lock()
+		if info.username != "" {
+			username = info.username
+		}
+		info.mu.Unlock()
+	}
+
 	host, _, err := net.SplitHostPort(req.RemoteAddr)
 	if err != nil {
 		host = req.RemoteAddr
@@ -39,7 +120,10 @@ func HttpLogFormater(writer io.Writer, params handlers.LogFormatterParams) {
 		uri = params.URL.RequestURI()
 	}
 
-	slog.Info("http request",
+	uri = redactURI(uri)
+
+	// request_id and trace_id are added by the handler from the context
+	slog.InfoContext(req.Context(), "http request",
 		"host", host,
 		"username", username,
 		"timestamp", params.TimeStamp.UTC().Format("02/Jan/2006:15:04:05 -0700"),
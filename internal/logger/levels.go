@@ -0,0 +1,281 @@
+/**
+ * This work is licensed under Apache License, Version 2.0 or later.
+ * Please read and understand latest version of Licence.
+ */
+package logger
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"runtime"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultModule names the level of the modules without an own level.
+const DefaultModule = "default"
+
+// Modules are the packages of the process logging through slog, see
+// moduleOf. Other modules can only be configured with logLevels.
+var Modules = []string{"main", "audit", "config", "health", "idempotency", "logger", "metrics", "scheduler", "tracing", "webserver"}
+
+// Override is a level changed at runtime, it reverts at Expires.
+type Override struct {
+	Module  string     `json:"module"`
+	Level   slog.Level `json:"level"`
+	Expires time.Time  `json:"expires"`
+
+	timer *time.Timer
+}
+
+// LevelStatus is a snapshot of the levels.
+type LevelStatus struct {
+	Default   slog.Level            `json:"default"`
+	Modules   map[string]slog.Level `json:"modules"`
+	Overrides []Override            `json:"overrides"`
+}
+
+// Levels holds the log level of every module. A module is the last element of
+// the package path of the logging code, like "webserver" or "scheduler".
+// Modules without an own level log at the default level, which is LogLevel.
+// Overrides set at runtime take precedence until they expire.
+type Levels struct {
+	mu        sync.RWMutex
+	modules   map[string]slog.Level
+	overrides map[string]*Override
+	// minimum is the lowest level of all modules, records below it are
+	// dropped without looking up their module
+	minimum slog.Level
+}
+
+func newLevels() *Levels {
+	l := &Levels{modules: map[string]slog.Level{}, overrides: map[string]*Override{}}
+	l.update()
+
+	return l
+}
+
+// ParseLevel parses debug, info, warn and error, with an optional offset like
+// debug-4.
+func ParseLevel(s string) (slog.Level, error) {
+	var level slog.Level
+
+	if err := level.UnmarshalText([]byte(s)); err != nil {
+		return 0, err
+	}
+
+	return level, nil
+}
+
+// ParseModuleLevels parses module=level entries, like webserver=debug.
+func ParseModuleLevels(entries []string) (map[string]slog.Level, error) {
+	levels := map[string]slog.Level{}
+
+	for _, entry := range entries {
+		module, levelName, ok := strings.Cut(entry, "=")
+		if !ok || module == "" {
+			return nil, fmt.Errorf("invalid module level %q, expected module=level", entry)
+		}
+
+		level, err := ParseLevel(levelName)
+		if err != nil {
+			return nil, fmt.Errorf("invalid level of module %s: %w", module, err)
+		}
+
+		levels[module] = level
+	}
+
+	return levels, nil
+}
+
+// SetModules replaces the configured levels of the modules, overrides are
+// kept.
+func (l *Levels) SetModules(modules map[string]slog.Level) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.modules = modules
+	l.update()
+}
+
+// Override sets the level of module, DefaultModule for the default level,
+// until ttl elapses. A previous override of the module is replaced.
+func (l *Levels) Override(module string, level slog.Level, ttl time.Duration) Override {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if previous, ok := l.overrides[module]; ok {
+		previous.timer.Stop()
+	}
+
+	o := &Override{Module: module, Level: level, Expires: time.Now().Add(ttl)}
+	o.timer = time.AfterFunc(ttl, func() { l.revert(o) })
+
+	l.overrides[module] = o
+	l.update()
+
+	return *o
+}
+
+func (l *Levels) revert(o *Override) {
+	l.mu.Lock()
+
+	// the override was replaced in the meantime
+	if l.overrides[o.Module] != o {
+		l.mu.Unlock()
+		return
+	}
+
+	delete(l.overrides, o.Module)
+	l.update()
+	l.mu.Unlock()
+
+	slog.Info("Log level override expired", "module", o.Module, "log_level", o.Level)
+}
+
+// Known reports whether module is DefaultModule, one of Modules or has a
+// configured level.
+func (l *Levels) Known(module string) bool {
+	if module == DefaultModule || slices.Contains(Modules, module) {
+		return true
+	}
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	_, ok := l.modules[module]
+
+	return ok
+}
+
+// Level returns the effective level of module.
+func (l *Levels) Level(module string) slog.Level {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return l.level(module)
+}
+
+func (l *Levels) level(module string) slog.Level {
+	if o, ok := l.overrides[module]; ok {
+		return o.Level
+	}
+
+	if level, ok := l.modules[module]; ok {
+		return level
+	}
+
+	if o, ok := l.overrides[DefaultModule]; ok {
+		return o.Level
+	}
+
+	return LogLevel.Level()
+}
+
+// update recomputes minimum, l.mu must be held.
+func (l *Levels) update() {
+	minimum := l.level(DefaultModule)
+
+	for module := range l.modules {
+		minimum = min(minimum, l.level(module))
+	}
+
+	for module := range l.overrides {
+		minimum = min(minimum, l.level(module))
+	}
+
+	l.minimum = minimum
+}
+
+func (l *Levels) enabled(level slog.Level) bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	// LogLevel may have changed since the last update
+	return level >= min(l.minimum, l.level(DefaultModule))
+}
+
+// Status returns the default level, the configured module levels and the
+// active overrides sorted by module.
+func (l *Levels) Status() LevelStatus {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	status := LevelStatus{
+		Default:   l.level(DefaultModule),
+		Modules:   make(map[string]slog.Level, len(l.modules)),
+		Overrides: make([]Override, 0, len(l.overrides)),
+	}
+
+	for module, level := range l.modules {
+		status.Modules[module] = level
+	}
+
+	for _, o := range l.overrides {
+		status.Overrides = append(status.Overrides, Override{Module: o.Module, Level: o.Level, Expires: o.Expires})
+	}
+
+	sort.Slice(status.Overrides, func(i, j int) bool { return status.Overrides[i].Module < status.Overrides[j].Module })
+
+	return status
+}
+
+var modulesByPC sync.Map
+
+// moduleOf returns the module of the code at pc, DefaultModule when it is
+// unknown.
+func moduleOf(pc uintptr) string {
+	if pc == 0 {
+		return DefaultModule
+	}
+
+	if module, ok := modulesByPC.Load(pc); ok {
+		return module.(string)
+	}
+
+	frame, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+
+	module := DefaultModule
+
+	// github.com/org/repo/internal/webserver.(*server).run
+	if name := frame.Function; name != "" {
+		name = name[strings.LastIndex(name, "/")+1:]
+		if pkg, _, ok := strings.Cut(name, "."); ok {
+			module = pkg
+		}
+	}
+
+	modulesByPC.Store(pc, module)
+
+	return module
+}
+
+// levelHandler drops the records below the level of their module.
+type levelHandler struct {
+	next   slog.Handler
+	levels *Levels
+}
+
+func (h *levelHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return h.levels.enabled(level)
+}
+
+func (h *levelHandler) Handle(ctx context.Context, r slog.Record) error {
+	if r.Level < h.levels.Level(moduleOf(r.PC)) {
+		return nil
+	}
+
+	return h.next.Handle(ctx, r)
+}
+
+func (h *levelHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &levelHandler{next: h.next.WithAttrs(attrs), levels: h.levels}
+}
+
+func (h *levelHandler) WithGroup(name string) slog.Handler {
+	return &levelHandler{next: h.next.WithGroup(name), levels: h.levels}
+}
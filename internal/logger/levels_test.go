@@ -0,0 +1,233 @@
+/**
+ * This work is licensed under Apache License, Version 2.0 or later.
+ * Please read and understand latest version of Licence.
+ */
+package logger
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"maps"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// setLogLevel sets LogLevel until the test ends.
+func setLogLevel(t *testing.T, level slog.Level) {
+	t.Helper()
+
+	previous := LogLevel.Level()
+	LogLevel.Set(level)
+
+	t.Cleanup(func() { LogLevel.Set(previous) })
+}
+
+func waitForLevel(t *testing.T, l *Levels, module string, want slog.Level) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+
+	for l.Level(module) != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("level of %s = %v, want %v", module, l.Level(module), want)
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestParseModuleLevels(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []string
+		want    map[string]slog.Level
+		wantErr bool
+	}{
+		{name: "empty", entries: nil, want: map[string]slog.Level{}},
+		{
+			name:    "levels",
+			entries: []string{"webserver=debug", "scheduler=WARN", "audit=debug-4"},
+			want:    map[string]slog.Level{"webserver": slog.LevelDebug, "scheduler": slog.LevelWarn, "audit": slog.LevelDebug - 4},
+		},
+		{name: "missing level", entries: []string{"webserver"}, wantErr: true},
+		{name: "missing module", entries: []string{"=debug"}, wantErr: true},
+		{name: "invalid level", entries: []string{"webserver=verbose"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseModuleLevels(tt.entries)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if !tt.wantErr && !maps.Equal(got, tt.want) {
+				t.Fatalf("levels = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLevelPrecedence(t *testing.T) {
+	setLogLevel(t, slog.LevelInfo)
+
+	l := newLevels()
+	l.SetModules(map[string]slog.Level{"webserver": slog.LevelWarn})
+
+	tests := []struct {
+		name      string
+		overrides map[string]slog.Level
+		module    string
+		want      slog.Level
+	}{
+		{name: "default", module: "scheduler", want: slog.LevelInfo},
+		{name: "configured module", module: "webserver", want: slog.LevelWarn},
+		{name: "default override", overrides: map[string]slog.Level{DefaultModule: slog.LevelDebug}, module: "scheduler", want: slog.LevelDebug},
+		{name: "configured module wins over the default override", overrides: map[string]slog.Level{DefaultModule: slog.LevelDebug}, module: "webserver", want: slog.LevelWarn},
+		{name: "module override", overrides: map[string]slog.Level{"webserver": slog.LevelError}, module: "webserver", want: slog.LevelError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for module, level := range tt.overrides {
+				l.Override(module, level, time.Hour)
+			}
+
+			t.Cleanup(func() {
+				for module := range tt.overrides {
+					l.mu.Lock()
+					l.overrides[module].timer.Stop()
+					delete(l.overrides, module)
+					l.update()
+					l.mu.Unlock()
+				}
+			})
+
+			if got := l.Level(tt.module); got != tt.want {
+				t.Fatalf("Level(%s) = %v, want %v", tt.module, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOverrideReverts(t *testing.T) {
+	setLogLevel(t, slog.LevelInfo)
+
+	l := newLevels()
+
+	o := l.Override("webserver", slog.LevelDebug, 20*time.Millisecond)
+	if o.Module != "webserver" || o.Level != slog.LevelDebug || time.Until(o.Expires) > 20*time.Millisecond {
+		t.Fatalf("override = %+v", o)
+	}
+
+	if !l.enabled(slog.LevelDebug) {
+		t.Fatal("debug records dropped while a module logs at debug")
+	}
+
+	waitForLevel(t, l, "webserver", slog.LevelInfo)
+
+	if l.enabled(slog.LevelDebug) {
+		t.Fatal("debug records enabled after the override expired")
+	}
+
+	if status := l.Status(); len(status.Overrides) != 0 {
+		t.Fatalf("overrides = %v, want none", status.Overrides)
+	}
+}
+
+func TestOverrideReplaced(t *testing.T) {
+	setLogLevel(t, slog.LevelInfo)
+
+	l := newLevels()
+
+	l.Override("webserver", slog.LevelDebug, 10*time.Millisecond)
+	l.Override("webserver", slog.LevelWarn, time.Hour)
+
+	// the timer of the replaced override does not revert the new one
+	time.Sleep(30 * time.Millisecond)
+
+	if got := l.Level("webserver"); got != slog.LevelWarn {
+		t.Fatalf("level = %v, want %v", got, slog.LevelWarn)
+	}
+}
+
+func TestStatus(t *testing.T) {
+	setLogLevel(t, slog.LevelWarn)
+
+	l := newLevels()
+	l.SetModules(map[string]slog.Level{"audit": slog.LevelInfo})
+	l.Override("webserver", slog.LevelDebug, time.Hour)
+	l.Override(DefaultModule, slog.LevelError, time.Hour)
+
+	status := l.Status()
+
+	if status.Default != slog.LevelError {
+		t.Fatalf("default = %v, want the override %v", status.Default, slog.LevelError)
+	}
+
+	if !maps.Equal(status.Modules, map[string]slog.Level{"audit": slog.LevelInfo}) {
+		t.Fatalf("modules = %v", status.Modules)
+	}
+
+	if len(status.Overrides) != 2 || status.Overrides[0].Module != DefaultModule || status.Overrides[1].Module != "webserver" {
+		t.Fatalf("overrides = %+v, want sorted by module", status.Overrides)
+	}
+}
+
+func TestKnown(t *testing.T) {
+	l := newLevels()
+	l.SetModules(map[string]slog.Level{"vendored": slog.LevelDebug})
+
+	tests := []struct {
+		module string
+		want   bool
+	}{
+		{module: DefaultModule, want: true},
+		{module: "webserver", want: true},
+		{module: "main", want: true},
+		{module: "vendored", want: true},
+		{module: "webservr", want: false},
+		{module: "", want: false},
+	}
+
+	for _, tt := range tests {
+		if got := l.Known(tt.module); got != tt.want {
+			t.Errorf("Known(%q) = %v, want %v", tt.module, got, tt.want)
+		}
+	}
+}
+
+func TestLevelHandler(t *testing.T) {
+	setLogLevel(t, slog.LevelInfo)
+
+	var buf bytes.Buffer
+
+	l := newLevels()
+	logger := slog.New(&levelHandler{next: slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug - 4}), levels: l})
+
+	pc, _, _, _ := runtime.Caller(0)
+	if module := moduleOf(pc); module != "logger" {
+		t.Fatalf("module of this test = %q, want logger", module)
+	}
+
+	logger.Debug("dropped")
+
+	// another module logging at debug enables debug records, this module
+	// still drops them
+	l.Override("webserver", slog.LevelDebug, time.Hour)
+	logger.Debug("dropped too")
+
+	l.Override("logger", slog.LevelDebug, time.Hour)
+	logger.Debug("kept")
+
+	if got := buf.String(); strings.Contains(got, "dropped") || !strings.Contains(got, "kept") {
+		t.Fatalf("output = %q", got)
+	}
+
+	if !logger.Handler().Enabled(context.Background(), slog.LevelDebug) {
+		t.Fatal("debug disabled with a module at debug")
+	}
+}
@@ -5,17 +5,121 @@
 package logger
 
 import (
+	"context"
+	"fmt"
+	"io"
 	"log"
 	"log/slog"
+	"os"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Output formats of the logs.
+const (
+	// FormatPretty writes colored lines for humans
+	FormatPretty = "pretty"
+	// FormatJSON writes a JSON object per line
+	FormatJSON = "json"
+	// FormatLogfmt writes key=value pairs per line
+	FormatLogfmt = "logfmt"
 )
 
+var Formats = []string{FormatPretty, FormatJSON, FormatLogfmt}
+
 var (
 	LogLevel                    = new(slog.LevelVar)
-	DefaultHandler slog.Handler = NewHandler(&slog.HandlerOptions{
+	moduleLevels                = newLevels()
+	DefaultHandler slog.Handler = &levelHandler{next: NewHandler(&slog.HandlerOptions{
 		AddSource: true,
-		Level:     LogLevel,
-	})
+		Level:     slog.LevelDebug - 4,
+	}), levels: moduleLevels}
 	DefaultSLogger     *slog.Logger = slog.New(DefaultHandler)
 	DefaultLogger      *log.Logger  = slog.NewLogLogger(DefaultHandler, slog.LevelInfo)
 	DefaultErrorLogger *log.Logger  = slog.NewLogLogger(DefaultHandler, slog.LevelError)
 )
+
+// ModuleLevels returns the levels of the modules of the process.
+func ModuleLevels() *Levels {
+	return moduleLevels
+}
+
+// NewFormatHandler returns a handler writing format to writer. Levels are not
+// filtered, the handlers are wrapped by the module levels.
+func NewFormatHandler(format string, writer io.Writer) (slog.Handler, error) {
+	opts := &slog.HandlerOptions{
+		AddSource: true,
+		Level:     slog.LevelDebug - 4,
+	}
+
+	switch format {
+	case FormatPretty, "":
+		return NewHandlerWithOptions(opts, WithDestinationWriter(writer), WithColor(), WithOutputEmptyAttrs()), nil
+	case FormatJSON:
+		return &contextHandler{next: slog.NewJSONHandler(writer, opts)}, nil
+	case FormatLogfmt:
+		return &contextHandler{next: slog.NewTextHandler(writer, opts)}, nil
+	}
+
+	return nil, fmt.Errorf("unknown log format %q", format)
+}
+
+// SetFormat replaces the default loggers by ones writing format to stderr.
+// Loggers derived from the previous ones keep the previous format.
+func SetFormat(format string) error {
+	handler, err := NewFormatHandler(format, os.Stderr)
+	if err != nil {
+		return err
+	}
+
+	DefaultHandler = &levelHandler{next: handler, levels: moduleLevels}
+	DefaultSLogger = slog.New(DefaultHandler)
+	DefaultLogger = slog.NewLogLogger(DefaultHandler, slog.LevelInfo)
+	DefaultErrorLogger = slog.NewLogLogger(DefaultHandler, slog.LevelError)
+
+	slog.SetDefault(DefaultSLogger)
+
+	return nil
+}
+
+// withContextAttrs correlates records logged with a request context or inside
+// a span.
+func withContextAttrs(ctx context.Context, r slog.Record) slog.Record {
+	if rc := RequestContextFromContext(ctx); rc != nil {
+		r = r.Clone()
+		r.AddAttrs(slog.String("request_id", rc.RequestID))
+
+		if sc := trace.SpanContextFromContext(ctx); !sc.IsValid() {
+			r.AddAttrs(slog.String("trace_id", rc.TraceID))
+		}
+	}
+
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		r = r.Clone()
+		r.AddAttrs(slog.String("trace_id", sc.TraceID().String()), slog.String("span_id", sc.SpanID().String()))
+	}
+
+	return r
+}
+
+// contextHandler adds the request and trace attributes to the records of the
+// standard handlers.
+type contextHandler struct {
+	next slog.Handler
+}
+
+func (h *contextHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	return h.next.Handle(ctx, withContextAttrs(ctx, r))
+}
+
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{next: h.next.WithAttrs(attrs)}
+}
+
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{next: h.next.WithGroup(name)}
+}
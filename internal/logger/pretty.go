@@ -85,6 +85,8 @@ func (h *Handler) computeAttrs(ctx context.Context, r slog.Record) (map[string]a
 }
 
 func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	r = withContextAttrs(ctx, r)
+
 	colorize := func(code int, value string) string {
 		return value
 	}
@@ -0,0 +1,173 @@
+/**
+ * This work is licensed under Apache License, Version 2.0 or later.
+ * Please read and understand latest version of Licence.
+ */
+package logger
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"regexp"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	RequestIDHeader   = "X-Request-ID"
+	TraceParentHeader = "traceparent"
+
+	maxRequestIDLength = 128
+)
+
+// RequestContext correlates a request with its log lines and the calls it
+// makes. TraceID and SpanID follow W3C trace context, SpanID is the span of
+// this server.
+type RequestContext struct {
+	RequestID  string
+	TraceID    string
+	SpanID     string
+	TraceFlags string
+}
+
+type requestContextKey struct{}
+
+var (
+	requestIDPattern   = regexp.MustCompile(`^[A-Za-z0-9._:/+=-]+$`)
+	traceParentPattern = regexp.MustCompile(`^([0-9a-f]{2})-([0-9a-f]{32})-([0-9a-f]{16})-([0-9a-f]{2})$`)
+)
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+
+	// crypto/rand.Read never returns an error on supported platforms
+	_, _ = rand.Read(b)
+
+	return hex.EncodeToString(b)
+}
+
+func allZero(s string) bool {
+	for _, c := range s {
+		if c != '0' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// TraceParent formats the context as a W3C traceparent header value.
+func (rc *RequestContext) TraceParent() string {
+	return "00-" + rc.TraceID + "-" + rc.SpanID + "-" + rc.TraceFlags
+}
+
+// NewRequestContext takes the request id and trace of the incoming headers,
+// invalid or missing values are generated.
+func NewRequestContext(header http.Header) *RequestContext {
+	rc := &RequestContext{
+		RequestID:  header.Get(RequestIDHeader),
+		SpanID:     randomHex(8),
+		TraceFlags: "01",
+	}
+
+	if len(rc.RequestID) > maxRequestIDLength || !requestIDPattern.MatchString(rc.RequestID) {
+		rc.RequestID = randomHex(16)
+	}
+
+	if m := traceParentPattern.FindStringSubmatch(header.Get(TraceParentHeader)); m != nil && m[1] != "ff" && !allZero(m[2]) && !allZero(m[3]) {
+		rc.TraceID = m[2]
+		rc.TraceFlags = m[4]
+	} else {
+		rc.TraceID = randomHex(16)
+	}
+
+	return rc
+}
+
+func WithRequestContext(ctx context.Context, rc *RequestContext) context.Context {
+	return context.WithValue(ctx, requestContextKey{}, rc)
+}
+
+// RequestContextFromContext returns the request context stored by
+// RequestContextHandler, nil outside of a request.
+func RequestContextFromContext(ctx context.Context) *RequestContext {
+	if ctx == nil {
+		return nil
+	}
+
+	rc, _ := ctx.Value(requestContextKey{}).(*RequestContext)
+
+	return rc
+}
+
+// RequestIDFromContext returns the request id, empty outside of a request.
+func RequestIDFromContext(ctx context.Context) string {
+	if rc := RequestContextFromContext(ctx); rc != nil {
+		return rc.RequestID
+	}
+
+	return ""
+}
+
+// TraceParentFromContext returns the traceparent of the active span, or of
+// the request context when tracing is disabled. It is empty outside of a
+// request.
+func TraceParentFromContext(ctx context.Context) string {
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		return "00-" + sc.TraceID().String() + "-" + sc.SpanID().String() + "-" + sc.TraceFlags().String()
+	}
+
+	if rc := RequestContextFromContext(ctx); rc != nil {
+		return rc.TraceParent()
+	}
+
+	return ""
+}
+
+// RequestContextHandler accepts or generates the request id and trace of a
+// request, stores them in the context and returns them in the response
+// headers.
+func RequestContextHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rc := NewRequestContext(r.Header)
+
+		w.Header().Set(RequestIDHeader, rc.RequestID)
+		w.Header().Set(TraceParentHeader, rc.TraceParent())
+
+		next.ServeHTTP(w, r.WithContext(WithRequestContext(r.Context(), rc)))
+	})
+}
+
+// RequestContextTransport adds the request id and the trace of the active
+// span or request context to outgoing calls.
+type RequestContextTransport struct {
+	Base http.RoundTripper
+}
+
+func (t *RequestContextTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	requestID := RequestIDFromContext(req.Context())
+	traceParent := TraceParentFromContext(req.Context())
+
+	if requestID == "" && traceParent == "" {
+		return base.RoundTrip(req)
+	}
+
+	// RoundTrippers must not modify the request
+	req = req.Clone(req.Context())
+
+	if requestID != "" {
+		req.Header.Set(RequestIDHeader, requestID)
+	}
+
+	if traceParent != "" {
+		req.Header.Set(TraceParentHeader, traceParent)
+	}
+
+	return base.RoundTrip(req)
+}
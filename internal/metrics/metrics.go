@@ -0,0 +1,196 @@
+/**
+ * This work is licensed under Apache License, Version 2.0 or later.
+ * Please read and understand latest version of Licence.
+ */
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "app"
+
+// Registry holds the metrics of the application and the Go runtime.
+var Registry = prometheus.NewRegistry()
+
+var (
+	HTTPRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by route, method and status code.",
+	}, []string{"route", "method", "code"})
+
+	HTTPRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by route and method.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method"})
+
+	APIActions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "api_actions_total",
+		Help:      "API actions by action and status code.",
+	}, []string{"action", "code"})
+
+	APIActionDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "api_action_duration_seconds",
+		Help:      "API action latency by action.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"action"})
+
+	AuthFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "auth_failures_total",
+		Help:      "Failed authentications by reason.",
+	}, []string{"reason"})
+
+	Reconciles = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "reconcile_total",
+		Help:      "Reconcile phases run by the watcher by phase and result.",
+	}, []string{"phase", "result"})
+
+	ReconcileDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "reconcile_duration_seconds",
+		Help:      "Duration of the reconcile phases of the watcher.",
+		Buckets:   []float64{0.01, 0.05, 0.1, 0.5, 1, 5, 10, 30, 60, 120, 300},
+	}, []string{"phase"})
+
+	ManagedClusters = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "managed_clusters",
+		Help:      "Clusters managed by the watcher by phase.",
+	}, []string{"phase"})
+
+	JobRuns = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "scheduled_job_runs_total",
+		Help:      "Runs of the scheduled jobs by job and result.",
+	}, []string{"job", "result"})
+
+	JobDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "scheduled_job_duration_seconds",
+		Help:      "Duration of the scheduled jobs.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"job"})
+
+	CertificateExpiry = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "certificate_expiry_timestamp_seconds",
+		Help:      "Unix time the certificates used by the process expire by certificate.",
+	}, []string{"certificate"})
+
+	JobLastSuccess = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "scheduled_job_last_success_timestamp_seconds",
+		Help:      "Unix time of the last successful run of the scheduled jobs.",
+	}, []string{"job"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequests,
+		HTTPRequestDuration,
+		APIActions,
+		APIActionDuration,
+		AuthFailures,
+		Reconciles,
+		ReconcileDuration,
+		ManagedClusters,
+		JobRuns,
+		JobDuration,
+		JobLastSuccess,
+		CertificateExpiry,
+	)
+}
+
+// ObserveAction records a finished API action.
+func ObserveAction(action string, status int, duration time.Duration) {
+	APIActions.WithLabelValues(action, strconv.Itoa(status)).Inc()
+	APIActionDuration.WithLabelValues(action).Observe(duration.Seconds())
+}
+
+// ObservePhase runs a reconcile phase and records its result and duration.
+func ObservePhase(phase string, run func() error) error {
+	start := time.Now()
+	err := run()
+
+	ReconcileDuration.WithLabelValues(phase).Observe(time.Since(start).Seconds())
+
+	result := "success"
+	if err != nil {
+		result = "error"
+	}
+
+	Reconciles.WithLabelValues(phase, result).Inc()
+
+	return err
+}
+
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
+
+// Serve exposes /metrics and the given routes, like the health probes, on
+// its own port so they are not reachable through the API ingress. A port of 0
+// disables the endpoint.
+func Serve(port int, routes map[string]http.Handler) (*http.Server, error) {
+	if port == 0 {
+		return nil, nil
+	}
+
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		return nil, err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", Handler())
+
+	for path, handler := range routes {
+		mux.Handle(path, handler)
+	}
+
+	srv := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		if err := srv.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("Error serving metrics", "error", err)
+		}
+	}()
+
+	slog.Info("Metrics server started", "port", port)
+
+	return srv, nil
+}
+
+// Shutdown stops the metrics server returned by Serve.
+func Shutdown(ctx context.Context, srv *http.Server) {
+	if srv == nil {
+		return
+	}
+
+	if err := srv.Shutdown(ctx); err != nil {
+		slog.Error("Error shutting down metrics server", "error", err)
+	}
+}
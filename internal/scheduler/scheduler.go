@@ -0,0 +1,247 @@
+/**
+ * This work is licensed under Apache License, Version 2.0 or later.
+ * Please read and understand latest version of Licence.
+ */
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/kazimsarikaya/assesmentbarkinrl/internal/health"
+	"github.com/kazimsarikaya/assesmentbarkinrl/internal/metrics"
+	"github.com/kazimsarikaya/assesmentbarkinrl/internal/tracing"
+)
+
+// disabledPoll is how often a disabled job checks whether it was enabled by a
+// config reload.
+const disabledPoll = time.Minute
+
+// failureThreshold is the number of consecutive failures after which a job is
+// reported unhealthy.
+const failureThreshold = 3
+
+// Job is a periodic maintenance task. Interval and Jitter are read before
+// every run so config reloads apply; an interval of 0 disables the job.
+type Job struct {
+	Name     string
+	Interval func() time.Duration
+	// Jitter is the maximum random delay added to the interval as a fraction
+	// of it, spreading the runs of replicas.
+	Jitter func() float64
+	Run    func(ctx context.Context) error
+}
+
+// JobStatus is a snapshot of the state of a job.
+type JobStatus struct {
+	Name                string        `json:"name"`
+	Interval            time.Duration `json:"interval"`
+	Runs                uint64        `json:"runs"`
+	Failures            uint64        `json:"failures"`
+	ConsecutiveFailures int           `json:"consecutive_failures"`
+	LastRun             time.Time     `json:"last_run,omitempty"`
+	LastDuration        time.Duration `json:"last_duration"`
+	LastError           string        `json:"last_error,omitempty"`
+	NextRun             time.Time     `json:"next_run,omitempty"`
+}
+
+type entry struct {
+	job    Job
+	status JobStatus
+}
+
+// Scheduler runs registered jobs until its context is done. Jobs registered
+// after Start begin right away.
+type Scheduler struct {
+	mu      sync.Mutex
+	ctx     context.Context
+	entries map[string]*entry
+}
+
+func New() *Scheduler {
+	return &Scheduler{entries: map[string]*entry{}}
+}
+
+var defaultScheduler = New()
+
+// Default returns the scheduler of the process.
+func Default() *Scheduler {
+	return defaultScheduler
+}
+
+// Register adds a job, a job with the same name is not added twice.
+func (s *Scheduler) Register(job Job) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.entries[job.Name]; ok {
+		return
+	}
+
+	e := &entry{job: job, status: JobStatus{Name: job.Name}}
+	s.entries[job.Name] = e
+
+	if s.ctx != nil {
+		go s.loop(s.ctx, e)
+	}
+}
+
+// Start runs the registered jobs until ctx is done.
+func (s *Scheduler) Start(ctx context.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.ctx != nil {
+		return
+	}
+
+	s.ctx = ctx
+
+	for _, e := range s.entries {
+		go s.loop(ctx, e)
+	}
+
+	slog.Info("Scheduler started", "jobs", len(s.entries))
+}
+
+// delay returns the wait before the next run, zero when the job is disabled.
+func (e *entry) delay() (time.Duration, time.Duration) {
+	interval := e.job.Interval()
+	if interval <= 0 {
+		return 0, 0
+	}
+
+	delay := interval
+
+	if e.job.Jitter != nil {
+		if jitter := e.job.Jitter(); jitter > 0 {
+			delay += time.Duration(rand.Float64() * jitter * float64(interval))
+		}
+	}
+
+	return interval, delay
+}
+
+func (s *Scheduler) loop(ctx context.Context, e *entry) {
+	for {
+		interval, delay := e.delay()
+
+		s.mu.Lock()
+		e.status.Interval = interval
+		if interval > 0 {
+			e.status.NextRun = time.Now().Add(delay)
+		} else {
+			e.status.NextRun = time.Time{}
+			delay = disabledPoll
+		}
+		s.mu.Unlock()
+
+		timer := time.NewTimer(delay)
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		if interval > 0 {
+			s.run(ctx, e)
+		}
+	}
+}
+
+// run runs a job once, bounded by its interval, and records the outcome.
+func (s *Scheduler) run(ctx context.Context, e *entry) {
+	ctx, cancel := context.WithTimeout(ctx, e.job.Interval())
+	defer cancel()
+
+	ctx, span := tracing.Start(ctx, "job."+e.job.Name)
+
+	start := time.Now()
+	err := runSafe(ctx, e.job.Run)
+	duration := time.Since(start)
+
+	tracing.End(span, err)
+
+	result := "success"
+	if err != nil {
+		result = "error"
+	}
+
+	metrics.JobRuns.WithLabelValues(e.job.Name, result).Inc()
+	metrics.JobDuration.WithLabelValues(e.job.Name).Observe(duration.Seconds())
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e.status.Runs++
+	e.status.LastRun = start
+	e.status.LastDuration = duration
+
+	if err != nil {
+		e.status.Failures++
+		e.status.ConsecutiveFailures++
+		e.status.LastError = err.Error()
+
+		slog.WarnContext(ctx, "Scheduled job failed", "job", e.job.Name, "error", err)
+
+		return
+	}
+
+	e.status.ConsecutiveFailures = 0
+	e.status.LastError = ""
+
+	metrics.JobLastSuccess.WithLabelValues(e.job.Name).SetToCurrentTime()
+
+	slog.DebugContext(ctx, "Scheduled job finished", "job", e.job.Name, "duration", duration)
+}
+
+func runSafe(ctx context.Context, run func(ctx context.Context) error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job panicked: %v", r)
+		}
+	}()
+
+	return run(ctx)
+}
+
+// Status returns the status of the jobs sorted by name.
+func (s *Scheduler) Status() []JobStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := make([]JobStatus, 0, len(s.entries))
+	for _, e := range s.entries {
+		result = append(result, e.status)
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+
+	return result
+}
+
+// HealthCheck fails when a job failed failureThreshold times in a row.
+func (s *Scheduler) HealthCheck() health.Check {
+	return health.Check{
+		Name: "scheduler",
+		Check: func(context.Context) error {
+			var errs []error
+
+			for _, status := range s.Status() {
+				if status.ConsecutiveFailures >= failureThreshold {
+					errs = append(errs, fmt.Errorf("job %s failed %d times: %s", status.Name, status.ConsecutiveFailures, status.LastError))
+				}
+			}
+
+			return errors.Join(errs...)
+		},
+	}
+}
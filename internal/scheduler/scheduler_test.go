@@ -0,0 +1,248 @@
+/**
+ * This work is licensed under Apache License, Version 2.0 or later.
+ * Please read and understand latest version of Licence.
+ */
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func every(d time.Duration) func() time.Duration {
+	return func() time.Duration { return d }
+}
+
+// startScheduler starts s until the test ends.
+func startScheduler(t *testing.T, s *Scheduler) {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	s.Start(ctx)
+}
+
+// waitFor polls cond until it holds or a second passed.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func status(s *Scheduler, name string) JobStatus {
+	for _, st := range s.Status() {
+		if st.Name == name {
+			return st
+		}
+	}
+
+	return JobStatus{}
+}
+
+func TestRegister(t *testing.T) {
+	s := New()
+
+	first := &atomic.Int32{}
+	second := &atomic.Int32{}
+
+	s.Register(Job{Name: "b", Interval: every(5 * time.Millisecond), Run: func(context.Context) error { first.Add(1); return nil }})
+	// a second job of the same name is ignored
+	s.Register(Job{Name: "b", Interval: every(5 * time.Millisecond), Run: func(context.Context) error { second.Add(1); return nil }})
+
+	startScheduler(t, s)
+
+	// jobs registered after Start begin right away
+	s.Register(Job{Name: "a", Interval: every(5 * time.Millisecond), Run: func(context.Context) error { return nil }})
+
+	waitFor(t, "both jobs to run", func() bool {
+		return status(s, "a").Runs > 0 && status(s, "b").Runs > 0
+	})
+
+	if second.Load() != 0 {
+		t.Fatal("duplicate job ran")
+	}
+
+	statuses := s.Status()
+	if len(statuses) != 2 || statuses[0].Name != "a" || statuses[1].Name != "b" {
+		t.Fatalf("Status = %+v, want a and b sorted by name", statuses)
+	}
+}
+
+func TestDelay(t *testing.T) {
+	tests := []struct {
+		name     string
+		interval time.Duration
+		jitter   func() float64
+		min, max time.Duration
+	}{
+		{name: "without jitter", interval: time.Minute, min: time.Minute, max: time.Minute},
+		{name: "zero jitter", interval: time.Minute, jitter: func() float64 { return 0 }, min: time.Minute, max: time.Minute},
+		{name: "jitter", interval: time.Minute, jitter: func() float64 { return 0.5 }, min: time.Minute, max: 90 * time.Second},
+		{name: "disabled", interval: 0, jitter: func() float64 { return 0.5 }, min: 0, max: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := &entry{job: Job{Interval: every(tt.interval), Jitter: tt.jitter}}
+
+			for i := 0; i < 100; i++ {
+				interval, delay := e.delay()
+
+				if interval != tt.interval {
+					t.Fatalf("interval = %v, want %v", interval, tt.interval)
+				}
+
+				if delay < tt.min || delay > tt.max {
+					t.Fatalf("delay = %v, want between %v and %v", delay, tt.min, tt.max)
+				}
+			}
+		})
+	}
+}
+
+func TestJobStatus(t *testing.T) {
+	s := New()
+
+	results := []error{errors.New("unavailable"), nil, errors.New("unavailable"), errors.New("timeout")}
+	runs := 0
+
+	s.Register(Job{
+		Name:     "flaky",
+		Interval: every(time.Minute),
+		Run: func(context.Context) error {
+			runs++
+
+			if runs == 2 {
+				panic("broken")
+			}
+
+			return results[runs-1]
+		},
+	})
+
+	e := s.entries["flaky"]
+	check := s.HealthCheck()
+
+	tests := []struct {
+		wantFailures    uint64
+		wantConsecutive int
+		wantError       string
+		wantHealthy     bool
+	}{
+		{wantFailures: 1, wantConsecutive: 1, wantError: "unavailable", wantHealthy: true},
+		{wantFailures: 2, wantConsecutive: 2, wantError: "job panicked: broken", wantHealthy: true},
+		{wantFailures: 3, wantConsecutive: 3, wantError: "unavailable", wantHealthy: false},
+		{wantFailures: 4, wantConsecutive: 4, wantError: "timeout", wantHealthy: false},
+	}
+
+	for i, tt := range tests {
+		s.run(context.Background(), e)
+
+		st := status(s, "flaky")
+		if st.Runs != uint64(i+1) || st.Failures != tt.wantFailures || st.ConsecutiveFailures != tt.wantConsecutive ||
+			st.LastError != tt.wantError || st.LastRun.IsZero() {
+			t.Fatalf("run %d: status = %+v", i+1, st)
+		}
+
+		if err := check.Check(context.Background()); (err == nil) != tt.wantHealthy {
+			t.Fatalf("run %d: health = %v, want healthy %v", i+1, err, tt.wantHealthy)
+		}
+	}
+
+	// a success resets the consecutive failures
+	results = append(results, nil)
+
+	s.run(context.Background(), e)
+
+	if st := status(s, "flaky"); st.Failures != 4 || st.ConsecutiveFailures != 0 || st.LastError != "" {
+		t.Fatalf("status after a success = %+v", st)
+	}
+
+	if err := check.Check(context.Background()); err != nil {
+		t.Fatalf("health after a success: %v", err)
+	}
+}
+
+func TestStatusNextRun(t *testing.T) {
+	s := New()
+
+	s.Register(Job{Name: "job", Interval: every(time.Hour), Run: func(context.Context) error { return nil }})
+
+	startScheduler(t, s)
+
+	waitFor(t, "the next run", func() bool { return !status(s, "job").NextRun.IsZero() })
+
+	if st := status(s, "job"); st.Interval != time.Hour || time.Until(st.NextRun) < 59*time.Minute || st.Runs != 0 {
+		t.Fatalf("status = %+v", st)
+	}
+}
+
+func TestNoOverlap(t *testing.T) {
+	s := New()
+
+	running := &atomic.Int32{}
+	overlapped := &atomic.Bool{}
+	runs := &atomic.Int32{}
+
+	s.Register(Job{
+		Name:     "slow",
+		Interval: every(2 * time.Millisecond),
+		Run: func(ctx context.Context) error {
+			if running.Add(1) > 1 {
+				overlapped.Store(true)
+			}
+			defer running.Add(-1)
+
+			runs.Add(1)
+
+			// outlives the interval, the run is bounded by it
+			<-ctx.Done()
+
+			return ctx.Err()
+		},
+	})
+
+	startScheduler(t, s)
+
+	waitFor(t, "several runs", func() bool { return runs.Load() >= 5 })
+
+	if overlapped.Load() {
+		t.Fatal("runs of a job overlapped")
+	}
+
+	if st := status(s, "slow"); st.LastError != context.DeadlineExceeded.Error() {
+		t.Fatalf("last error = %q, want the interval deadline", st.LastError)
+	}
+}
+
+func TestDisabledJob(t *testing.T) {
+	s := New()
+
+	runs := &atomic.Int32{}
+
+	s.Register(Job{Name: "off", Interval: every(0), Run: func(context.Context) error { runs.Add(1); return nil }})
+
+	startScheduler(t, s)
+
+	time.Sleep(20 * time.Millisecond)
+
+	if runs.Load() != 0 {
+		t.Fatal("disabled job ran")
+	}
+
+	if st := status(s, "off"); st.Interval != 0 || !st.NextRun.IsZero() {
+		t.Fatalf("status = %+v", st)
+	}
+}
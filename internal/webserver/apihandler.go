@@ -6,77 +6,148 @@ package webserver
 
 import (
 	"encoding/json"
+	"errors"
 	"io"
 	"log/slog"
 	"net/http"
-	"os"
-	"path/filepath"
 	"strings"
+	"time"
 
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/rest"
-	"k8s.io/client-go/tools/clientcmd"
+	"github.com/kazimsarikaya/assesmentbarkinrl/internal/config"
+	"github.com/kazimsarikaya/assesmentbarkinrl/internal/logger"
+	"github.com/kazimsarikaya/assesmentbarkinrl/internal/metrics"
 )
 
 type apiActionResult func()
 type apiAction func(w http.ResponseWriter, r *http.Request, data map[string]interface{}) apiActionResult
 
 type securedApiAction struct {
-	action   apiAction
-	needAuth bool
+	action    apiAction
+	needAuth  bool
+	adminOnly bool
+	// mutating actions are written to the audit log
+	mutating bool
+	// summary, request and response document the action in the OpenAPI
+	// document, request also validates the incoming data
+	summary  string
+	request  *jsonSchema
+	response *jsonSchema
 }
 
 var apiActions = map[string]securedApiAction{
-	"get_version":      {action: getVersion, needAuth: false},
-	"get_configmaps":   {action: getConfigMaps, needAuth: true},
-	"delete_configmap": {action: deleteConfigMap, needAuth: true},
-	"get_configmap":    {action: getConfigMap, needAuth: true},
-	"update_configmap": {action: updateConfigMap, needAuth: true},
+	"get_version": {
+		action: getVersion, needAuth: false,
+		summary: "Get the build information", request: emptyRequestSchema, response: versionSchema,
+	},
+	"get_configmaps": {
+		action: getConfigMaps, needAuth: true,
+		summary: "List ConfigMaps", request: getConfigMapsRequestSchema, response: arraySchema("ConfigMaps", configMapRefSchema),
+	},
+	"delete_configmap": {
+		action: deleteConfigMap, needAuth: true, mutating: true,
+		summary: "Delete a ConfigMap", request: configMapRequestSchema, response: statusSchema,
+	},
+	"get_configmap": {
+		action: getConfigMap, needAuth: true,
+		summary: "Get a ConfigMap", request: configMapRequestSchema, response: configMapSchema,
+	},
+	"update_configmap": {
+		action: updateConfigMap, needAuth: true, mutating: true,
+		summary: "Replace the data of a ConfigMap", request: updateConfigMapRequestSchema, response: statusSchema,
+	},
+	"get_audit_log": {
+		action: getAuditLog, needAuth: true, adminOnly: true,
+		summary: "Query the audit log", request: auditLogRequestSchema, response: arraySchema("Audit records", auditRecordSchema),
+	},
+	"get_log_levels": {
+		action: getLogLevels, needAuth: true, adminOnly: true,
+		summary: "Get the log levels of the replica", request: emptyRequestSchema, response: logLevelsSchema,
+	},
+	"set_log_level": {
+		action: setLogLevel, needAuth: true, adminOnly: true, mutating: true,
+		summary: "Change the log level of a module in the replica serving the call until the duration elapses, other replicas keep theirs", request: setLogLevelRequestSchema, response: setLogLevelResponseSchema,
+	},
 }
 
-func sendError(w http.ResponseWriter, errmsg string, statusCode int) {
-	w.WriteHeader(statusCode)
-	w.Header().Set("Content-Type", "application/json")
-	msg, _ := json.Marshal(map[string]string{"error": errmsg})
-	_, err := w.Write([]byte(msg))
+var (
+	errMissingAuthHeader = errors.New("authorization header is missing")
+	errInvalidAuthHeader = errors.New("authorization header is invalid")
+)
+
+// authenticateRequest validates the bearer token of the request.
+func authenticateRequest(r *http.Request) (*Identity, error) {
+	authHeader := r.Header.Get("Authorization")
+
+	if len(authHeader) == 0 {
+		metrics.AuthFailures.WithLabelValues(authFailureReason(errMissingAuthHeader)).Inc()
+		return nil, errMissingAuthHeader
+	}
+
+	parts := strings.SplitN(authHeader, " ", 2)
 
+	if len(parts) != 2 || parts[0] != "Bearer" {
+		metrics.AuthFailures.WithLabelValues(authFailureReason(errInvalidAuthHeader)).Inc()
+		return nil, errInvalidAuthHeader
+	}
+
+	identity, err := validateToken(r.Context(), parts[1])
 	if err != nil {
-		slog.Error("Error writing response", "error", err)
+		slog.DebugContext(r.Context(), "Token validation failed", "error", err)
+		metrics.AuthFailures.WithLabelValues(authFailureReason(err)).Inc()
+		return nil, errors.New("token validation failed")
 	}
+
+	return identity, nil
 }
 
 func ApiHandler(w http.ResponseWriter, r *http.Request) {
 	var data map[string]interface{}
 
+	// form posts and GET calls can be sent cross-site without a preflight
+	simpleRequest := false
+
 	// if method get and data parameter exists in query string
 	if (r.Method == "GET" || r.Method == "HEAD") && len(r.URL.Query().Get("data")) > 0 {
+		simpleRequest = true
+
 		err := json.Unmarshal([]byte(r.URL.Query().Get("data")), &data)
 
 		if err != nil {
-			sendError(w, err.Error(), http.StatusBadRequest)
+			sendError(w, r, errBadRequest(err.Error()))
 			return
 		}
 	} else if r.Method == "POST" {
-		if r.Header.Get("Content-Type") == "application/json" {
+		limitBody(w, r)
+
+		mediaType, err := requestMediaType(r)
+
+		if err != nil {
+			sendError(w, r, newAPIError(http.StatusUnsupportedMediaType, codeUnsupportedMediaType, err.Error()))
+			return
+		}
+
+		switch mediaType {
+		case mediaTypeJSON:
 			buf, err := io.ReadAll(r.Body)
 
 			if err != nil {
-				sendError(w, err.Error(), http.StatusBadRequest)
+				sendError(w, r, bodyError(err))
 				return
 			}
 
 			err = json.Unmarshal(buf, &data)
 
 			if err != nil {
-				sendError(w, err.Error(), http.StatusBadRequest)
+				sendError(w, r, errBadRequest(err.Error()))
 				return
 			}
-		} else if r.Header.Get("Content-Type") == "application/x-www-form-urlencoded" {
+		case mediaTypeForm:
+			simpleRequest = true
+
 			err := r.ParseForm()
 
 			if err != nil {
-				sendError(w, err.Error(), http.StatusBadRequest)
+				sendError(w, r, bodyError(err))
 				return
 			}
 
@@ -85,20 +156,27 @@ func ApiHandler(w http.ResponseWriter, r *http.Request) {
 			for key, value := range r.PostForm {
 				data[key] = value[0]
 			}
-		} else {
-			sendError(w, "Content-Type is not allowed", http.StatusBadRequest)
+		default:
+			sendError(w, r, newAPIError(http.StatusUnsupportedMediaType, codeUnsupportedMediaType, "Content-Type "+mediaType+" is not allowed"))
 			return
 		}
-
 	} else {
-		sendError(w, "method is not allowed", http.StatusMethodNotAllowed)
+		sendError(w, r, errMethodNotAllowed("method is not allowed"))
 		return
 	}
 
+	if simpleRequest {
+		if err := checkOrigin(r); err != nil {
+			slog.WarnContext(r.Context(), "Cross-site API call rejected", "error", err, "method", r.Method)
+			sendError(w, r, errPermissionDenied(err.Error()))
+			return
+		}
+	}
+
 	action, ok := data["action"].(string)
 
 	if !ok {
-		sendError(w, "action parameter is missing", http.StatusBadRequest)
+		sendError(w, r, errBadRequest("action parameter is missing"))
 		return
 	}
 
@@ -106,91 +184,107 @@ func ApiHandler(w http.ResponseWriter, r *http.Request) {
 
 	// check if action exists
 	if _, ok := apiActions[action]; !ok {
-		sendError(w, "action parameter is invalid", http.StatusBadRequest)
+		sendError(w, r, errBadRequest("action parameter is invalid"))
+		return
+	}
+
+	w, r, done := instrumentAction(w, r, action)
+	defer done()
+
+	if apiActions[action].mutating && r.Method != "POST" && !config.GetConfig().GetApiAllowMutatingGet() {
+		w.Header().Set("Allow", http.MethodPost)
+		sendError(w, r, errMethodNotAllowed("mutating actions must be called with POST"))
 		return
 	}
 
+	if apiActions[action].mutating {
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		w = recorder
+		start := time.Now()
+
+		// r is replaced once the caller is authenticated, read it when the action is done.
+		defer func() {
+			auditApiAction(r, action, data, recorder.status, time.Since(start))
+		}()
+	}
+
+	var identity *Identity
+
 	// call action
 	if apiActions[action].needAuth {
-		authHeader := r.Header.Get("Authorization")
-
-		if len(authHeader) == 0 {
-			slog.Error("Authorization header is missing")
-			sendError(w, "Authorization header is missing", http.StatusUnauthorized)
+		if !checkIPRateLimit(w, r) {
 			return
 		}
 
-		parts := strings.SplitN(authHeader, " ", 2)
+		var err error
 
-		if len(parts) != 2 {
-			slog.Error("Authorization header is invalid")
-			sendError(w, "Authorization header is invalid", http.StatusUnauthorized)
+		identity, err = authenticateRequest(r)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Authentication failed", "error", err)
+			sendError(w, r, errUnauthenticated(err.Error()))
 			return
 		}
 
-		tokenType, token := parts[0], parts[1]
+		logger.SetRequestUsername(r.Context(), identity.Username)
+		r = r.WithContext(withIdentity(r.Context(), identity))
 
-		switch tokenType {
-		case "Bearer":
-			valid, err := validateToken(token)
-			if err != nil {
-				slog.Error("Token validation failed", "error", err)
-				sendError(w, "Token validation failed", http.StatusUnauthorized)
-				return
-			} else if valid {
-				slog.Info("Token is valid and user is in 'admins' group.")
-			} else {
-				slog.Error("User is not in 'admins' group")
-				sendError(w, "User is not in 'admins' group", http.StatusUnauthorized)
-				return
-			}
-		default:
-			slog.Error("Authorization header is invalid")
-			sendError(w, "Authorization header is invalid", http.StatusUnauthorized)
+		if apiActions[action].adminOnly && !identity.IsAdmin() {
+			slog.ErrorContext(r.Context(), "User is not an admin", "username", identity.Username, "action", action)
+			sendError(w, r, errPermissionDenied("User is not in '"+config.GetConfig().GetAdminGroup()+"' group"))
 			return
 		}
 	}
 
-	result := apiActions[action].action(w, r, data)
+	if !checkRateLimit(w, r, identity, apiActions[action].mutating) {
+		return
+	}
 
-	//chech if w has content type set if not set it to json
-	if w.Header().Get("Content-Type") == "" {
-		w.Header().Set("Content-Type", "application/json")
+	if errs := validateActionData(apiActions[action], data); len(errs) > 0 {
+		sendError(w, r, errValidation(errs))
+		return
+	}
+
+	run := func(w http.ResponseWriter) {
+		result := apiActions[action].action(w, r, data)
+
+		//chech if w has content type set if not set it to json
+		if w.Header().Get("Content-Type") == "" {
+			w.Header().Set("Content-Type", "application/json")
+		}
+
+		result()
 	}
 
-	result()
+	if apiActions[action].mutating {
+		withIdempotency(w, r, action, data, run)
+		return
+	}
+
+	run(w)
 }
 
 func getConfigMaps(w http.ResponseWriter, r *http.Request, data map[string]interface{}) apiActionResult {
 	return func() {
-		var config *rest.Config
-		var err error
-		config, err = rest.InClusterConfig()
-		if err != nil {
-			kubeconfig := filepath.Join(os.Getenv("HOME"), ".kube", "config")
-			config, err = clientcmd.BuildConfigFromFlags("", kubeconfig)
-			if err != nil {
-				sendError(w, "Kubeconfig error", http.StatusInternalServerError)
-				return
-			}
-		}
-		clientset, err := kubernetes.NewForConfig(config)
+		namespace, _ := data["namespace"].(string)
+
+		service, err := newClusterService(r.Context())
 		if err != nil {
-			sendError(w, "Clientset error", http.StatusInternalServerError)
+			slog.ErrorContext(r.Context(), "Error creating clientset", "error", err)
+			sendError(w, r, errInternal("Clientset error"))
 			return
 		}
-		cms, err := clientset.CoreV1().ConfigMaps("").List(r.Context(), metav1.ListOptions{})
+		cms, err := service.listConfigMaps(r.Context(), namespace, false)
 		if err != nil {
-			sendError(w, "List error", http.StatusInternalServerError)
+			sendError(w, r, kubeAPIError(r.Context(), err))
 			return
 		}
 		result := []map[string]string{}
-		for _, cm := range cms.Items {
-			result = append(result, map[string]string{"name": cm.Name})
+		for _, cm := range cms {
+			result = append(result, map[string]string{"name": cm.Name, "namespace": cm.Namespace})
 		}
 		w.Header().Set("Content-Type", "application/json")
 		if err := json.NewEncoder(w).Encode(result); err != nil {
-			slog.Error("Error encoding response", "error", err)
+			slog.ErrorContext(r.Context(), "Error encoding response", "error", err)
 		}
 	}
 }
@@ -199,33 +293,31 @@ func deleteConfigMap(w http.ResponseWriter, r *http.Request, data map[string]int
 	return func() {
 		name, ok := data["name"].(string)
 		if !ok || name == "" {
-			sendError(w, "Missing configmap name", http.StatusBadRequest)
+			sendError(w, r, errBadRequest("Missing configmap name"))
 			return
 		}
-		var config *rest.Config
-		var err error
-		config, err = rest.InClusterConfig()
-		if err != nil {
-			kubeconfig := filepath.Join(os.Getenv("HOME"), ".kube", "config")
-			config, err = clientcmd.BuildConfigFromFlags("", kubeconfig)
-			if err != nil {
-				sendError(w, "Kubeconfig error", http.StatusInternalServerError)
-				return
-			}
+		namespace, ok := data["namespace"].(string)
+		if !ok || namespace == "" {
+			sendError(w, r, errBadRequest("Missing configmap namespace"))
+			return
 		}
-		clientset, err := kubernetes.NewForConfig(config)
+		service, err := newClusterService(r.Context())
 		if err != nil {
-			sendError(w, "Clientset error", http.StatusInternalServerError)
+			slog.ErrorContext(r.Context(), "Error creating clientset", "error", err)
+			sendError(w, r, errInternal("Clientset error"))
 			return
 		}
-		err = clientset.CoreV1().ConfigMaps("").Delete(r.Context(), name, metav1.DeleteOptions{})
+		cm, err := service.getConfigMap(r.Context(), namespace, name)
+		if err == nil {
+			err = service.deleteConfigMap(r.Context(), cm, "")
+		}
 		if err != nil {
-			sendError(w, "Delete error: "+err.Error(), http.StatusInternalServerError)
+			sendError(w, r, kubeAPIError(r.Context(), err))
 			return
 		}
 		w.WriteHeader(http.StatusOK)
 		if _, err := w.Write([]byte(`{"status":"deleted"}`)); err != nil {
-			slog.Error("Error writing response", "error", err)
+			slog.ErrorContext(r.Context(), "Error writing response", "error", err)
 		}
 	}
 }
@@ -234,36 +326,32 @@ func getConfigMap(w http.ResponseWriter, r *http.Request, data map[string]interf
 	return func() {
 		name, ok := data["name"].(string)
 		if !ok || name == "" {
-			sendError(w, "Missing configmap name", http.StatusBadRequest)
+			sendError(w, r, errBadRequest("Missing configmap name"))
 			return
 		}
-		var config *rest.Config
-		var err error
-		config, err = rest.InClusterConfig()
-		if err != nil {
-			kubeconfig := filepath.Join(os.Getenv("HOME"), ".kube", "config")
-			config, err = clientcmd.BuildConfigFromFlags("", kubeconfig)
-			if err != nil {
-				sendError(w, "Kubeconfig error", http.StatusInternalServerError)
-				return
-			}
+		namespace, ok := data["namespace"].(string)
+		if !ok || namespace == "" {
+			sendError(w, r, errBadRequest("Missing configmap namespace"))
+			return
 		}
-		clientset, err := kubernetes.NewForConfig(config)
+		service, err := newClusterService(r.Context())
 		if err != nil {
-			sendError(w, "Clientset error", http.StatusInternalServerError)
+			slog.ErrorContext(r.Context(), "Error creating clientset", "error", err)
+			sendError(w, r, errInternal("Clientset error"))
 			return
 		}
-		cm, err := clientset.CoreV1().ConfigMaps("").Get(r.Context(), name, metav1.GetOptions{})
+		cm, err := service.getConfigMap(r.Context(), namespace, name)
 		if err != nil {
-			sendError(w, "Get error: "+err.Error(), http.StatusInternalServerError)
+			sendError(w, r, kubeAPIError(r.Context(), err))
 			return
 		}
 		w.Header().Set("Content-Type", "application/json")
 		if err := json.NewEncoder(w).Encode(map[string]interface{}{
-			"name": cm.Name,
-			"data": cm.Data,
+			"name":      cm.Name,
+			"namespace": cm.Namespace,
+			"data":      cm.Data,
 		}); err != nil {
-			slog.Error("Error encoding response", "error", err)
+			slog.ErrorContext(r.Context(), "Error encoding response", "error", err)
 		}
 	}
 }
@@ -272,58 +360,43 @@ func updateConfigMap(w http.ResponseWriter, r *http.Request, data map[string]int
 	return func() {
 		name, ok := data["name"].(string)
 		if !ok || name == "" {
-			sendError(w, "Missing configmap name", http.StatusBadRequest)
+			sendError(w, r, errBadRequest("Missing configmap name"))
+			return
+		}
+		namespace, ok := data["namespace"].(string)
+		if !ok || namespace == "" {
+			sendError(w, r, errBadRequest("Missing configmap namespace"))
 			return
 		}
 		rawData, ok := data["data"]
 		if !ok {
-			sendError(w, "Missing configmap data", http.StatusBadRequest)
+			sendError(w, r, errBadRequest("Missing configmap data"))
 			return
 		}
 		// Convert rawData to map[string]string
-		dataMap := map[string]string{}
-		switch v := rawData.(type) {
-		case map[string]interface{}:
-			for k, val := range v {
-				if str, ok := val.(string); ok {
-					dataMap[k] = str
-				}
-			}
-		default:
-			sendError(w, "Invalid data format", http.StatusBadRequest)
+		dataMap, ok := stringMap(rawData)
+		if !ok {
+			sendError(w, r, errBadRequest("Invalid data format"))
 			return
 		}
 
-		var config *rest.Config
-		var err error
-		config, err = rest.InClusterConfig()
-		if err != nil {
-			kubeconfig := filepath.Join(os.Getenv("HOME"), ".kube", "config")
-			config, err = clientcmd.BuildConfigFromFlags("", kubeconfig)
-			if err != nil {
-				sendError(w, "Kubeconfig error", http.StatusInternalServerError)
-				return
-			}
-		}
-		clientset, err := kubernetes.NewForConfig(config)
+		service, err := newClusterService(r.Context())
 		if err != nil {
-			sendError(w, "Clientset error", http.StatusInternalServerError)
+			slog.ErrorContext(r.Context(), "Error creating clientset", "error", err)
+			sendError(w, r, errInternal("Clientset error"))
 			return
 		}
-		cm, err := clientset.CoreV1().ConfigMaps("").Get(r.Context(), name, metav1.GetOptions{})
-		if err != nil {
-			sendError(w, "Get error: "+err.Error(), http.StatusInternalServerError)
-			return
+		cm, err := service.getConfigMap(r.Context(), namespace, name)
+		if err == nil {
+			_, err = service.updateConfigMap(r.Context(), cm, dataMap, "")
 		}
-		cm.Data = dataMap
-		_, err = clientset.CoreV1().ConfigMaps("").Update(r.Context(), cm, metav1.UpdateOptions{})
 		if err != nil {
-			sendError(w, "Update error: "+err.Error(), http.StatusInternalServerError)
+			sendError(w, r, kubeAPIError(r.Context(), err))
 			return
 		}
 		w.WriteHeader(http.StatusOK)
 		if _, err := w.Write([]byte(`{"status":"updated"}`)); err != nil {
-			slog.Error("Error writing response", "error", err)
+			slog.ErrorContext(r.Context(), "Error writing response", "error", err)
 		}
 	}
 }
@@ -0,0 +1,123 @@
+/**
+ * This work is licensed under Apache License, Version 2.0 or later.
+ * Please read and understand latest version of Licence.
+ */
+package webserver
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/kazimsarikaya/assesmentbarkinrl/internal/audit"
+)
+
+// statusRecorder remembers the status code written by an action.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (sr *statusRecorder) WriteHeader(statusCode int) {
+	sr.status = statusCode
+	sr.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (sr *statusRecorder) Unwrap() http.ResponseWriter {
+	return sr.ResponseWriter
+}
+
+// Flush keeps the recorder usable for streaming responses.
+func (sr *statusRecorder) Flush() {
+	_ = http.NewResponseController(sr.ResponseWriter).Flush()
+}
+
+// payloadDigest returns the sha256 of the canonical JSON of the request data.
+// encoding/json sorts map keys, so equal payloads have equal digests.
+func payloadDigest(data map[string]interface{}) string {
+	buf, err := json.Marshal(data)
+	if err != nil {
+		return ""
+	}
+
+	sum := sha256.Sum256(buf)
+
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+func auditOutcome(status int) string {
+	switch {
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return audit.OutcomeDenied
+	case status >= http.StatusBadRequest:
+		return audit.OutcomeFailure
+	default:
+		return audit.OutcomeSuccess
+	}
+}
+
+// auditApiAction writes the audit record of a mutating action.
+func auditApiAction(r *http.Request, action string, data map[string]interface{}, status int, latency time.Duration) {
+	record := audit.Record{
+		Time:          time.Now().UTC(),
+		Username:      "-",
+		Action:        action,
+		PayloadDigest: payloadDigest(data),
+		Outcome:       auditOutcome(status),
+		Status:        status,
+		Latency:       float64(latency.Microseconds()) / 1000,
+	}
+
+	if identity, ok := IdentityFromContext(r.Context()); ok {
+		record.Subject = identity.Subject
+		record.Username = identity.Username
+	}
+
+	record.Namespace, _ = data["namespace"].(string)
+	record.Name, _ = data["name"].(string)
+
+	audit.Log(r.Context(), record)
+}
+
+func getAuditLog(w http.ResponseWriter, r *http.Request, data map[string]interface{}) apiActionResult {
+	return func() {
+		filter := audit.Filter{Limit: 100}
+
+		filter.Username, _ = data["username"].(string)
+		filter.Action, _ = data["audit_action"].(string)
+		filter.Namespace, _ = data["namespace"].(string)
+		filter.Name, _ = data["name"].(string)
+		filter.Outcome, _ = data["outcome"].(string)
+
+		switch limit := data["limit"].(type) {
+		case float64:
+			filter.Limit = int(limit)
+		case string:
+			l, err := strconv.Atoi(limit)
+			if err != nil {
+				sendError(w, r, errBadRequest("Invalid limit"))
+				return
+			}
+
+			filter.Limit = l
+		}
+
+		if since, ok := data["since"].(string); ok && since != "" {
+			t, err := time.Parse(time.RFC3339, since)
+			if err != nil {
+				sendError(w, r, errBadRequest("Invalid since, expected RFC3339"))
+				return
+			}
+
+			filter.Since = t
+		}
+
+		if err := json.NewEncoder(w).Encode(audit.Query(filter)); err != nil {
+			slog.ErrorContext(r.Context(), "Error encoding response", "error", err)
+		}
+	}
+}
@@ -6,6 +6,11 @@ package webserver
 
 import (
 	"context"
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
 	"crypto/rsa"
 	"encoding/base64"
 	"encoding/json"
@@ -18,6 +23,7 @@ import (
 
 	jwt "github.com/golang-jwt/jwt/v5"
 	"github.com/kazimsarikaya/assesmentbarkinrl/internal/config"
+	"github.com/kazimsarikaya/assesmentbarkinrl/internal/tracing"
 )
 
 type OIDCConfig struct {
@@ -29,9 +35,14 @@ type OIDCConfig struct {
 // JWK represents a JSON Web Key.
 type JWK struct {
 	Kid string `json:"kid"`
+	Kty string `json:"kty"`
 	Alg string `json:"alg"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
 	N   string `json:"n"`
 	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
 }
 
 // JWKS represents a JSON Web Key Set.
@@ -43,7 +54,7 @@ func fetchOIDCConfig(ctx context.Context, wellKnownURL string) (*OIDCConfig, err
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, wellKnownURL, nil)
 
 	if err != nil {
-		slog.Debug("Failed to create request", "error", err)
+		slog.DebugContext(ctx, "Failed to create request", "error", err)
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
@@ -52,21 +63,21 @@ func fetchOIDCConfig(ctx context.Context, wellKnownURL string) (*OIDCConfig, err
 	resp, err := http.DefaultClient.Do(req)
 
 	if err != nil {
-		slog.Debug("Failed to fetch OIDC configuration", "error", err)
+		slog.DebugContext(ctx, "Failed to fetch OIDC configuration", "error", err)
 		return nil, fmt.Errorf("failed to fetch OIDC configuration: %w", err)
 	}
 
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		slog.Debug("Failed to fetch OIDC configuration", "status", resp.Status)
+		slog.DebugContext(ctx, "Failed to fetch OIDC configuration", "status", resp.Status)
 		return nil, fmt.Errorf("failed to fetch OIDC configuration, status: %s", resp.Status)
 	}
 
 	var config OIDCConfig
 
 	if err := json.NewDecoder(resp.Body).Decode(&config); err != nil {
-		slog.Debug("Failed to decode OIDC configuration", "error", err)
+		slog.DebugContext(ctx, "Failed to decode OIDC configuration", "error", err)
 		return nil, fmt.Errorf("failed to decode OIDC configuration: %w", err)
 	}
 
@@ -78,7 +89,7 @@ func fetchJWKS(ctx context.Context, jwksURL string) (*JWKS, error) {
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, jwksURL, nil)
 
 	if err != nil {
-		slog.Debug("Failed to create request", "error", err)
+		slog.DebugContext(ctx, "Failed to create request", "error", err)
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
@@ -87,29 +98,43 @@ func fetchJWKS(ctx context.Context, jwksURL string) (*JWKS, error) {
 	resp, err := http.DefaultClient.Do(req)
 
 	if err != nil {
-		slog.Debug("Failed to fetch JWKS", "error", err)
+		slog.DebugContext(ctx, "Failed to fetch JWKS", "error", err)
 		return nil, fmt.Errorf("failed to fetch JWKS: %w", err)
 	}
 
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		slog.Debug("Failed to fetch JWKS", "status", resp.Status)
+		slog.DebugContext(ctx, "Failed to fetch JWKS", "status", resp.Status)
 		return nil, fmt.Errorf("failed to fetch JWKS, status: %s", resp.Status)
 	}
 
 	var jwks JWKS
 
 	if err := json.NewDecoder(resp.Body).Decode(&jwks); err != nil {
-		slog.Debug("Failed to decode JWKS", "error", err)
+		slog.DebugContext(ctx, "Failed to decode JWKS", "error", err)
 		return nil, fmt.Errorf("failed to decode JWKS: %w", err)
 	}
 
 	return &jwks, nil
 }
 
-// ConvertJWKToPublicKey converts a JWK to an RSA public key.
-func convertJWKToPublicKey(jwk JWK) (*rsa.PublicKey, error) {
+// convertJWKToPublicKey converts a JWK to an RSA, ECDSA or Ed25519 public key.
+func convertJWKToPublicKey(jwk JWK) (crypto.PublicKey, error) {
+	switch jwk.Kty {
+	case "RSA", "":
+		return convertRSAJWK(jwk)
+	case "EC":
+		return convertECJWK(jwk)
+	case "OKP":
+		return convertOKPJWK(jwk)
+	default:
+		slog.Debug("Unsupported key type", "kty", jwk.Kty)
+		return nil, fmt.Errorf("unsupported key type: %s", jwk.Kty)
+	}
+}
+
+func convertRSAJWK(jwk JWK) (*rsa.PublicKey, error) {
 	// Decode the modulus and exponent
 	nBytes, err := base64.RawURLEncoding.DecodeString(jwk.N)
 	if err != nil {
@@ -138,98 +163,179 @@ func convertJWKToPublicKey(jwk JWK) (*rsa.PublicKey, error) {
 	return publicKey, nil
 }
 
-// KeyFunc returns the signing key.
-func KeyFunc(ctx context.Context, jwksURL string) jwt.Keyfunc {
+type ecCurve struct {
+	curve elliptic.Curve
+	ecdh  ecdh.Curve
+}
+
+var ecCurves = map[string]ecCurve{
+	"P-256": {curve: elliptic.P256(), ecdh: ecdh.P256()},
+	"P-384": {curve: elliptic.P384(), ecdh: ecdh.P384()},
+	"P-521": {curve: elliptic.P521(), ecdh: ecdh.P521()},
+}
+
+func convertECJWK(jwk JWK) (*ecdsa.PublicKey, error) {
+	c, ok := ecCurves[jwk.Crv]
+	if !ok {
+		slog.Debug("Unsupported EC curve", "crv", jwk.Crv)
+		return nil, fmt.Errorf("unsupported EC curve: %s", jwk.Crv)
+	}
+
+	xBytes, err := base64.RawURLEncoding.DecodeString(jwk.X)
+	if err != nil {
+		slog.Debug("Failed to decode x coordinate", "error", err)
+		return nil, fmt.Errorf("failed to decode x coordinate: %w", err)
+	}
+
+	yBytes, err := base64.RawURLEncoding.DecodeString(jwk.Y)
+	if err != nil {
+		slog.Debug("Failed to decode y coordinate", "error", err)
+		return nil, fmt.Errorf("failed to decode y coordinate: %w", err)
+	}
+
+	size := (c.curve.Params().BitSize + 7) / 8
+	if len(xBytes) != size || len(yBytes) != size {
+		slog.Debug("Invalid EC coordinate length", "crv", jwk.Crv)
+		return nil, fmt.Errorf("invalid EC coordinate length for curve %s", jwk.Crv)
+	}
+
+	// Let crypto/ecdh validate that the point is on the curve.
+	point := append([]byte{4}, append(xBytes, yBytes...)...)
+
+	if _, err := c.ecdh.NewPublicKey(point); err != nil {
+		slog.Debug("Invalid EC point", "error", err)
+		return nil, fmt.Errorf("invalid EC point: %w", err)
+	}
+
+	publicKey := &ecdsa.PublicKey{
+		Curve: c.curve,
+		X:     new(big.Int).SetBytes(xBytes),
+		Y:     new(big.Int).SetBytes(yBytes),
+	}
+
+	return publicKey, nil
+}
+
+func convertOKPJWK(jwk JWK) (ed25519.PublicKey, error) {
+	if jwk.Crv != "Ed25519" {
+		slog.Debug("Unsupported OKP curve", "crv", jwk.Crv)
+		return nil, fmt.Errorf("unsupported OKP curve: %s", jwk.Crv)
+	}
+
+	xBytes, err := base64.RawURLEncoding.DecodeString(jwk.X)
+	if err != nil {
+		slog.Debug("Failed to decode x coordinate", "error", err)
+		return nil, fmt.Errorf("failed to decode x coordinate: %w", err)
+	}
+
+	if len(xBytes) != ed25519.PublicKeySize {
+		slog.Debug("Invalid Ed25519 key length", "length", len(xBytes))
+		return nil, fmt.Errorf("invalid Ed25519 key length: %d", len(xBytes))
+	}
+
+	return ed25519.PublicKey(xBytes), nil
+}
+
+// KeyFunc returns the signing key from the JWKS cache.
+func KeyFunc(ctx context.Context) jwt.Keyfunc {
 	return func(token *jwt.Token) (interface{}, error) {
 		// Get the key ID from the token.
 		kid, ok := token.Header["kid"].(string)
 		if !ok {
-			slog.Debug("Missing kid in token header")
+			slog.DebugContext(ctx, "Missing kid in token header")
 			return nil, errors.New("missing kid in token header")
 		}
 
-		// Fetch JWKS.
-		jwks, err := fetchJWKS(ctx, jwksURL)
+		jwk, err := keyCache.getKey(ctx, kid)
 		if err != nil {
-			slog.Debug("Failed to fetch JWKS", "error", err)
-			return nil, fmt.Errorf("failed to fetch JWKS: %w", err)
+			slog.DebugContext(ctx, "Failed to get signing key", "kid", kid, "error", err)
+			return nil, fmt.Errorf("failed to get signing key: %w", err)
 		}
 
-		// Find the corresponding key.
-		for _, jwk := range jwks.Keys {
-			if jwk.Kid == kid {
-				// Convert the JWK to an RSA public key.
-				return convertJWKToPublicKey(jwk)
-			}
+		if jwk.Use != "" && jwk.Use != "sig" {
+			slog.DebugContext(ctx, "Key is not a signing key", "kid", kid, "use", jwk.Use)
+			return nil, fmt.Errorf("key %s is not a signing key", kid)
+		}
+
+		// A key pinned to an algorithm must only verify that algorithm.
+		if jwk.Alg != "" && jwk.Alg != token.Method.Alg() {
+			slog.DebugContext(ctx, "Token algorithm does not match key", "kid", kid, "key_alg", jwk.Alg, "token_alg", token.Method.Alg())
+			return nil, fmt.Errorf("token algorithm %s does not match key algorithm %s", token.Method.Alg(), jwk.Alg)
 		}
 
-		slog.Debug("Key not found for kid", "kid", kid)
-		return nil, fmt.Errorf("key not found for kid: %s", kid)
+		return convertJWKToPublicKey(jwk)
 	}
 }
 
-func validateToken(tokenString string) (bool, error) {
-	ctx := context.Background()
+var (
+	errInvalidIssuer   = errors.New("invalid issuer")
+	errInvalidAudience = errors.New("invalid audience")
+)
+
+// validateToken validates the bearer token and returns the caller's identity.
+func validateToken(ctx context.Context, tokenString string) (identity *Identity, err error) {
+	ctx, span := tracing.Start(ctx, "auth.validate_token")
+	defer func() { tracing.End(span, err) }()
+
+	// JWKS refreshes are shared by concurrent requests, a client going away
+	// must not cancel them. The values, like the request id, are kept.
+	ctx = context.WithoutCancel(ctx)
 
 	config := config.GetConfig()
 
 	if config.GetOidcIssuer() == "" {
-		slog.Debug("OIDC issuer not set")
-		return false, errors.New("OIDC issuer not set")
+		slog.DebugContext(ctx, "OIDC issuer not set")
+		return nil, errors.New("OIDC issuer not set")
 	}
 
 	if config.GetOidcAudience() == "" {
-		slog.Debug("OIDC audience not set")
-		return false, errors.New("OIDC audience not set")
+		slog.DebugContext(ctx, "OIDC audience not set")
+		return nil, errors.New("OIDC audience not set")
 	}
 
-	wellKnownURL := config.GetOidcIssuer() + "/.well-known/openid-configuration"
-
-	// Fetch OIDC configuration
-	oidcConfig, err := fetchOIDCConfig(ctx, wellKnownURL)
-	if err != nil {
-		slog.Debug("Failed to fetch OIDC configuration", "error", err)
-		return false, fmt.Errorf("failed to fetch OIDC configuration: %w", err)
+	if len(config.GetOidcAllowedAlgs()) == 0 {
+		slog.DebugContext(ctx, "OIDC allowed algorithms not set")
+		return nil, errors.New("OIDC allowed algorithms not set")
 	}
 
-	// Parse the token with the KeyFunc.
-	token, err := jwt.Parse(tokenString, KeyFunc(ctx, oidcConfig.JwksURI))
+	// Parse the token with the KeyFunc, only accepting the configured algorithms.
+	token, err := jwt.Parse(tokenString, KeyFunc(ctx), jwt.WithValidMethods(config.GetOidcAllowedAlgs()))
 	if err != nil {
-		slog.Debug("Token validation failed", "error", err)
-		return false, fmt.Errorf("token validation failed: %w", err)
+		slog.DebugContext(ctx, "Token validation failed", "error", err)
+		return nil, fmt.Errorf("token validation failed: %w", err)
 	}
 
 	// Ensure token is valid
 	if !token.Valid {
-		slog.Debug("Invalid token")
-		return false, errors.New("invalid token")
+		slog.DebugContext(ctx, "Invalid token")
+		return nil, errors.New("invalid token")
 	}
 
 	// Extract claims
 	claims, ok := token.Claims.(jwt.MapClaims)
 	if !ok {
-		slog.Debug("Failed to parse token claims")
-		return false, errors.New("failed to parse token claims")
+		slog.DebugContext(ctx, "Failed to parse token claims")
+		return nil, errors.New("failed to parse token claims")
 	}
 
 	// Validate expiration (`exp` claim).
 	if exp, ok := claims["exp"].(float64); ok {
 		expirationTime := time.Unix(int64(exp), 0)
 		if time.Now().After(expirationTime) {
-			slog.Debug("Token has expired")
-			return false, fmt.Errorf("token has expired")
+			slog.DebugContext(ctx, "Token has expired")
+			return nil, jwt.ErrTokenExpired
 		}
 	} else {
-		slog.Debug("Missing or invalid exp claim")
-		return false, fmt.Errorf("missing or invalid exp claim")
+		slog.DebugContext(ctx, "Missing or invalid exp claim")
+		return nil, fmt.Errorf("%w: missing or invalid exp claim", jwt.ErrTokenInvalidClaims)
 	}
 
 	// Optional: Validate "nbf" (not before) claim.
 	if nbf, ok := claims["nbf"].(float64); ok {
 		notBeforeTime := time.Unix(int64(nbf), 0)
 		if time.Now().Before(notBeforeTime) {
-			slog.Debug("Token is not yet valid")
-			return false, fmt.Errorf("token is not yet valid")
+			slog.DebugContext(ctx, "Token is not yet valid")
+			return nil, jwt.ErrTokenNotValidYet
 		}
 	}
 
@@ -237,15 +343,15 @@ func validateToken(tokenString string) (bool, error) {
 	if iat, ok := claims["iat"].(float64); ok {
 		issuedAtTime := time.Unix(int64(iat), 0)
 		if time.Now().Before(issuedAtTime) {
-			slog.Debug("Token issued in the future")
-			return false, fmt.Errorf("token issued in the future")
+			slog.DebugContext(ctx, "Token issued in the future")
+			return nil, jwt.ErrTokenUsedBeforeIssued
 		}
 	}
 
 	// Validate claims
 	if claims["iss"] != config.GetOidcIssuer() {
-		slog.Debug("Invalid issuer", "issuer", claims["iss"])
-		return false, errors.New("invalid issuer")
+		slog.DebugContext(ctx, "Invalid issuer", "issuer", claims["iss"])
+		return nil, errInvalidIssuer
 	}
 
 	validAudience := false
@@ -265,28 +371,19 @@ func validateToken(tokenString string) (bool, error) {
 	}
 
 	if !validAudience {
-		slog.Debug("Invalid audience", "audience", claims["aud"])
-		return false, errors.New("invalid audience")
-	}
-
-	// Get username
-
-	username, ok := claims["preferred_username"].(string)
-
-	if !ok {
-		slog.Debug("Username not found")
-		return false, errors.New("username not found")
+		slog.DebugContext(ctx, "Invalid audience", "audience", claims["aud"])
+		return nil, errInvalidAudience
 	}
 
-	// Check if user is in the "admins" group
-	groups, ok := claims["groups"].([]interface{})
-	if !ok {
-		return false, errors.New("groups claim not found or invalid")
+	identity, err = identityFromClaims(claims)
+	if err != nil {
+		slog.DebugContext(ctx, "Invalid identity claims", "error", err)
+		return nil, err
 	}
 
-	slog.Debug("Groups", "username", username, "groups", groups)
+	slog.DebugContext(ctx, "Groups", "username", identity.Username, "groups", identity.Groups)
 
-	return true, nil
+	return identity, nil
 }
 
 // end of file
@@ -0,0 +1,267 @@
+/**
+ * This work is licensed under Apache License, Version 2.0 or later.
+ * Please read and understand latest version of Licence.
+ */
+package webserver
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v5"
+	"github.com/kazimsarikaya/assesmentbarkinrl/internal/config"
+	"github.com/spf13/viper"
+)
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func rsaJWK(kid, alg string, key *rsa.PublicKey) JWK {
+	return JWK{Kid: kid, Kty: "RSA", Alg: alg, Use: "sig", N: b64(key.N.Bytes()), E: b64(big.NewInt(int64(key.E)).Bytes())}
+}
+
+func ecJWK(kid, alg, crv string, key *ecdsa.PublicKey) JWK {
+	size := (key.Curve.Params().BitSize + 7) / 8
+
+	return JWK{Kid: kid, Kty: "EC", Alg: alg, Use: "sig", Crv: crv, X: b64(key.X.FillBytes(make([]byte, size))), Y: b64(key.Y.FillBytes(make([]byte, size)))}
+}
+
+func okpJWK(kid string, key ed25519.PublicKey) JWK {
+	return JWK{Kid: kid, Kty: "OKP", Alg: "EdDSA", Use: "sig", Crv: "Ed25519", X: b64(key)}
+}
+
+func TestConvertJWKToPublicKey(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p384Key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p521Key, err := ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// RFC 7515 appendix A.3
+	rfcP256 := JWK{Kty: "EC", Crv: "P-256", X: "f83OJ3D2xF1Bg8vub9tLe1gHMzV76e8Tus9uPHvRVEU", Y: "x_FEzRu9m36HLN_tue659LNpXW6pCyStikYjKIWI5a0"}
+	// RFC 8037 appendix A.2
+	rfcEd25519 := JWK{Kty: "OKP", Crv: "Ed25519", X: "11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo"}
+
+	offCurve := rfcP256
+	offCurve.Y = rfcP256.X
+
+	shortX := rfcP256
+	shortX.X = b64(make([]byte, 31))
+
+	tests := []struct {
+		name    string
+		jwk     JWK
+		want    crypto.PublicKey
+		wantErr string
+	}{
+		{name: "rsa", jwk: rsaJWK("", "RS256", &rsaKey.PublicKey), want: &rsaKey.PublicKey},
+		{name: "rsa without kty", jwk: JWK{N: b64(rsaKey.N.Bytes()), E: "AQAB"}, want: &rsaKey.PublicKey},
+		{name: "rsa invalid modulus", jwk: JWK{Kty: "RSA", N: "not base64!", E: "AQAB"}, wantErr: "modulus"},
+		{name: "rsa invalid exponent", jwk: JWK{Kty: "RSA", N: b64(rsaKey.N.Bytes()), E: "@@"}, wantErr: "exponent"},
+		{name: "ec p-256 rfc 7515", jwk: rfcP256},
+		{name: "ec p-384", jwk: ecJWK("", "ES384", "P-384", &p384Key.PublicKey), want: &p384Key.PublicKey},
+		{name: "ec p-521", jwk: ecJWK("", "ES512", "P-521", &p521Key.PublicKey), want: &p521Key.PublicKey},
+		{name: "ec unsupported curve", jwk: JWK{Kty: "EC", Crv: "secp256k1", X: rfcP256.X, Y: rfcP256.Y}, wantErr: "unsupported EC curve"},
+		{name: "ec curve mismatch", jwk: JWK{Kty: "EC", Crv: "P-384", X: rfcP256.X, Y: rfcP256.Y}, wantErr: "coordinate length"},
+		{name: "ec point not on curve", jwk: offCurve, wantErr: "invalid EC point"},
+		{name: "ec short coordinate", jwk: shortX, wantErr: "coordinate length"},
+		{name: "ed25519 rfc 8037", jwk: rfcEd25519},
+		{name: "okp unsupported curve", jwk: JWK{Kty: "OKP", Crv: "X25519", X: rfcEd25519.X}, wantErr: "unsupported OKP curve"},
+		{name: "okp short key", jwk: JWK{Kty: "OKP", Crv: "Ed25519", X: b64(make([]byte, 31))}, wantErr: "key length"},
+		{name: "unsupported key type", jwk: JWK{Kty: "oct"}, wantErr: "unsupported key type"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := convertJWKToPublicKey(tt.jwk)
+
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if tt.want != nil && !got.(interface{ Equal(crypto.PublicKey) bool }).Equal(tt.want) {
+				t.Fatalf("key = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// testIssuer serves the discovery document and keys of an identity provider.
+func testIssuer(t *testing.T, keys []JWK) string {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(OIDCConfig{JwksURI: srv.URL + "/jwks"})
+	})
+
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(JWKS{Keys: keys})
+	})
+
+	return srv.URL
+}
+
+// setTestConfig publishes a config with the given options.
+func setTestConfig(t *testing.T, options map[string]interface{}) {
+	t.Helper()
+
+	for key, value := range options {
+		viper.Set(key, value)
+	}
+
+	config.GetConfigBuilder().SyncConfig()
+
+	t.Cleanup(func() {
+		for key := range options {
+			viper.Set(key, nil)
+		}
+
+		config.GetConfigBuilder().SyncConfig()
+	})
+}
+
+func TestKeyFunc(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	edPublic, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encKey := rsaJWK("enc", "RS256", &rsaKey.PublicKey)
+	encKey.Use = "enc"
+
+	issuer := testIssuer(t, []JWK{
+		rsaJWK("rsa", "RS256", &rsaKey.PublicKey),
+		rsaJWK("rsa-any", "", &rsaKey.PublicKey),
+		ecJWK("ec", "ES256", "P-256", &ecKey.PublicKey),
+		okpJWK("ed", edPublic),
+		encKey,
+	})
+
+	setTestConfig(t, map[string]interface{}{
+		"oidcIssuer":             issuer,
+		"oidcAudience":           "api",
+		"oidcAllowedAlgs":        []string{"RS256", "PS256", "ES256", "EdDSA"},
+		"jwksCacheTTL":           time.Hour,
+		"jwksMinRefreshInterval": time.Hour,
+	})
+
+	keyCache = &jwksCache{}
+
+	sign := func(method jwt.SigningMethod, kid string, key interface{}) string {
+		token := jwt.NewWithClaims(method, jwt.MapClaims{
+			"iss":                issuer,
+			"aud":                "api",
+			"sub":                "user-1",
+			"preferred_username": "user",
+			"groups":             []string{"users"},
+			"exp":                time.Now().Add(time.Minute).Unix(),
+		})
+
+		if kid != "" {
+			token.Header["kid"] = kid
+		}
+
+		signed, err := token.SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return signed
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr string
+	}{
+		{name: "rs256", token: sign(jwt.SigningMethodRS256, "rsa", rsaKey)},
+		{name: "es256", token: sign(jwt.SigningMethodES256, "ec", ecKey)},
+		{name: "eddsa", token: sign(jwt.SigningMethodEdDSA, "ed", edKey)},
+		{name: "key without alg", token: sign(jwt.SigningMethodPS256, "rsa-any", rsaKey)},
+		{name: "alg does not match key", token: sign(jwt.SigningMethodPS256, "rsa", rsaKey), wantErr: "does not match key algorithm"},
+		{name: "alg not allowed", token: sign(jwt.SigningMethodRS512, "rsa-any", rsaKey), wantErr: "signing method RS512 is invalid"},
+		{name: "encryption key", token: sign(jwt.SigningMethodRS256, "enc", rsaKey), wantErr: "not a signing key"},
+		{name: "missing kid", token: sign(jwt.SigningMethodRS256, "", rsaKey), wantErr: "missing kid"},
+		{name: "unknown kid", token: sign(jwt.SigningMethodRS256, "other", rsaKey), wantErr: "key not found"},
+		{name: "wrong key", token: sign(jwt.SigningMethodES256, "ec", mustECKey(t)), wantErr: "verification error"},
+		{name: "none", token: sign(jwt.SigningMethodNone, "rsa", jwt.UnsafeAllowNoneSignatureType), wantErr: "signing method none is invalid"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			identity, err := validateToken(context.Background(), tt.token)
+
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if identity.Subject != "user-1" || identity.Username != "user" {
+				t.Fatalf("identity = %+v", identity)
+			}
+		})
+	}
+}
+
+func mustECKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return key
+}
@@ -0,0 +1,238 @@
+/**
+ * This work is licensed under Apache License, Version 2.0 or later.
+ * Please read and understand latest version of Licence.
+ */
+package webserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/kazimsarikaya/assesmentbarkinrl/internal/config"
+	"github.com/kazimsarikaya/assesmentbarkinrl/internal/metrics"
+)
+
+var (
+	batchItemSchema = &jsonSchema{
+		Type:        "object",
+		Description: "Sub-action with its data, same as a single /api call",
+		Properties: map[string]*jsonSchema{
+			"action": stringSchema("Action name"),
+		},
+		Required:             []string{"action"},
+		AdditionalProperties: true,
+	}
+
+	batchRequestSchema = objectSchema("Run several actions", map[string]*jsonSchema{
+		"items": arraySchema("Sub-actions", batchItemSchema),
+	}, "items")
+
+	batchResultSchema = objectSchema("Result of a sub-action", map[string]*jsonSchema{
+		"index":  {Type: "integer", Description: "Position of the sub-action in items"},
+		"action": stringSchema("Action name"),
+		"status": {Type: "integer", Description: "HTTP status the sub-action would have returned"},
+		"result": {Description: "Response of the sub-action on success"},
+		"error":  errorSchema.Properties["error"],
+	}, "index", "action", "status")
+
+	batchResponseSchema = objectSchema("Results of a batch call", map[string]*jsonSchema{
+		"succeeded": {Type: "integer", Description: "Number of successful sub-actions"},
+		"failed":    {Type: "integer", Description: "Number of failed sub-actions"},
+		"results":   arraySchema("Results in the order of items", batchResultSchema),
+	}, "succeeded", "failed", "results")
+)
+
+func init() {
+	// batch dispatches to apiActions, so it is registered here to avoid an
+	// initialization cycle. It is mutating so an Idempotency-Key covers the
+	// whole batch, the sub-actions have no keys of their own: a retry replays
+	// the results of every item, failed ones included, without running any
+	// item again.
+	apiActions["batch"] = securedApiAction{
+		action: batchAction, needAuth: true, mutating: true,
+		summary: "Run several actions in one call. An Idempotency-Key covers the whole batch, a retry replays all results " +
+			"including failed items, retry those with a new key",
+		request: batchRequestSchema, response: batchResponseSchema,
+	}
+}
+
+// bufferedResponse collects the response of a sub-action.
+type bufferedResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func newBufferedResponse() *bufferedResponse {
+	return &bufferedResponse{header: http.Header{}, status: http.StatusOK}
+}
+
+func (br *bufferedResponse) Header() http.Header {
+	return br.header
+}
+
+func (br *bufferedResponse) WriteHeader(statusCode int) {
+	br.status = statusCode
+}
+
+func (br *bufferedResponse) Write(b []byte) (int, error) {
+	return br.body.Write(b)
+}
+
+type batchResult struct {
+	Index  int             `json:"index"`
+	Action string          `json:"action"`
+	Status int             `json:"status"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  *apiError       `json:"error,omitempty"`
+}
+
+// runBatchItem rate limits, authorizes, validates and runs a single sub-action
+// the same way ApiHandler does.
+func runBatchItem(r *http.Request, identity *Identity, index int, item map[string]interface{}) batchResult {
+	action, _ := item["action"].(string)
+	result := batchResult{Index: index, Action: action}
+
+	fail := func(apiErr *apiError) batchResult {
+		result.Status = apiErr.Status
+		result.Error = apiErr
+
+		return result
+	}
+
+	api, ok := apiActions[action]
+	if !ok || action == "batch" {
+		return fail(errBadRequest("action parameter is invalid"))
+	}
+
+	if err := r.Context().Err(); err != nil {
+		return fail(newAPIError(http.StatusServiceUnavailable, codeUnavailable, "request was cancelled"))
+	}
+
+	r, span := startActionSpan(r, action)
+	defer span.End()
+
+	start := time.Now()
+
+	defer func() {
+		metrics.ObserveAction(action, result.Status, time.Since(start))
+	}()
+
+	if api.mutating {
+		defer func() {
+			auditApiAction(r, action, item, result.Status, time.Since(start))
+		}()
+	}
+
+	// every sub-action costs a token of its own, the batch call itself only
+	// paid for one
+	if apiErr := takeRateLimit(r, identity, api.mutating); apiErr != nil {
+		return fail(apiErr)
+	}
+
+	if api.adminOnly && !identity.IsAdmin() {
+		return fail(errPermissionDenied("User is not in '" + config.GetConfig().GetAdminGroup() + "' group"))
+	}
+
+	if errs := validateActionData(api, item); len(errs) > 0 {
+		return fail(errValidation(errs))
+	}
+
+	response := newBufferedResponse()
+	api.action(response, r, item)()
+
+	result.Status = response.status
+
+	if response.status >= http.StatusBadRequest {
+		envelope := struct {
+			Error *apiError `json:"error"`
+		}{}
+
+		if err := json.Unmarshal(response.body.Bytes(), &envelope); err != nil || envelope.Error == nil {
+			return fail(newAPIError(response.status, codeInternal, http.StatusText(response.status)))
+		}
+
+		return fail(envelope.Error)
+	}
+
+	if body := bytes.TrimSpace(response.body.Bytes()); json.Valid(body) {
+		result.Result = body
+	}
+
+	return result
+}
+
+func batchAction(w http.ResponseWriter, r *http.Request, data map[string]interface{}) apiActionResult {
+	return func() {
+		cfg := config.GetConfig()
+
+		identity, ok := IdentityFromContext(r.Context())
+		if !ok {
+			sendError(w, r, errUnauthenticated("identity not found"))
+			return
+		}
+
+		rawItems, _ := data["items"].([]interface{})
+
+		if maxItems := cfg.GetBatchMaxItems(); maxItems > 0 && len(rawItems) > maxItems {
+			sendError(w, r, errBadRequest(fmt.Sprintf("batch has more than %d items", maxItems)))
+			return
+		}
+
+		concurrency := cfg.GetBatchConcurrency()
+		if concurrency < 1 {
+			concurrency = 1
+		}
+
+		results := make([]batchResult, len(rawItems))
+		sem := make(chan struct{}, concurrency)
+
+		var wg sync.WaitGroup
+
+		for i, rawItem := range rawItems {
+			item, _ := rawItem.(map[string]interface{})
+
+			wg.Add(1)
+			sem <- struct{}{}
+
+			go func() {
+				defer wg.Done()
+				defer func() { <-sem }()
+				defer func() {
+					// the recovery middleware does not see panics of this goroutine
+					if p := recover(); p != nil {
+						slog.ErrorContext(r.Context(), "Batch item panicked", "action", item["action"], "panic", p)
+						results[i] = batchResult{Index: i, Status: http.StatusInternalServerError, Error: errInternal("internal error")}
+					}
+				}()
+
+				results[i] = runBatchItem(r, identity, i, item)
+			}()
+		}
+
+		wg.Wait()
+
+		succeeded := 0
+
+		for _, result := range results {
+			if result.Error == nil {
+				succeeded++
+			}
+		}
+
+		err := json.NewEncoder(w).Encode(map[string]interface{}{
+			"succeeded": succeeded,
+			"failed":    len(results) - succeeded,
+			"results":   results,
+		})
+
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error encoding response", "error", err)
+		}
+	}
+}
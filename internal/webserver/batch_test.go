@@ -0,0 +1,288 @@
+/**
+ * This work is licensed under Apache License, Version 2.0 or later.
+ * Please read and understand latest version of Licence.
+ */
+package webserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kazimsarikaya/assesmentbarkinrl/internal/idempotency"
+)
+
+// registerBatchTestActions adds sub-actions for the batch tests and returns
+// the number of test_write runs.
+func registerBatchTestActions(t *testing.T) *atomic.Int32 {
+	t.Helper()
+
+	writes := &atomic.Int32{}
+
+	echo := func(w http.ResponseWriter, r *http.Request, data map[string]interface{}) apiActionResult {
+		return func() {
+			// later items finish first
+			if delay, ok := data["delay"].(float64); ok {
+				time.Sleep(time.Duration(delay) * time.Millisecond)
+			}
+
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"name": data["name"]})
+		}
+	}
+
+	actions := map[string]securedApiAction{
+		"test_read": {action: echo, needAuth: true},
+		"test_write": {
+			action: func(w http.ResponseWriter, r *http.Request, data map[string]interface{}) apiActionResult {
+				writes.Add(1)
+				return echo(w, r, data)
+			},
+			needAuth: true, mutating: true,
+			request: objectSchema("Test write", map[string]*jsonSchema{
+				"name":  stringSchema("Name"),
+				"delay": {Type: "number", Description: "Milliseconds to sleep"},
+			}, "name"),
+		},
+		"test_admin": {action: echo, needAuth: true, adminOnly: true},
+		"test_fail": {
+			action: func(w http.ResponseWriter, r *http.Request, data map[string]interface{}) apiActionResult {
+				return func() { sendError(w, r, errNotFound("ConfigMap not found")) }
+			},
+			needAuth: true,
+		},
+		"test_panic": {
+			action: func(w http.ResponseWriter, r *http.Request, data map[string]interface{}) apiActionResult {
+				return func() { panic("test panic") }
+			},
+			needAuth: true,
+		},
+	}
+
+	for name, action := range actions {
+		apiActions[name] = action
+	}
+
+	t.Cleanup(func() {
+		for name := range actions {
+			delete(apiActions, name)
+		}
+	})
+
+	return writes
+}
+
+type batchResponse struct {
+	Succeeded int           `json:"succeeded"`
+	Failed    int           `json:"failed"`
+	Results   []batchResult `json:"results"`
+}
+
+// runBatch calls the batch action like ApiHandler does after authentication.
+func runBatch(t *testing.T, identity *Identity, key string, items ...map[string]interface{}) (*httptest.ResponseRecorder, batchResponse) {
+	t.Helper()
+
+	rawItems := []interface{}{}
+	for _, item := range items {
+		rawItems = append(rawItems, item)
+	}
+
+	data := map[string]interface{}{"action": "batch", "items": rawItems}
+
+	r := httptest.NewRequest(http.MethodPost, "/api", nil)
+	r = r.WithContext(withIdentity(r.Context(), identity))
+
+	if key != "" {
+		r.Header.Set("Idempotency-Key", key)
+	}
+
+	w := httptest.NewRecorder()
+
+	withIdempotency(w, r, "batch", data, func(w http.ResponseWriter) {
+		batchAction(w, r, data)()
+	})
+
+	var response batchResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("response %q: %v", w.Body.String(), err)
+	}
+
+	return w, response
+}
+
+func item(action string, fields ...interface{}) map[string]interface{} {
+	result := map[string]interface{}{"action": action}
+	for i := 0; i+1 < len(fields); i += 2 {
+		result[fields[i].(string)] = fields[i+1]
+	}
+
+	return result
+}
+
+func TestBatch(t *testing.T) {
+	user := &Identity{Subject: "u1", Username: "alice", Groups: []string{"dev"}}
+	admin := &Identity{Subject: "u2", Username: "root", Groups: []string{"admins"}}
+
+	tests := []struct {
+		name     string
+		identity *Identity
+		// concurrency defaults to 4, one runs the items in order
+		concurrency int
+		items       []map[string]interface{}
+		wantStatus  []int
+	}{
+		{
+			name:     "results keep the order of items",
+			identity: user,
+			items: []map[string]interface{}{
+				item("test_write", "name", "a", "delay", 60.0),
+				item("test_read", "name", "b", "delay", 30.0),
+				item("test_read", "name", "c"),
+			},
+			wantStatus: []int{http.StatusOK, http.StatusOK, http.StatusOK},
+		},
+		{
+			name:        "every write item takes a token",
+			identity:    user,
+			concurrency: 1,
+			items: []map[string]interface{}{
+				item("test_write", "name", "a"),
+				item("test_write", "name", "b"),
+				item("test_write", "name", "c"),
+				item("test_read", "name", "d"),
+			},
+			wantStatus: []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests, http.StatusOK},
+		},
+		{
+			name:       "admin actions need the admin group",
+			identity:   user,
+			items:      []map[string]interface{}{item("test_admin", "name", "a")},
+			wantStatus: []int{http.StatusForbidden},
+		},
+		{
+			name:       "admin actions for admins",
+			identity:   admin,
+			items:      []map[string]interface{}{item("test_admin", "name", "a")},
+			wantStatus: []int{http.StatusOK},
+		},
+		{
+			name:     "invalid items",
+			identity: user,
+			items: []map[string]interface{}{
+				item("test_write"),
+				item("test_write", "name", 1.0),
+				item("unknown"),
+				item("batch"),
+				item("test_fail"),
+			},
+			wantStatus: []int{http.StatusBadRequest, http.StatusBadRequest, http.StatusBadRequest, http.StatusBadRequest, http.StatusNotFound},
+		},
+		{
+			name:     "panics fail only their item",
+			identity: user,
+			items: []map[string]interface{}{
+				item("test_panic"),
+				item("test_read", "name", "a"),
+			},
+			wantStatus: []int{http.StatusInternalServerError, http.StatusOK},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setRateLimitTestConfig(t)
+			concurrency := tt.concurrency
+			if concurrency == 0 {
+				concurrency = 4
+			}
+
+			setTestConfig(t, map[string]interface{}{"adminGroup": "admins", "batchConcurrency": concurrency})
+			registerBatchTestActions(t)
+
+			_, response := runBatch(t, tt.identity, "", tt.items...)
+
+			if len(response.Results) != len(tt.wantStatus) {
+				t.Fatalf("results = %d, want %d", len(response.Results), len(tt.wantStatus))
+			}
+
+			failed := 0
+
+			for i, result := range response.Results {
+				if result.Index != i || result.Status != tt.wantStatus[i] {
+					t.Errorf("result %d = index %d status %d, want status %d", i, result.Index, result.Status, tt.wantStatus[i])
+				}
+
+				if (result.Error != nil) != (tt.wantStatus[i] >= http.StatusBadRequest) {
+					t.Errorf("result %d: error = %v", i, result.Error)
+				}
+
+				if result.Error != nil {
+					failed++
+				}
+			}
+
+			if response.Failed != failed || response.Succeeded != len(tt.wantStatus)-failed {
+				t.Errorf("succeeded = %d, failed = %d", response.Succeeded, response.Failed)
+			}
+		})
+	}
+}
+
+func TestBatchMaxItems(t *testing.T) {
+	setRateLimitTestConfig(t)
+	setTestConfig(t, map[string]interface{}{"batchMaxItems": 1})
+
+	data := map[string]interface{}{"items": []interface{}{item("test_read"), item("test_read")}}
+
+	r := httptest.NewRequest(http.MethodPost, "/api", nil)
+	r = r.WithContext(withIdentity(r.Context(), &Identity{Username: "alice"}))
+
+	w := httptest.NewRecorder()
+
+	batchAction(w, r, data)()
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
+
+func TestBatchIdempotency(t *testing.T) {
+	setRateLimitTestConfig(t)
+	setTestConfig(t, map[string]interface{}{"idempotencyTTL": time.Hour})
+
+	writes := registerBatchTestActions(t)
+
+	previous := idempotencyStore
+	idempotencyStore = idempotency.NewMemoryStore()
+
+	t.Cleanup(func() { idempotencyStore = previous })
+
+	user := &Identity{Subject: "u1", Username: "alice"}
+	items := []map[string]interface{}{item("test_write", "name", "a"), item("test_fail")}
+
+	_, first := runBatch(t, user, "k1", items...)
+
+	w, retry := runBatch(t, user, "k1", items...)
+
+	// the retry replays both results without writing again
+	if got := writes.Load(); got != 1 {
+		t.Fatalf("writes = %d, want 1", got)
+	}
+
+	if w.Header().Get("Idempotent-Replayed") != "true" {
+		t.Fatal("retry was not replayed")
+	}
+
+	if retry.Succeeded != first.Succeeded || retry.Failed != first.Failed || retry.Failed != 1 {
+		t.Fatalf("retry = %+v, first = %+v", retry, first)
+	}
+
+	// a new key runs the items again
+	runBatch(t, user, "k2", items...)
+
+	if got := writes.Load(); got != 2 {
+		t.Fatalf("writes = %d, want 2", got)
+	}
+}
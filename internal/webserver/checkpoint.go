@@ -0,0 +1,154 @@
+/**
+ * This work is licensed under Apache License, Version 2.0 or later.
+ * Please read and understand latest version of Licence.
+ */
+package webserver
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+)
+
+const checkpointTimeout = 5 * time.Second
+
+// teardownCheckpoints keeps the phase reached by running teardowns in a
+// ConfigMap. The cluster ConfigMap is already gone when a teardown starts, so
+// a teardown interrupted by a shutdown would never be enqueued again without
+// it. Phases are cached, only changes go to the API.
+type teardownCheckpoints struct {
+	clientset kubernetes.Interface
+	namespace string
+	name      string
+
+	mu     sync.Mutex
+	phases map[string]string
+}
+
+func newTeardownCheckpoints(clientset kubernetes.Interface, namespace, name string) *teardownCheckpoints {
+	return &teardownCheckpoints{
+		clientset: clientset,
+		namespace: namespace,
+		name:      name,
+		phases:    map[string]string{},
+	}
+}
+
+// checkpointDataKey maps a namespace/name key to a ConfigMap data key, "/" is
+// not allowed there and "_" is not allowed in namespaces.
+func checkpointDataKey(key string) string {
+	return strings.Replace(key, "/", "_", 1)
+}
+
+func checkpointKey(dataKey string) string {
+	return strings.Replace(dataKey, "_", "/", 1)
+}
+
+// load reads the checkpoints and returns the keys of the interrupted
+// teardowns.
+func (s *teardownCheckpoints) load(ctx context.Context) ([]string, error) {
+	cm, err := s.clientset.CoreV1().ConfigMaps(s.namespace).Get(ctx, s.name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys := []string{}
+
+	for dataKey, phase := range cm.Data {
+		key := checkpointKey(dataKey)
+		s.phases[key] = phase
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+// phase returns the checkpointed phase of a teardown, empty when there is
+// none.
+func (s *teardownCheckpoints) phase(key string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.phases[key]
+}
+
+// save records the phase a teardown is entering, an empty phase removes the
+// checkpoint. Writes are not bound to ctx, so a cancelled teardown still
+// records where it stopped.
+func (s *teardownCheckpoints) save(ctx context.Context, key, phase string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.phases[key] == phase {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), checkpointTimeout)
+	defer cancel()
+
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		configMaps := s.clientset.CoreV1().ConfigMaps(s.namespace)
+
+		cm, err := configMaps.Get(ctx, s.name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			if phase == "" {
+				return nil
+			}
+
+			cm = &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: s.name, Namespace: s.namespace},
+				Data:       map[string]string{checkpointDataKey(key): phase},
+			}
+
+			_, err = configMaps.Create(ctx, cm, metav1.CreateOptions{})
+			if apierrors.IsAlreadyExists(err) {
+				return apierrors.NewConflict(corev1.Resource("configmaps"), s.name, err)
+			}
+
+			return err
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if cm.Data == nil {
+			cm.Data = map[string]string{}
+		}
+
+		if phase == "" {
+			delete(cm.Data, checkpointDataKey(key))
+		} else {
+			cm.Data[checkpointDataKey(key)] = phase
+		}
+
+		_, err = configMaps.Update(ctx, cm, metav1.UpdateOptions{})
+
+		return err
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Error saving teardown checkpoint", "key", key, "phase", phase, "error", err)
+		return
+	}
+
+	if phase == "" {
+		delete(s.phases, key)
+	} else {
+		s.phases[key] = phase
+	}
+}
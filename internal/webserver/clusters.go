@@ -0,0 +1,153 @@
+/**
+ * This work is licensed under Apache License, Version 2.0 or later.
+ * Please read and understand latest version of Licence.
+ */
+package webserver
+
+import (
+	"context"
+	"time"
+
+	"github.com/kazimsarikaya/assesmentbarkinrl/internal/tracing"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// clusterView is the API representation of a cluster ConfigMap.
+type clusterView struct {
+	Namespace       string            `json:"namespace"`
+	Name            string            `json:"name"`
+	Data            map[string]string `json:"data"`
+	ResourceVersion string            `json:"resource_version"`
+	CreatedAt       time.Time         `json:"created_at"`
+}
+
+func newClusterView(cm *corev1.ConfigMap) clusterView {
+	data := cm.Data
+	if data == nil {
+		data = map[string]string{}
+	}
+
+	return clusterView{
+		Namespace:       cm.Namespace,
+		Name:            cm.Name,
+		Data:            data,
+		ResourceVersion: cm.ResourceVersion,
+		CreatedAt:       cm.CreationTimestamp.UTC(),
+	}
+}
+
+// clusterService implements the ConfigMap operations shared by the action
+// endpoint and the REST routes. It acts with the clientset of the request, so
+// impersonation applies to both.
+type clusterService struct {
+	clientset kubernetes.Interface
+}
+
+func newClusterService(ctx context.Context) (*clusterService, error) {
+	clientset, err := kubeClientsetFor(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &clusterService{clientset: clientset}, nil
+}
+
+// listConfigMaps lists ConfigMaps of a namespace, all namespaces when empty.
+// With clustersOnly only ConfigMaps annotated as clusters are returned.
+func (s *clusterService) listConfigMaps(ctx context.Context, namespace string, clustersOnly bool) ([]corev1.ConfigMap, error) {
+	cms, err := s.clientset.CoreV1().ConfigMaps(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	if !clustersOnly {
+		return cms.Items, nil
+	}
+
+	result := []corev1.ConfigMap{}
+
+	for _, cm := range cms.Items {
+		if isClusterConfigMap(&cm) {
+			result = append(result, cm)
+		}
+	}
+
+	return result, nil
+}
+
+func (s *clusterService) getConfigMap(ctx context.Context, namespace, name string) (*corev1.ConfigMap, error) {
+	return s.clientset.CoreV1().ConfigMaps(namespace).Get(ctx, name, metav1.GetOptions{})
+}
+
+// createCluster creates a ConfigMap annotated as a cluster, which the watcher
+// then provisions.
+func (s *clusterService) createCluster(ctx context.Context, namespace, name string, data map[string]string) (cm *corev1.ConfigMap, err error) {
+	ctx, span := startKubeSpan(ctx, "create", "configmaps", namespace, name)
+	defer func() { tracing.End(span, err) }()
+
+	cm = &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   namespace,
+			Annotations: map[string]string{annotationKey: "true"},
+		},
+		Data: data,
+	}
+
+	return s.clientset.CoreV1().ConfigMaps(namespace).Create(ctx, cm, metav1.CreateOptions{})
+}
+
+// updateConfigMap replaces the data of current, the ConfigMap as read by the
+// caller. The update fails with a conflict if the ConfigMap changed since that
+// read, or since resourceVersion when it is set.
+func (s *clusterService) updateConfigMap(ctx context.Context, current *corev1.ConfigMap, data map[string]string, resourceVersion string) (cm *corev1.ConfigMap, err error) {
+	ctx, span := startKubeSpan(ctx, "update", "configmaps", current.Namespace, current.Name)
+	defer func() { tracing.End(span, err) }()
+
+	cm = current.DeepCopy()
+
+	if resourceVersion != "" {
+		cm.ResourceVersion = resourceVersion
+	}
+
+	cm.Data = data
+
+	return s.clientset.CoreV1().ConfigMaps(cm.Namespace).Update(ctx, cm, metav1.UpdateOptions{})
+}
+
+// deleteConfigMap deletes current, the ConfigMap as read by the caller. The
+// delete fails with a conflict if the ConfigMap was replaced or changed since
+// that read, or since resourceVersion when it is set.
+func (s *clusterService) deleteConfigMap(ctx context.Context, current *corev1.ConfigMap, resourceVersion string) (err error) {
+	ctx, span := startKubeSpan(ctx, "delete", "configmaps", current.Namespace, current.Name)
+	defer func() { tracing.End(span, err) }()
+
+	if resourceVersion == "" {
+		resourceVersion = current.ResourceVersion
+	}
+
+	return s.clientset.CoreV1().ConfigMaps(current.Namespace).Delete(ctx, current.Name, metav1.DeleteOptions{
+		Preconditions: &metav1.Preconditions{UID: &current.UID, ResourceVersion: &resourceVersion},
+	})
+}
+
+// stringMap converts a decoded JSON object to ConfigMap data, only string
+// values are kept.
+func stringMap(raw interface{}) (map[string]string, bool) {
+	v, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+
+	dataMap := map[string]string{}
+
+	for k, val := range v {
+		if str, ok := val.(string); ok {
+			dataMap[k] = str
+		}
+	}
+
+	return dataMap, true
+}
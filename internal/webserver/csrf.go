@@ -0,0 +1,90 @@
+/**
+ * This work is licensed under Apache License, Version 2.0 or later.
+ * Please read and understand latest version of Licence.
+ */
+package webserver
+
+import (
+	"errors"
+	"mime"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/kazimsarikaya/assesmentbarkinrl/internal/config"
+)
+
+const (
+	mediaTypeJSON = "application/json"
+	mediaTypeForm = "application/x-www-form-urlencoded"
+)
+
+// requestMediaType parses the Content-Type of the request. Only utf-8 is
+// accepted as charset.
+func requestMediaType(r *http.Request) (string, error) {
+	contentType := r.Header.Get("Content-Type")
+	if contentType == "" {
+		return "", errors.New("content type is missing")
+	}
+
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return "", errors.New("content type is invalid")
+	}
+
+	if charset, ok := params["charset"]; ok && !strings.EqualFold(charset, "utf-8") {
+		return "", errors.New("charset " + charset + " is not supported")
+	}
+
+	return mediaType, nil
+}
+
+// sameOrigin reports whether origin is the server itself or one of the
+// configured allowed origins.
+func sameOrigin(r *http.Request, origin string) bool {
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" {
+		return false
+	}
+
+	if strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+
+	return slices.ContainsFunc(config.GetConfig().GetAllowedOrigins(), func(allowed string) bool {
+		return strings.EqualFold(strings.TrimSuffix(allowed, "/"), u.Scheme+"://"+u.Host)
+	})
+}
+
+// checkOrigin rejects cross-site requests a browser can send without a
+// preflight, i.e. form posts and GET calls. Clients that send neither
+// Sec-Fetch-Site, Origin nor Referer are not browsers and are accepted.
+func checkOrigin(r *http.Request) error {
+	switch r.Header.Get("Sec-Fetch-Site") {
+	case "same-origin", "none":
+		return nil
+	}
+
+	if origin := r.Header.Get("Origin"); origin != "" && origin != "null" {
+		if sameOrigin(r, origin) {
+			return nil
+		}
+
+		return errors.New("origin " + origin + " is not allowed")
+	}
+
+	if referer := r.Header.Get("Referer"); referer != "" {
+		if sameOrigin(r, referer) {
+			return nil
+		}
+
+		return errors.New("referer is not allowed")
+	}
+
+	if r.Header.Get("Origin") == "null" || r.Header.Get("Sec-Fetch-Site") == "cross-site" {
+		return errors.New("cross-site request is not allowed")
+	}
+
+	return nil
+}
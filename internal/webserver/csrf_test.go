@@ -0,0 +1,173 @@
+/**
+ * This work is licensed under Apache License, Version 2.0 or later.
+ * Please read and understand latest version of Licence.
+ */
+package webserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequestMediaType(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		want        string
+		wantErr     bool
+	}{
+		{name: "json", contentType: "application/json", want: mediaTypeJSON},
+		{name: "utf-8 charset", contentType: "application/json; charset=UTF-8", want: mediaTypeJSON},
+		{name: "form", contentType: "application/x-www-form-urlencoded", want: mediaTypeForm},
+		{name: "other type is returned", contentType: "text/plain", want: "text/plain"},
+		{name: "missing", contentType: "", wantErr: true},
+		{name: "invalid", contentType: "application/json; charset", wantErr: true},
+		{name: "other charset", contentType: "application/json; charset=latin1", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/api", nil)
+			if tt.contentType != "" {
+				r.Header.Set("Content-Type", tt.contentType)
+			}
+
+			got, err := requestMediaType(r)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if got != tt.want {
+				t.Fatalf("media type = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckOrigin(t *testing.T) {
+	setTestConfig(t, map[string]interface{}{
+		"allowedOrigins": []string{"https://admin.example.com/"},
+	})
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		wantErr bool
+	}{
+		{name: "no browser headers", headers: nil},
+		{name: "same origin fetch", headers: map[string]string{"Sec-Fetch-Site": "same-origin", "Origin": "https://evil.example.com"}},
+		{name: "user navigation", headers: map[string]string{"Sec-Fetch-Site": "none"}},
+		{name: "same origin", headers: map[string]string{"Origin": "https://app.example.com"}},
+		{name: "allowed origin", headers: map[string]string{"Origin": "https://admin.example.com"}},
+		{name: "allowed origin with another scheme", headers: map[string]string{"Origin": "http://admin.example.com"}, wantErr: true},
+		{name: "origin not in the list", headers: map[string]string{"Origin": "https://evil.example.com"}, wantErr: true},
+		{name: "referer fallback", headers: map[string]string{"Referer": "https://app.example.com/configmaps"}},
+		{name: "cross-site referer", headers: map[string]string{"Referer": "https://evil.example.com/page"}, wantErr: true},
+		{name: "origin wins over referer", headers: map[string]string{"Origin": "https://evil.example.com", "Referer": "https://app.example.com/"}, wantErr: true},
+		{name: "null origin", headers: map[string]string{"Origin": "null"}, wantErr: true},
+		{name: "null origin with same origin referer", headers: map[string]string{"Origin": "null", "Referer": "https://app.example.com/"}},
+		{name: "cross-site fetch without origin", headers: map[string]string{"Sec-Fetch-Site": "cross-site"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "https://app.example.com/api", nil)
+			for key, value := range tt.headers {
+				r.Header.Set(key, value)
+			}
+
+			if err := checkOrigin(r); (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestApiHandlerRejectsSimpleRequests(t *testing.T) {
+	setRateLimitTestConfig(t)
+
+	tests := []struct {
+		name        string
+		method      string
+		target      string
+		contentType string
+		body        string
+		origin      string
+		wantStatus  int
+	}{
+		{
+			name:        "wrong content type",
+			method:      http.MethodPost,
+			target:      "/api",
+			contentType: "text/plain",
+			body:        `{"action":"get_configmaps"}`,
+			wantStatus:  http.StatusUnsupportedMediaType,
+		},
+		{
+			name:       "missing content type",
+			method:     http.MethodPost,
+			target:     "/api",
+			body:       `{"action":"get_configmaps"}`,
+			wantStatus: http.StatusUnsupportedMediaType,
+		},
+		{
+			name:        "cross-site form post",
+			method:      http.MethodPost,
+			target:      "/api",
+			contentType: mediaTypeForm,
+			body:        "action=delete_configmap&name=a&namespace=default",
+			origin:      "https://evil.example.com",
+			wantStatus:  http.StatusForbidden,
+		},
+		{
+			name:       "cross-site query call",
+			method:     http.MethodGet,
+			target:     `/api?data={"action":"get_configmaps"}`,
+			origin:     "https://evil.example.com",
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			// passes the origin check and fails on the missing action
+			name:        "same origin form post",
+			method:      http.MethodPost,
+			target:      "/api",
+			contentType: mediaTypeForm,
+			body:        "name=a",
+			origin:      "https://app.example.com",
+			wantStatus:  http.StatusBadRequest,
+		},
+		{
+			// JSON needs a preflight, the origin is not checked
+			name:        "cross-site json",
+			method:      http.MethodPost,
+			target:      "/api",
+			contentType: mediaTypeJSON,
+			body:        `{"name":"a"}`,
+			origin:      "https://evil.example.com",
+			wantStatus:  http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, "https://app.example.com"+tt.target, strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				r.Header.Set("Content-Type", tt.contentType)
+			}
+
+			if tt.origin != "" {
+				r.Header.Set("Origin", tt.origin)
+			}
+
+			w := httptest.NewRecorder()
+
+			ApiHandler(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,150 @@
+/**
+ * This work is licensed under Apache License, Version 2.0 or later.
+ * Please read and understand latest version of Licence.
+ */
+package webserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/kazimsarikaya/assesmentbarkinrl/internal/logger"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// Machine readable error codes of the API.
+const (
+	codeBadRequest           = "bad_request"
+	codeValidationFailed     = "validation_failed"
+	codeUnauthenticated      = "unauthenticated"
+	codePermissionDenied     = "permission_denied"
+	codeNotFound             = "not_found"
+	codeMethodNotAllowed     = "method_not_allowed"
+	codeAlreadyExists        = "already_exists"
+	codeConflict             = "conflict"
+	codeInvalid              = "invalid"
+	codeUnsupportedMediaType = "unsupported_media_type"
+	codePayloadTooLarge      = "payload_too_large"
+	codeRateLimited          = "rate_limited"
+	codeIdempotencyKeyReused = "idempotency_key_reused"
+	codeTimeout              = "timeout"
+	codeUnavailable          = "unavailable"
+	codeInternal             = "internal"
+)
+
+// apiError is the error envelope returned by every endpoint as
+// {"error": {...}}.
+type apiError struct {
+	Status      int          `json:"status"`
+	Code        string       `json:"code"`
+	Message     string       `json:"message"`
+	Details     interface{}  `json:"details,omitempty"`
+	FieldErrors []fieldError `json:"field_errors,omitempty"`
+	RequestID   string       `json:"request_id,omitempty"`
+}
+
+func (e *apiError) Error() string {
+	return e.Code + ": " + e.Message
+}
+
+func newAPIError(status int, code string, message string) *apiError {
+	return &apiError{Status: status, Code: code, Message: message}
+}
+
+func errBadRequest(message string) *apiError {
+	return newAPIError(http.StatusBadRequest, codeBadRequest, message)
+}
+
+func errUnauthenticated(message string) *apiError {
+	return newAPIError(http.StatusUnauthorized, codeUnauthenticated, message)
+}
+
+func errPermissionDenied(message string) *apiError {
+	return newAPIError(http.StatusForbidden, codePermissionDenied, message)
+}
+
+func errNotFound(message string) *apiError {
+	return newAPIError(http.StatusNotFound, codeNotFound, message)
+}
+
+func errMethodNotAllowed(message string) *apiError {
+	return newAPIError(http.StatusMethodNotAllowed, codeMethodNotAllowed, message)
+}
+
+func errInternal(message string) *apiError {
+	return newAPIError(http.StatusInternalServerError, codeInternal, message)
+}
+
+func errValidation(fieldErrors []fieldError) *apiError {
+	apiErr := newAPIError(http.StatusBadRequest, codeValidationFailed, "request data is invalid")
+	apiErr.FieldErrors = fieldErrors
+
+	return apiErr
+}
+
+// kubeAPIError maps an error returned by the Kubernetes API to the matching
+// HTTP status and code. The Kubernetes reason and target are kept in details.
+func kubeAPIError(ctx context.Context, err error) *apiError {
+	var apiErr *apiError
+
+	switch {
+	case apierrors.IsNotFound(err):
+		apiErr = errNotFound(err.Error())
+	case apierrors.IsAlreadyExists(err):
+		apiErr = newAPIError(http.StatusConflict, codeAlreadyExists, err.Error())
+	case apierrors.IsConflict(err):
+		apiErr = newAPIError(http.StatusConflict, codeConflict, err.Error())
+	case apierrors.IsInvalid(err):
+		apiErr = newAPIError(http.StatusUnprocessableEntity, codeInvalid, err.Error())
+	case apierrors.IsForbidden(err):
+		apiErr = errPermissionDenied(err.Error())
+	case apierrors.IsBadRequest(err):
+		apiErr = errBadRequest(err.Error())
+	case apierrors.IsTimeout(err), apierrors.IsServerTimeout(err):
+		apiErr = newAPIError(http.StatusGatewayTimeout, codeTimeout, err.Error())
+	case apierrors.IsServiceUnavailable(err), apierrors.IsTooManyRequests(err):
+		apiErr = newAPIError(http.StatusServiceUnavailable, codeUnavailable, err.Error())
+	default:
+		slog.ErrorContext(ctx, "Kubernetes API error", "error", err)
+		return errInternal("Kubernetes API error")
+	}
+
+	var statusErr apierrors.APIStatus
+	if errors.As(err, &statusErr) {
+		status := statusErr.Status()
+
+		details := map[string]string{"reason": string(status.Reason)}
+
+		if status.Details != nil {
+			details["kind"] = status.Details.Kind
+			details["name"] = status.Details.Name
+
+			for _, cause := range status.Details.Causes {
+				apiErr.FieldErrors = append(apiErr.FieldErrors, fieldError{Field: cause.Field, Message: cause.Message})
+			}
+		}
+
+		apiErr.Details = details
+	}
+
+	return apiErr
+}
+
+// sendError writes the error envelope of apiErr, r must not be nil.
+func sendError(w http.ResponseWriter, r *http.Request, apiErr *apiError) {
+	apiErr.RequestID = logger.RequestIDFromContext(r.Context())
+
+	msg, _ := json.Marshal(map[string]*apiError{"error": apiErr})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(apiErr.Status)
+
+	_, err := w.Write(msg)
+
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error writing response", "error", err)
+	}
+}
@@ -0,0 +1,554 @@
+/**
+ * This work is licensed under Apache License, Version 2.0 or later.
+ * Please read and understand latest version of Licence.
+ */
+package webserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/kazimsarikaya/assesmentbarkinrl/internal/config"
+	"github.com/kazimsarikaya/assesmentbarkinrl/internal/logger"
+	appsv1 "k8s.io/api/apps/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	appslisters "k8s.io/client-go/listers/apps/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	clusterEventAdded   = "added"
+	clusterEventUpdated = "updated"
+	clusterEventDeleted = "deleted"
+	clusterEventStatus  = "status"
+	clusterEventReset   = "reset"
+	clusterEventSynced  = "synced"
+
+	subscriberBufferSize = 64
+)
+
+type clusterStatus struct {
+	Replicas        int32 `json:"replicas"`
+	ReadyReplicas   int32 `json:"ready_replicas"`
+	CurrentReplicas int32 `json:"current_replicas"`
+	UpdatedReplicas int32 `json:"updated_replicas"`
+}
+
+// clusterEvent is a change of a cluster ConfigMap or of its StatefulSet. The
+// resource version is used as the SSE event id so clients can resume.
+type clusterEvent struct {
+	Type            string         `json:"type"`
+	Kind            string         `json:"kind"`
+	Namespace       string         `json:"namespace"`
+	Name            string         `json:"name"`
+	Cluster         string         `json:"cluster"`
+	ResourceVersion string         `json:"resource_version"`
+	Status          *clusterStatus `json:"status,omitempty"`
+}
+
+// eventBroker fans informer events out to SSE subscribers and keeps a bounded
+// history for resuming.
+type eventBroker struct {
+	mu sync.Mutex
+	// subscribers map to the context of their request
+	subscribers map[chan clusterEvent]context.Context
+	history     []clusterEvent
+
+	cmLister  corelisters.ConfigMapLister
+	stsLister appslisters.StatefulSetLister
+}
+
+var (
+	brokerMu sync.Mutex
+	broker   *eventBroker
+)
+
+func getEventBroker() (*eventBroker, error) {
+	brokerMu.Lock()
+	defer brokerMu.Unlock()
+
+	if broker == nil {
+		b, err := newEventBroker()
+		if err != nil {
+			return nil, err
+		}
+
+		broker = b
+	}
+
+	// A no-op once the caches are synced, retried if the first sync failed.
+	if err := startInformers(serverContext()); err != nil {
+		return nil, err
+	}
+
+	return broker, nil
+}
+
+func newEventBroker() (*eventBroker, error) {
+	factory, err := getInformerFactory()
+	if err != nil {
+		return nil, err
+	}
+
+	cmInformer := factory.Core().V1().ConfigMaps()
+	stsInformer := factory.Apps().V1().StatefulSets()
+
+	b := &eventBroker{
+		subscribers: map[chan clusterEvent]context.Context{},
+		cmLister:    cmInformer.Lister(),
+		stsLister:   stsInformer.Lister(),
+	}
+
+	_, err = cmInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    b.onConfigMapAdd,
+		UpdateFunc: b.onConfigMapUpdate,
+		DeleteFunc: b.onConfigMapDelete,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to add configmap event handler: %w", err)
+	}
+
+	_, err = stsInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    b.onStatefulSetAdd,
+		UpdateFunc: b.onStatefulSetUpdate,
+		DeleteFunc: b.onStatefulSetDelete,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to add statefulset event handler: %w", err)
+	}
+
+	return b, nil
+}
+
+func isClusterConfigMap(cm *corev1.ConfigMap) bool {
+	return cm.GetAnnotations()[annotationKey] == "true"
+}
+
+// clusterNameForStatefulSet returns the cluster a StatefulSet belongs to. The
+// watcher annotates it with its cluster ConfigMap, older ones only have the
+// app=<cluster name> label of the template.
+func clusterNameForStatefulSet(sts *appsv1.StatefulSet) string {
+	if name := sts.GetAnnotations()[clusterOwnerAnnotation]; name != "" {
+		return name
+	}
+
+	if name, ok := sts.GetLabels()["app"]; ok && name != "" {
+		return name
+	}
+
+	return sts.Name
+}
+
+func (b *eventBroker) isClusterStatefulSet(sts *appsv1.StatefulSet) bool {
+	cm, err := b.cmLister.ConfigMaps(sts.Namespace).Get(clusterNameForStatefulSet(sts))
+
+	return err == nil && isClusterConfigMap(cm)
+}
+
+func configMapEvent(eventType string, cm *corev1.ConfigMap) clusterEvent {
+	return clusterEvent{
+		Type:            eventType,
+		Kind:            "ConfigMap",
+		Namespace:       cm.Namespace,
+		Name:            cm.Name,
+		Cluster:         cm.Name,
+		ResourceVersion: cm.ResourceVersion,
+	}
+}
+
+func statefulSetEvent(eventType string, sts *appsv1.StatefulSet) clusterEvent {
+	ev := clusterEvent{
+		Type:            eventType,
+		Kind:            "StatefulSet",
+		Namespace:       sts.Namespace,
+		Name:            sts.Name,
+		Cluster:         clusterNameForStatefulSet(sts),
+		ResourceVersion: sts.ResourceVersion,
+	}
+
+	if eventType != clusterEventDeleted {
+		ev.Status = &clusterStatus{
+			Replicas:        sts.Status.Replicas,
+			ReadyReplicas:   sts.Status.ReadyReplicas,
+			CurrentReplicas: sts.Status.CurrentReplicas,
+			UpdatedReplicas: sts.Status.UpdatedReplicas,
+		}
+	}
+
+	return ev
+}
+
+func (b *eventBroker) onConfigMapAdd(obj interface{}) {
+	if cm, ok := obj.(*corev1.ConfigMap); ok && isClusterConfigMap(cm) {
+		b.publish(configMapEvent(clusterEventAdded, cm))
+	}
+}
+
+func (b *eventBroker) onConfigMapUpdate(oldObj, newObj interface{}) {
+	oldCM, ok := oldObj.(*corev1.ConfigMap)
+	if !ok {
+		return
+	}
+
+	newCM, ok := newObj.(*corev1.ConfigMap)
+	if !ok || oldCM.ResourceVersion == newCM.ResourceVersion {
+		return
+	}
+
+	switch wasCluster, isCluster := isClusterConfigMap(oldCM), isClusterConfigMap(newCM); {
+	case wasCluster && isCluster:
+		b.publish(configMapEvent(clusterEventUpdated, newCM))
+	case !wasCluster && isCluster:
+		b.publish(configMapEvent(clusterEventAdded, newCM))
+	case wasCluster && !isCluster:
+		b.publish(configMapEvent(clusterEventDeleted, newCM))
+	}
+}
+
+func (b *eventBroker) onConfigMapDelete(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+
+	if cm, ok := obj.(*corev1.ConfigMap); ok && isClusterConfigMap(cm) {
+		b.publish(configMapEvent(clusterEventDeleted, cm))
+	}
+}
+
+func (b *eventBroker) onStatefulSetAdd(obj interface{}) {
+	if sts, ok := obj.(*appsv1.StatefulSet); ok && b.isClusterStatefulSet(sts) {
+		b.publish(statefulSetEvent(clusterEventStatus, sts))
+	}
+}
+
+func (b *eventBroker) onStatefulSetUpdate(oldObj, newObj interface{}) {
+	oldSts, ok := oldObj.(*appsv1.StatefulSet)
+	if !ok {
+		return
+	}
+
+	newSts, ok := newObj.(*appsv1.StatefulSet)
+	if !ok || oldSts.ResourceVersion == newSts.ResourceVersion {
+		return
+	}
+
+	if oldSts.Status.Replicas == newSts.Status.Replicas &&
+		oldSts.Status.ReadyReplicas == newSts.Status.ReadyReplicas &&
+		oldSts.Status.CurrentReplicas == newSts.Status.CurrentReplicas &&
+		oldSts.Status.UpdatedReplicas == newSts.Status.UpdatedReplicas {
+		return
+	}
+
+	if b.isClusterStatefulSet(newSts) {
+		b.publish(statefulSetEvent(clusterEventStatus, newSts))
+	}
+}
+
+func (b *eventBroker) onStatefulSetDelete(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+
+	// The cluster ConfigMap may already be gone, so only the owner annotation
+	// of the watcher tells whether this StatefulSet belonged to a cluster.
+	// The informer sees every StatefulSet, the app label is too common.
+	if sts, ok := obj.(*appsv1.StatefulSet); ok && sts.GetAnnotations()[clusterOwnerAnnotation] != "" {
+		b.publish(statefulSetEvent(clusterEventDeleted, sts))
+	}
+}
+
+func (b *eventBroker) publish(ev clusterEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.history = append(b.history, ev)
+
+	if size := config.GetConfig().GetSseHistorySize(); len(b.history) > size {
+		b.history = append([]clusterEvent(nil), b.history[len(b.history)-size:]...)
+	}
+
+	for ch, ctx := range b.subscribers {
+		select {
+		case ch <- ev:
+		default:
+			// Slow subscriber, drop it. The client reconnects and resumes.
+			slog.WarnContext(ctx, "Dropping slow event subscriber")
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// subscribe registers a subscriber. When lastEventID is set the events after
+// it are returned as backlog; reset is true when it is no longer in history.
+func (b *eventBroker) subscribe(ctx context.Context, lastEventID string) (ch chan clusterEvent, backlog []clusterEvent, latestID string, reset bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	ch = make(chan clusterEvent, subscriberBufferSize)
+	b.subscribers[ch] = ctx
+
+	if len(b.history) > 0 {
+		latestID = b.history[len(b.history)-1].ResourceVersion
+	}
+
+	if lastEventID == "" {
+		return ch, nil, latestID, false
+	}
+
+	// Deletions repeat the last resource version of the object, so resume
+	// after the first occurrence and rather send a duplicate than miss one.
+	for i, ev := range b.history {
+		if ev.ResourceVersion == lastEventID {
+			backlog = append(backlog, b.history[i+1:]...)
+			return ch, backlog, latestID, false
+		}
+	}
+
+	return ch, nil, latestID, true
+}
+
+func (b *eventBroker) unsubscribe(ch chan clusterEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.subscribers[ch]; ok {
+		delete(b.subscribers, ch)
+		close(ch)
+	}
+}
+
+// snapshot returns the current clusters and their StatefulSets from the cache.
+func (b *eventBroker) snapshot() ([]clusterEvent, error) {
+	cms, err := b.cmLister.List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+
+	events := []clusterEvent{}
+
+	for _, cm := range cms {
+		if isClusterConfigMap(cm) {
+			events = append(events, configMapEvent(clusterEventAdded, cm))
+		}
+	}
+
+	stss, err := b.stsLister.List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+
+	for _, sts := range stss {
+		if b.isClusterStatefulSet(sts) {
+			events = append(events, statefulSetEvent(clusterEventStatus, sts))
+		}
+	}
+
+	return events, nil
+}
+
+// namespaceAccess answers, per stream, whether the caller may list cluster
+// ConfigMaps of a namespace. It only restricts anything when impersonation is
+// enabled, otherwise the service account sees everything anyway.
+type namespaceAccess struct {
+	ctx     context.Context
+	allowed map[string]bool
+}
+
+func (na *namespaceAccess) can(namespace string) bool {
+	if !config.GetConfig().GetKubeImpersonate() {
+		return true
+	}
+
+	if allowed, ok := na.allowed[namespace]; ok {
+		return allowed
+	}
+
+	allowed := false
+
+	clientset, err := kubeClientsetFor(na.ctx)
+	if err == nil {
+		review, err := clientset.AuthorizationV1().SelfSubjectAccessReviews().Create(na.ctx, &authorizationv1.SelfSubjectAccessReview{
+			Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+				ResourceAttributes: &authorizationv1.ResourceAttributes{
+					Namespace: namespace,
+					Verb:      "list",
+					Resource:  "configmaps",
+				},
+			},
+		}, metav1.CreateOptions{})
+
+		if err == nil {
+			allowed = review.Status.Allowed
+		} else {
+			slog.ErrorContext(na.ctx, "Access review failed", "namespace", namespace, "error", err)
+		}
+	} else {
+		slog.ErrorContext(na.ctx, "Error creating clientset", "error", err)
+	}
+
+	na.allowed[namespace] = allowed
+
+	return allowed
+}
+
+func writeSSE(w http.ResponseWriter, id string, ev clusterEvent) error {
+	buf, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+
+	if id != "" {
+		if _, err := fmt.Fprintf(w, "id: %s\n", id); err != nil {
+			return err
+		}
+	}
+
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.Type, buf)
+
+	return err
+}
+
+// EventsHandler streams cluster changes as Server-Sent Events. Clients resume
+// with the Last-Event-ID header or the resourceVersion query parameter.
+// EventSource cannot set headers, so the bearer token may also be passed as
+// the access_token query parameter.
+func EventsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") == "" && r.URL.Query().Get("access_token") != "" {
+		r = r.Clone(r.Context())
+		r.Header.Set("Authorization", "Bearer "+r.URL.Query().Get("access_token"))
+	}
+
+	if !checkIPRateLimit(w, r) {
+		return
+	}
+
+	identity, err := authenticateRequest(r)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Authentication failed", "error", err)
+		sendError(w, r, errUnauthenticated(err.Error()))
+		return
+	}
+
+	logger.SetRequestUsername(r.Context(), identity.Username)
+	ctx := withIdentity(r.Context(), identity)
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		sendError(w, r, errInternal("Streaming is not supported"))
+		return
+	}
+
+	b, err := getEventBroker()
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error starting event broker", "error", err)
+		sendError(w, r, newAPIError(http.StatusServiceUnavailable, codeUnavailable, "Event stream is not available"))
+		return
+	}
+
+	// The server write timeout would otherwise end the stream.
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+		slog.DebugContext(r.Context(), "Cannot clear write deadline", "error", err)
+	}
+
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = r.URL.Query().Get("resourceVersion")
+	}
+
+	ch, backlog, latestID, reset := b.subscribe(r.Context(), lastEventID)
+	defer b.unsubscribe(ch)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	access := &namespaceAccess{ctx: ctx, allowed: map[string]bool{}}
+
+	send := func(id string, ev clusterEvent) bool {
+		if ev.Namespace != "" && !access.can(ev.Namespace) {
+			return true
+		}
+
+		if err := writeSSE(w, id, ev); err != nil {
+			slog.DebugContext(r.Context(), "Error writing event", "error", err)
+			return false
+		}
+
+		return true
+	}
+
+	if _, err := fmt.Fprint(w, "retry: 5000\n\n"); err != nil {
+		return
+	}
+
+	if lastEventID == "" || reset {
+		if reset && !send("", clusterEvent{Type: clusterEventReset}) {
+			return
+		}
+
+		snapshot, err := b.snapshot()
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error listing clusters", "error", err)
+			return
+		}
+
+		for _, ev := range snapshot {
+			if !send("", ev) {
+				return
+			}
+		}
+
+		if !send(latestID, clusterEvent{Type: clusterEventSynced, ResourceVersion: latestID}) {
+			return
+		}
+	} else {
+		for _, ev := range backlog {
+			if !send(ev.ResourceVersion, ev) {
+				return
+			}
+		}
+	}
+
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(config.GetConfig().GetSseHeartbeat())
+	defer heartbeat.Stop()
+
+	// streams end with the server, they would hold the shutdown until the
+	// drain deadline
+	stopping := serverContext().Done()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-stopping:
+			return
+		case ev, ok := <-ch:
+			if !ok {
+				return
+			}
+
+			if !send(ev.ResourceVersion, ev) {
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+		}
+
+		flusher.Flush()
+	}
+}
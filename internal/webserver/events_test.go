@@ -0,0 +1,62 @@
+/**
+ * This work is licensed under Apache License, Version 2.0 or later.
+ * Please read and understand latest version of Licence.
+ */
+package webserver
+
+import (
+	"context"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestOnStatefulSetDelete(t *testing.T) {
+	owned := &appsv1.StatefulSet{ObjectMeta: metav1.ObjectMeta{
+		Namespace:   "team",
+		Name:        "mycluster-db",
+		Labels:      map[string]string{"app": "mycluster"},
+		Annotations: map[string]string{clusterOwnerAnnotation: "mycluster"},
+	}}
+
+	tests := []struct {
+		name        string
+		obj         interface{}
+		wantCluster string
+	}{
+		{name: "owned", obj: owned, wantCluster: "mycluster"},
+		{name: "tombstone", obj: cache.DeletedFinalStateUnknown{Key: "team/mycluster-db", Obj: owned}, wantCluster: "mycluster"},
+		{name: "app label only", obj: &appsv1.StatefulSet{ObjectMeta: metav1.ObjectMeta{
+			Namespace: "kube-system",
+			Name:      "other",
+			Labels:    map[string]string{"app": "other"},
+		}}},
+		{name: "not a StatefulSet", obj: "team/mycluster-db"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &eventBroker{subscribers: map[chan clusterEvent]context.Context{}}
+
+			ch, _, _, _ := b.subscribe(context.Background(), "")
+			b.onStatefulSetDelete(tt.obj)
+
+			select {
+			case ev := <-ch:
+				if tt.wantCluster == "" {
+					t.Fatalf("published %+v", ev)
+				}
+
+				if ev.Type != clusterEventDeleted || ev.Cluster != tt.wantCluster {
+					t.Fatalf("event = %+v, want a deletion of %s", ev, tt.wantCluster)
+				}
+			default:
+				if tt.wantCluster != "" {
+					t.Fatal("nothing published")
+				}
+			}
+		})
+	}
+}
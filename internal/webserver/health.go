@@ -0,0 +1,112 @@
+/**
+ * This work is licensed under Apache License, Version 2.0 or later.
+ * Please read and understand latest version of Licence.
+ */
+package webserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/kazimsarikaya/assesmentbarkinrl/internal/config"
+	"github.com/kazimsarikaya/assesmentbarkinrl/internal/health"
+	"github.com/kazimsarikaya/assesmentbarkinrl/internal/scheduler"
+)
+
+// oidcCheckInterval is how long the result of the OIDC discovery check is
+// reused, so probes do not hammer the identity provider.
+const oidcCheckInterval = 30 * time.Second
+
+var (
+	healthOnce    sync.Once
+	healthChecker *health.Checker
+)
+
+// HealthChecker returns the checker with the probes of the process. It is
+// shared by the server and the watcher.
+func HealthChecker() *health.Checker {
+	healthOnce.Do(func() {
+		healthChecker = health.NewChecker()
+		healthChecker.AddLiveness(health.Ping)
+		healthChecker.AddReadiness(health.Check{Name: "kubernetes", Check: checkKubeAPI})
+		healthChecker.AddReadiness(health.Check{Name: "informers", Check: checkInformers})
+		healthChecker.AddStatus(scheduler.Default().HealthCheck())
+	})
+
+	return healthChecker
+}
+
+// checkKubeAPI checks that the Kubernetes API answers.
+func checkKubeAPI(ctx context.Context) error {
+	clientset, err := getKubeClientset()
+	if err != nil {
+		return err
+	}
+
+	return clientset.Discovery().RESTClient().Get().AbsPath("/version").Do(ctx).Error()
+}
+
+// checkInformers checks that the started informers are synced. Informers are
+// started on demand by the server, not started ones are fine.
+func checkInformers(ctx context.Context) error {
+	informerMu.Lock()
+	factories := slices.Clone(watcherFactories)
+	if informerStarted && informerFactory != nil {
+		factories = append(factories, informerFactory)
+	}
+	informerMu.Unlock()
+
+	// a closed channel returns the current sync state without waiting
+	done := make(chan struct{})
+	close(done)
+
+	for _, factory := range factories {
+		for informerType, synced := range factory.WaitForCacheSync(done) {
+			if !synced {
+				return fmt.Errorf("informer %v is not synced", informerType)
+			}
+		}
+	}
+
+	return nil
+}
+
+// oidcCheck caches the result of the OIDC discovery check.
+type oidcCheck struct {
+	mu        sync.Mutex
+	checkedAt time.Time
+	lastErr   error
+}
+
+var oidcHealth = &oidcCheck{}
+
+func (c *oidcCheck) check(ctx context.Context) error {
+	issuer := config.GetConfig().GetOidcIssuer()
+	if issuer == "" {
+		return errors.New("OIDC issuer not set")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.checkedAt.IsZero() && time.Since(c.checkedAt) < oidcCheckInterval {
+		return c.lastErr
+	}
+
+	_, err := fetchOIDCConfig(ctx, issuer+"/.well-known/openid-configuration")
+
+	c.checkedAt = time.Now()
+	c.lastErr = err
+
+	return err
+}
+
+// AddOIDCHealthCheck adds the OIDC discovery check to the readiness checks.
+// Only the server validates tokens, so the watcher does not add it.
+func AddOIDCHealthCheck() {
+	HealthChecker().AddReadiness(health.Check{Name: "oidc", Check: oidcHealth.check})
+}
@@ -0,0 +1,163 @@
+/**
+ * This work is licensed under Apache License, Version 2.0 or later.
+ * Please read and understand latest version of Licence.
+ */
+package webserver
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/kazimsarikaya/assesmentbarkinrl/internal/config"
+	"github.com/kazimsarikaya/assesmentbarkinrl/internal/idempotency"
+)
+
+const maxIdempotencyKeyLength = 255
+
+var (
+	idempotencyStore idempotency.Store
+	idempotencyLocks = idempotency.NewLocker()
+)
+
+// setupIdempotency creates the configured idempotency store.
+func setupIdempotency() error {
+	cfg := config.GetConfig()
+
+	switch cfg.GetIdempotencyStore() {
+	case "memory":
+		idempotencyStore = idempotency.NewMemoryStore()
+	case "configmap":
+		clientset, err := getKubeClientset()
+		if err != nil {
+			return err
+		}
+
+		idempotencyStore = idempotency.NewConfigMapStore(clientset, cfg.GetIdempotencyNamespace(), cfg.GetIdempotencyConfigMap())
+	case "none", "":
+		idempotencyStore = nil
+	default:
+		return fmt.Errorf("unknown idempotency store %q", cfg.GetIdempotencyStore())
+	}
+
+	return nil
+}
+
+// responseCapture keeps a copy of the response for replays.
+type responseCapture struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rc *responseCapture) WriteHeader(statusCode int) {
+	rc.status = statusCode
+	rc.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (rc *responseCapture) Write(b []byte) (int, error) {
+	rc.body.Write(b)
+	return rc.ResponseWriter.Write(b)
+}
+
+func (rc *responseCapture) Unwrap() http.ResponseWriter {
+	return rc.ResponseWriter
+}
+
+// withIdempotency runs a mutating action once per Idempotency-Key. Duplicates
+// get the first response replayed, a reused key with another payload is
+// rejected. Requests without the header run as usual.
+func withIdempotency(w http.ResponseWriter, r *http.Request, action string, data map[string]interface{}, run func(w http.ResponseWriter)) {
+	key := r.Header.Get("Idempotency-Key")
+	if key == "" || idempotencyStore == nil {
+		run(w)
+		return
+	}
+
+	if len(key) > maxIdempotencyKeyLength {
+		sendError(w, r, errBadRequest(fmt.Sprintf("Idempotency-Key is longer than %d characters", maxIdempotencyKeyLength)))
+		return
+	}
+
+	storeKey := idempotency.Key(idempotencyScope(r), key)
+	digest := action + ":" + payloadDigest(data)
+
+	unlock := idempotencyLocks.Lock(storeKey)
+	defer unlock()
+
+	entry, err := idempotencyStore.Get(r.Context(), storeKey)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error reading idempotency key", "error", err)
+		sendError(w, r, newAPIError(http.StatusServiceUnavailable, codeUnavailable, "idempotency store is unavailable"))
+		return
+	}
+
+	if entry != nil {
+		if entry.PayloadDigest != digest {
+			sendError(w, r, newAPIError(http.StatusUnprocessableEntity, codeIdempotencyKeyReused,
+				"Idempotency-Key was already used with a different request"))
+			return
+		}
+
+		for name, values := range entry.Header {
+			w.Header()[name] = values
+		}
+
+		w.Header().Set("Idempotent-Replayed", "true")
+		w.WriteHeader(entry.Status)
+
+		if _, err := w.Write(entry.Body); err != nil {
+			slog.ErrorContext(r.Context(), "Error writing response", "error", err)
+		}
+
+		return
+	}
+
+	capture := &responseCapture{ResponseWriter: w, status: http.StatusOK}
+
+	run(capture)
+
+	// server errors are not final, let the client retry them
+	if capture.status >= http.StatusInternalServerError {
+		return
+	}
+
+	entry = &idempotency.Entry{
+		PayloadDigest: digest,
+		Status:        capture.status,
+		Header:        http.Header{},
+		Body:          capture.body.Bytes(),
+		Expires:       time.Now().Add(config.GetConfig().GetIdempotencyTTL()),
+	}
+
+	for _, name := range []string{"Content-Type", "Location"} {
+		if value := w.Header().Get(name); value != "" {
+			entry.Header.Set(name, value)
+		}
+	}
+
+	if err := idempotencyStore.Put(r.Context(), storeKey, entry); errors.Is(err, idempotency.ErrEntryTooLarge) {
+		slog.WarnContext(r.Context(), "Response not stored for its idempotency key", "action", action, "size", len(entry.Body))
+	} else if err != nil {
+		slog.ErrorContext(r.Context(), "Error storing idempotency key", "error", err)
+	}
+}
+
+// idempotencyScope separates the keys of the callers. The subject is unique
+// per issuer, tokens without one are scoped by issuer and username instead so
+// they never share the empty subject.
+func idempotencyScope(r *http.Request) string {
+	identity, ok := IdentityFromContext(r.Context())
+	if !ok {
+		return "-"
+	}
+
+	if identity.Subject != "" {
+		return "sub:" + identity.Issuer + "\x00" + identity.Subject
+	}
+
+	return "user:" + identity.Issuer + "\x00" + identity.Username
+}
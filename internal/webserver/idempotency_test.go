@@ -0,0 +1,169 @@
+/**
+ * This work is licensed under Apache License, Version 2.0 or later.
+ * Please read and understand latest version of Licence.
+ */
+package webserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/kazimsarikaya/assesmentbarkinrl/internal/idempotency"
+)
+
+// idempotentCall is a call of withIdempotency by a user.
+type idempotentCall struct {
+	username string
+	key      string
+	data     map[string]interface{}
+	// status the action answers with when it runs
+	status int
+	// sleep before the call
+	sleep time.Duration
+
+	wantRun      bool
+	wantStatus   int
+	wantReplayed bool
+}
+
+func TestWithIdempotency(t *testing.T) {
+	payload := map[string]interface{}{"name": "a", "namespace": "default"}
+	other := map[string]interface{}{"name": "b", "namespace": "default"}
+
+	tests := []struct {
+		name  string
+		calls []idempotentCall
+	}{
+		{
+			name: "replay",
+			calls: []idempotentCall{
+				{username: "alice", key: "k1", data: payload, status: http.StatusCreated, wantRun: true, wantStatus: http.StatusCreated},
+				{username: "alice", key: "k1", data: payload, status: http.StatusCreated, wantStatus: http.StatusCreated, wantReplayed: true},
+			},
+		},
+		{
+			name: "client errors are replayed",
+			calls: []idempotentCall{
+				{username: "alice", key: "k1", data: payload, status: http.StatusConflict, wantRun: true, wantStatus: http.StatusConflict},
+				{username: "alice", key: "k1", data: payload, status: http.StatusOK, wantStatus: http.StatusConflict, wantReplayed: true},
+			},
+		},
+		{
+			name: "key reused with another payload",
+			calls: []idempotentCall{
+				{username: "alice", key: "k1", data: payload, status: http.StatusOK, wantRun: true, wantStatus: http.StatusOK},
+				{username: "alice", key: "k1", data: other, status: http.StatusOK, wantStatus: http.StatusUnprocessableEntity},
+			},
+		},
+		{
+			name: "keys are scoped per caller",
+			calls: []idempotentCall{
+				{username: "alice", key: "k1", data: payload, status: http.StatusOK, wantRun: true, wantStatus: http.StatusOK},
+				{username: "bob", key: "k1", data: payload, status: http.StatusOK, wantRun: true, wantStatus: http.StatusOK},
+				{username: "bob", key: "k1", data: other, status: http.StatusOK, wantStatus: http.StatusUnprocessableEntity},
+			},
+		},
+		{
+			name: "server errors are not stored",
+			calls: []idempotentCall{
+				{username: "alice", key: "k1", data: payload, status: http.StatusServiceUnavailable, wantRun: true, wantStatus: http.StatusServiceUnavailable},
+				{username: "alice", key: "k1", data: payload, status: http.StatusOK, wantRun: true, wantStatus: http.StatusOK},
+				{username: "alice", key: "k1", data: payload, status: http.StatusOK, wantStatus: http.StatusOK, wantReplayed: true},
+			},
+		},
+		{
+			name: "expired keys run again",
+			calls: []idempotentCall{
+				{username: "alice", key: "k1", data: payload, status: http.StatusOK, wantRun: true, wantStatus: http.StatusOK},
+				{username: "alice", key: "k1", data: other, status: http.StatusOK, sleep: 100 * time.Millisecond, wantRun: true, wantStatus: http.StatusOK},
+			},
+		},
+		{
+			name: "without a key",
+			calls: []idempotentCall{
+				{username: "alice", data: payload, status: http.StatusOK, wantRun: true, wantStatus: http.StatusOK},
+				{username: "alice", data: payload, status: http.StatusOK, wantRun: true, wantStatus: http.StatusOK},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setTestConfig(t, map[string]interface{}{"idempotencyTTL": 50 * time.Millisecond})
+
+			previous := idempotencyStore
+			idempotencyStore = idempotency.NewMemoryStore()
+
+			t.Cleanup(func() { idempotencyStore = previous })
+
+			for i, c := range tt.calls {
+				time.Sleep(c.sleep)
+
+				r := httptest.NewRequest(http.MethodPost, "/api", nil)
+				r = r.WithContext(withIdentity(r.Context(), &Identity{Issuer: "https://issuer", Subject: c.username, Username: c.username}))
+
+				if c.key != "" {
+					r.Header.Set("Idempotency-Key", c.key)
+				}
+
+				w := httptest.NewRecorder()
+				ran := false
+
+				withIdempotency(w, r, "update_configmap", c.data, func(w http.ResponseWriter) {
+					ran = true
+
+					w.Header().Set("Content-Type", "application/json")
+					w.WriteHeader(c.status)
+					_ = json.NewEncoder(w).Encode(map[string]int{"call": i})
+				})
+
+				if ran != c.wantRun {
+					t.Fatalf("call %d: ran = %v, want %v", i, ran, c.wantRun)
+				}
+
+				if w.Code != c.wantStatus {
+					t.Fatalf("call %d: status = %d, want %d", i, w.Code, c.wantStatus)
+				}
+
+				if replayed := w.Header().Get("Idempotent-Replayed") == "true"; replayed != c.wantReplayed {
+					t.Fatalf("call %d: replayed = %v, want %v", i, replayed, c.wantReplayed)
+				}
+
+				// a replay carries the body of the first response
+				if c.wantReplayed {
+					var body map[string]int
+					if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || body["call"] == i {
+						t.Fatalf("call %d: replayed body %q", i, w.Body.String())
+					}
+
+					if got := w.Header().Get("Content-Type"); got != "application/json" {
+						t.Fatalf("call %d: replayed Content-Type %q", i, got)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestWithIdempotencyKeyTooLong(t *testing.T) {
+	previous := idempotencyStore
+	idempotencyStore = idempotency.NewMemoryStore()
+
+	t.Cleanup(func() { idempotencyStore = previous })
+
+	r := httptest.NewRequest(http.MethodPost, "/api", nil)
+	r.Header.Set("Idempotency-Key", string(make([]byte, maxIdempotencyKeyLength+1)))
+
+	w := httptest.NewRecorder()
+
+	withIdempotency(w, r, "update_configmap", nil, func(w http.ResponseWriter) {
+		t.Fatal("action ran with an invalid key")
+	})
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,151 @@
+/**
+ * This work is licensed under Apache License, Version 2.0 or later.
+ * Please read and understand latest version of Licence.
+ */
+package webserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sort"
+
+	jwt "github.com/golang-jwt/jwt/v5"
+	"github.com/kazimsarikaya/assesmentbarkinrl/internal/config"
+)
+
+// Identity is the authenticated caller extracted from a validated token.
+type Identity struct {
+	Issuer   string   `json:"issuer"`
+	Subject  string   `json:"subject"`
+	Username string   `json:"username"`
+	Email    string   `json:"email,omitempty"`
+	Groups   []string `json:"groups"`
+	Roles    []string `json:"roles"`
+}
+
+type identityKey struct{}
+
+func init() {
+	// get_me lists apiActions, so it is registered here to avoid an
+	// initialization cycle.
+	apiActions["get_me"] = securedApiAction{
+		action: getMe, needAuth: true,
+		summary: "Get the identity and permissions of the caller", request: emptyRequestSchema, response: meSchema,
+	}
+}
+
+func withIdentity(ctx context.Context, identity *Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, identity)
+}
+
+// IdentityFromContext returns the identity stored by the API handler, if any.
+func IdentityFromContext(ctx context.Context) (*Identity, bool) {
+	identity, ok := ctx.Value(identityKey{}).(*Identity)
+
+	return identity, ok && identity != nil
+}
+
+// IsAdmin reports whether the identity is a member of the configured admin group.
+func (i *Identity) IsAdmin() bool {
+	return slices.Contains(i.Groups, config.GetConfig().GetAdminGroup())
+}
+
+func identityFromClaims(claims jwt.MapClaims) (*Identity, error) {
+	username, ok := claims["preferred_username"].(string)
+	if !ok {
+		return nil, errors.New("username not found")
+	}
+
+	rawGroups, ok := claims["groups"].([]interface{})
+	if !ok {
+		return nil, errors.New("groups claim not found or invalid")
+	}
+
+	identity := &Identity{
+		Username: username,
+		Groups:   claimStrings(rawGroups),
+		Roles:    []string{},
+	}
+
+	identity.Issuer, _ = claims["iss"].(string)
+	identity.Subject, _ = claims["sub"].(string)
+	identity.Email, _ = claims["email"].(string)
+
+	// Keycloak puts realm roles under realm_access and client roles under
+	// resource_access.<client>.
+	if realmAccess, ok := claims["realm_access"].(map[string]interface{}); ok {
+		if roles, ok := realmAccess["roles"].([]interface{}); ok {
+			identity.Roles = append(identity.Roles, claimStrings(roles)...)
+		}
+	}
+
+	if resourceAccess, ok := claims["resource_access"].(map[string]interface{}); ok {
+		if client, ok := resourceAccess[config.GetConfig().GetOidcAudience()].(map[string]interface{}); ok {
+			if roles, ok := client["roles"].([]interface{}); ok {
+				identity.Roles = append(identity.Roles, claimStrings(roles)...)
+			}
+		}
+	}
+
+	sort.Strings(identity.Roles)
+	identity.Roles = slices.Compact(identity.Roles)
+
+	return identity, nil
+}
+
+func claimStrings(values []interface{}) []string {
+	result := make([]string, 0, len(values))
+
+	for _, v := range values {
+		if str, ok := v.(string); ok {
+			result = append(result, str)
+		}
+	}
+
+	return result
+}
+
+// permittedActions returns the API actions the identity may call.
+func permittedActions(identity *Identity) []string {
+	actions := []string{}
+
+	for name, action := range apiActions {
+		if action.adminOnly && (identity == nil || !identity.IsAdmin()) {
+			continue
+		}
+
+		actions = append(actions, name)
+	}
+
+	sort.Strings(actions)
+
+	return actions
+}
+
+func getMe(w http.ResponseWriter, r *http.Request, data map[string]interface{}) apiActionResult {
+	return func() {
+		identity, ok := IdentityFromContext(r.Context())
+		if !ok {
+			sendError(w, r, errUnauthenticated("identity not found"))
+			return
+		}
+
+		err := json.NewEncoder(w).Encode(map[string]interface{}{
+			"subject":     identity.Subject,
+			"username":    identity.Username,
+			"email":       identity.Email,
+			"groups":      identity.Groups,
+			"roles":       identity.Roles,
+			"is_admin":    identity.IsAdmin(),
+			"permissions": permittedActions(identity),
+		})
+
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error encoding response", "error", err)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+/**
+ * This work is licensed under Apache License, Version 2.0 or later.
+ * Please read and understand latest version of Licence.
+ */
+package webserver
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"k8s.io/client-go/informers"
+)
+
+const informerSyncTimeout = 30 * time.Second
+
+var (
+	informerMu      sync.Mutex
+	informerFactory informers.SharedInformerFactory
+	informerStarted bool
+
+	// watcherFactories are the factories of the watcher, scoped to its
+	// namespaces
+	watcherFactories []informers.SharedInformerFactory
+)
+
+// getInformerFactory returns the process wide informer factory. Informers
+// must be requested from it before startInformers is called.
+func getInformerFactory() (informers.SharedInformerFactory, error) {
+	informerMu.Lock()
+	defer informerMu.Unlock()
+
+	if informerFactory != nil {
+		return informerFactory, nil
+	}
+
+	clientset, err := getKubeClientset()
+	if err != nil {
+		return nil, err
+	}
+
+	informerFactory = informers.NewSharedInformerFactory(clientset, 0)
+
+	return informerFactory, nil
+}
+
+// startInformers starts the requested informers, which run until ctx is done,
+// and waits until their caches are synced.
+func startInformers(ctx context.Context) error {
+	factory, err := getInformerFactory()
+	if err != nil {
+		return err
+	}
+
+	informerMu.Lock()
+	if !informerStarted {
+		slog.InfoContext(ctx, "Starting informers")
+		informerStarted = true
+	}
+	informerMu.Unlock()
+
+	return syncInformers(ctx, factory)
+}
+
+// newWatcherInformerFactory returns a factory for the informers of the
+// watcher in namespace, metav1.NamespaceAll for all namespaces. The informers
+// are resynced every resync period, 0 disables resyncs.
+func newWatcherInformerFactory(namespace string, resync time.Duration) (informers.SharedInformerFactory, error) {
+	clientset, err := getKubeClientset()
+	if err != nil {
+		return nil, err
+	}
+
+	factory := informers.NewSharedInformerFactoryWithOptions(clientset, resync, informers.WithNamespace(namespace))
+
+	informerMu.Lock()
+	watcherFactories = append(watcherFactories, factory)
+	informerMu.Unlock()
+
+	return factory, nil
+}
+
+// syncInformers starts the requested informers of factory, which run until
+// ctx is done, and waits until their caches are synced.
+func syncInformers(ctx context.Context, factory informers.SharedInformerFactory) error {
+	// Start is a no-op for informers that are already running.
+	factory.Start(ctx.Done())
+
+	syncCtx, cancel := context.WithTimeout(ctx, informerSyncTimeout)
+	defer cancel()
+
+	for informerType, synced := range factory.WaitForCacheSync(syncCtx.Done()) {
+		if !synced {
+			slog.ErrorContext(ctx, "Informer cache not synced", "type", informerType)
+			return errors.New("informer caches not synced")
+		}
+	}
+
+	return nil
+}
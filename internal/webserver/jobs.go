@@ -0,0 +1,126 @@
+/**
+ * This work is licensed under Apache License, Version 2.0 or later.
+ * Please read and understand latest version of Licence.
+ */
+package webserver
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/kazimsarikaya/assesmentbarkinrl/internal/config"
+	"github.com/kazimsarikaya/assesmentbarkinrl/internal/metrics"
+	"github.com/kazimsarikaya/assesmentbarkinrl/internal/scheduler"
+)
+
+// registerServerJobs adds the maintenance jobs of the web server. The orphan
+// collector of the watcher is registered by WatchConfigMaps.
+func registerServerJobs() {
+	scheduler.Default().Register(scheduler.Job{
+		Name:     "jwks_refresh",
+		Interval: func() time.Duration { return config.GetConfig().GetJwksRefreshInterval() },
+		Jitter:   func() float64 { return config.GetConfig().GetJobJitter() },
+		Run:      refreshJWKS,
+	})
+
+	registerCredentialCheck()
+}
+
+// registerCredentialCheck adds the certificate expiry check, used by the
+// server and the watcher.
+func registerCredentialCheck() {
+	scheduler.Default().Register(scheduler.Job{
+		Name:     "credential_check",
+		Interval: func() time.Duration { return config.GetConfig().GetCredentialCheckInterval() },
+		Jitter:   func() float64 { return config.GetConfig().GetJobJitter() },
+		Run:      checkCredentials,
+	})
+}
+
+// refreshJWKS fetches the signing keys ahead of their expiry, so requests do
+// not wait for the refresh.
+func refreshJWKS(ctx context.Context) error {
+	if config.GetConfig().GetOidcIssuer() == "" {
+		return nil
+	}
+
+	keyCache.mu.RLock()
+	lastRefreshAt := keyCache.lastRefreshAt
+	keyCache.mu.RUnlock()
+
+	return keyCache.refresh(ctx, lastRefreshAt)
+}
+
+// checkCredentials fails when the serving certificate or the Kubernetes client
+// certificate expires within the configured warning window.
+func checkCredentials(ctx context.Context) error {
+	warning := config.GetConfig().GetCredentialExpiryWarning()
+
+	var errs []error
+
+	check := func(name string, notAfter time.Time) {
+		metrics.CertificateExpiry.WithLabelValues(name).Set(float64(notAfter.Unix()))
+
+		remaining := time.Until(notAfter)
+
+		slog.DebugContext(ctx, "Certificate expiry", "certificate", name, "not_after", notAfter, "remaining", remaining)
+
+		if remaining < warning {
+			errs = append(errs, fmt.Errorf("%s certificate expires at %s", name, notAfter.Format(time.RFC3339)))
+		}
+	}
+
+	if certs := servingCerts(); certs != nil {
+		notAfter, err := certs.notAfter()
+		if err != nil {
+			errs = append(errs, err)
+		} else {
+			check("serving", notAfter)
+		}
+	}
+
+	restConfig, err := getKubeRestConfig()
+	if err != nil {
+		// the kubernetes health check reports it
+		return errors.Join(errs...)
+	}
+
+	certPEM := restConfig.CertData
+	if len(certPEM) == 0 && restConfig.CertFile != "" {
+		if certPEM, err = os.ReadFile(restConfig.CertFile); err != nil {
+			errs = append(errs, fmt.Errorf("failed to read Kubernetes client certificate: %w", err))
+		}
+	}
+
+	if len(certPEM) != 0 {
+		notAfter, err := pemNotAfter(certPEM)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid Kubernetes client certificate: %w", err))
+		} else {
+			check("kubernetes_client", notAfter)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// pemNotAfter returns the expiry of the first certificate of a PEM bundle.
+func pemNotAfter(data []byte) (time.Time, error) {
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return time.Time{}, errors.New("no certificate found")
+	}
+
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return cert.NotAfter, nil
+}
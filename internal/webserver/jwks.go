@@ -0,0 +1,235 @@
+/**
+ * This work is licensed under Apache License, Version 2.0 or later.
+ * Please read and understand latest version of Licence.
+ */
+package webserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/kazimsarikaya/assesmentbarkinrl/internal/config"
+	"github.com/kazimsarikaya/assesmentbarkinrl/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+var errJWKSKeyNotFound = errors.New("key not found in JWKS")
+
+// jwksRefreshTimeout bounds a refresh, which does not end with the request
+// that triggered it.
+const jwksRefreshTimeout = 10 * time.Second
+
+// JWKSCacheStats is a snapshot of the JWKS cache counters.
+type JWKSCacheStats struct {
+	Hits          uint64    `json:"hits"`
+	Misses        uint64    `json:"misses"`
+	Refreshes     uint64    `json:"refreshes"`
+	RefreshErrors uint64    `json:"refresh_errors"`
+	StaleServed   uint64    `json:"stale_served"`
+	Keys          int       `json:"keys"`
+	FetchedAt     time.Time `json:"fetched_at"`
+}
+
+// jwksCache keeps the OIDC discovery document and the JWKS of the configured
+// issuer in memory. Keys are refreshed when the TTL expires or when a token
+// carries an unknown kid (rate limited), and the last good key set is served
+// for a bounded window while the issuer is unreachable.
+type jwksCache struct {
+	mu        sync.RWMutex
+	refreshMu sync.Mutex
+
+	issuer         string
+	oidcConfig     *OIDCConfig
+	keys           map[string]JWK
+	fetchedAt      time.Time
+	lastRefreshAt  time.Time
+	lastRefreshErr error
+
+	hits          atomic.Uint64
+	misses        atomic.Uint64
+	refreshes     atomic.Uint64
+	refreshErrors atomic.Uint64
+	staleServed   atomic.Uint64
+}
+
+var keyCache = &jwksCache{}
+
+// GetJWKSCacheStats returns the current JWKS cache counters.
+func GetJWKSCacheStats() JWKSCacheStats {
+	return keyCache.stats()
+}
+
+func (c *jwksCache) stats() JWKSCacheStats {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return JWKSCacheStats{
+		Hits:          c.hits.Load(),
+		Misses:        c.misses.Load(),
+		Refreshes:     c.refreshes.Load(),
+		RefreshErrors: c.refreshErrors.Load(),
+		StaleServed:   c.staleServed.Load(),
+		Keys:          len(c.keys),
+		FetchedAt:     c.fetchedAt,
+	}
+}
+
+// getKey returns the JWK for the given kid, refreshing the cache if needed.
+func (c *jwksCache) getKey(ctx context.Context, kid string) (JWK, error) {
+	if err := c.ensureFresh(ctx); err != nil {
+		return JWK{}, err
+	}
+
+	if jwk, ok := c.lookup(kid); ok {
+		c.hits.Add(1)
+		return jwk, nil
+	}
+
+	c.misses.Add(1)
+
+	// Unknown kid, the issuer may have rotated its keys.
+	c.mu.RLock()
+	lastRefreshAt := c.lastRefreshAt
+	c.mu.RUnlock()
+
+	if time.Since(lastRefreshAt) < config.GetConfig().GetJwksMinRefreshInterval() {
+		slog.DebugContext(ctx, "JWKS refresh rate limited", "kid", kid, "last_refresh", lastRefreshAt)
+		return JWK{}, fmt.Errorf("%w: %s", errJWKSKeyNotFound, kid)
+	}
+
+	if err := c.refresh(ctx, lastRefreshAt); err != nil {
+		return JWK{}, err
+	}
+
+	if jwk, ok := c.lookup(kid); ok {
+		return jwk, nil
+	}
+
+	return JWK{}, fmt.Errorf("%w: %s", errJWKSKeyNotFound, kid)
+}
+
+func (c *jwksCache) lookup(kid string) (JWK, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	jwk, ok := c.keys[kid]
+
+	return jwk, ok
+}
+
+// ensureFresh refreshes the cache when it is empty, expired or was built for
+// another issuer. When the refresh fails the stale key set is kept for the
+// configured stale window.
+func (c *jwksCache) ensureFresh(ctx context.Context) error {
+	cfg := config.GetConfig()
+
+	c.mu.RLock()
+	issuer := c.issuer
+	fetchedAt := c.fetchedAt
+	lastRefreshAt := c.lastRefreshAt
+	hasKeys := c.keys != nil
+	c.mu.RUnlock()
+
+	usable := hasKeys && issuer == cfg.GetOidcIssuer()
+
+	if usable && time.Since(fetchedAt) < cfg.GetJwksCacheTTL() {
+		return nil
+	}
+
+	var err error
+
+	// Do not hammer an issuer that just failed, keep serving stale keys instead.
+	if usable && time.Since(lastRefreshAt) < cfg.GetJwksMinRefreshInterval() {
+		c.mu.RLock()
+		err = c.lastRefreshErr
+		c.mu.RUnlock()
+	} else {
+		err = c.refresh(ctx, lastRefreshAt)
+	}
+
+	if err == nil {
+		return nil
+	}
+
+	if usable && time.Since(fetchedAt) < cfg.GetJwksCacheTTL()+cfg.GetJwksStaleTTL() {
+		c.staleServed.Add(1)
+		slog.WarnContext(ctx, "Serving stale JWKS", "fetched_at", fetchedAt, "error", err)
+		return nil
+	}
+
+	return err
+}
+
+// refresh fetches the discovery document and the JWKS. Concurrent callers that
+// observed the same lastRefreshAt share a single fetch, so it runs detached
+// from the cancellation of the triggering request. lastRefreshAt is set when
+// the fetch ends, callers arriving while it runs wait for its result.
+func (c *jwksCache) refresh(ctx context.Context, observed time.Time) (err error) {
+	c.refreshMu.Lock()
+	defer c.refreshMu.Unlock()
+
+	c.mu.RLock()
+	alreadyRefreshed := c.lastRefreshAt.After(observed)
+	lastRefreshErr := c.lastRefreshErr
+	c.mu.RUnlock()
+
+	if alreadyRefreshed {
+		return lastRefreshErr
+	}
+
+	issuer := config.GetConfig().GetOidcIssuer()
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), jwksRefreshTimeout)
+	defer cancel()
+
+	ctx, span := tracing.Start(ctx, "jwks.fetch", attribute.String("oidc.issuer", issuer))
+	defer func() { tracing.End(span, err) }()
+
+	c.refreshes.Add(1)
+
+	oidcConfig, err := fetchOIDCConfig(ctx, issuer+"/.well-known/openid-configuration")
+	if err != nil {
+		return c.refreshFailed(fmt.Errorf("failed to fetch OIDC configuration: %w", err))
+	}
+
+	jwks, err := fetchJWKS(ctx, oidcConfig.JwksURI)
+	if err != nil {
+		return c.refreshFailed(fmt.Errorf("failed to fetch JWKS: %w", err))
+	}
+
+	keys := make(map[string]JWK, len(jwks.Keys))
+	for _, jwk := range jwks.Keys {
+		keys[jwk.Kid] = jwk
+	}
+
+	c.mu.Lock()
+	c.issuer = issuer
+	c.oidcConfig = oidcConfig
+	c.keys = keys
+	c.fetchedAt = time.Now()
+	c.lastRefreshAt = c.fetchedAt
+	c.lastRefreshErr = nil
+	c.mu.Unlock()
+
+	slog.DebugContext(ctx, "JWKS refreshed", "issuer", issuer, "keys", len(keys))
+
+	return nil
+}
+
+func (c *jwksCache) refreshFailed(err error) error {
+	c.refreshErrors.Add(1)
+
+	c.mu.Lock()
+	c.lastRefreshAt = time.Now()
+	c.lastRefreshErr = err
+	c.mu.Unlock()
+
+	slog.Debug("JWKS refresh failed", "error", err)
+
+	return err
+}
@@ -0,0 +1,308 @@
+/**
+ * This work is licensed under Apache License, Version 2.0 or later.
+ * Please read and understand latest version of Licence.
+ */
+package webserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeIssuer is an identity provider counting its requests, it can be taken
+// down and its keys rotated.
+type fakeIssuer struct {
+	URL string
+
+	discoveries atomic.Int32
+	fetches     atomic.Int32
+	down        atomic.Bool
+
+	mu   sync.Mutex
+	keys []JWK
+	// gate, when set, blocks the JWKS requests until it is closed
+	gate chan struct{}
+	// fetching receives a value when a JWKS request arrives
+	fetching chan struct{}
+}
+
+func newFakeIssuer(t *testing.T, kids ...string) *fakeIssuer {
+	t.Helper()
+
+	iss := &fakeIssuer{fetching: make(chan struct{}, 100)}
+	iss.setKeys(kids...)
+
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	iss.URL = srv.URL
+
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		iss.discoveries.Add(1)
+
+		if iss.down.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		_ = json.NewEncoder(w).Encode(OIDCConfig{JwksURI: srv.URL + "/jwks"})
+	})
+
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		iss.fetches.Add(1)
+		iss.fetching <- struct{}{}
+
+		iss.mu.Lock()
+		keys, gate := iss.keys, iss.gate
+		iss.mu.Unlock()
+
+		if gate != nil {
+			<-gate
+		}
+
+		if iss.down.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		_ = json.NewEncoder(w).Encode(JWKS{Keys: keys})
+	})
+
+	return iss
+}
+
+func (iss *fakeIssuer) setKeys(kids ...string) {
+	iss.mu.Lock()
+	defer iss.mu.Unlock()
+
+	iss.keys = nil
+	for _, kid := range kids {
+		iss.keys = append(iss.keys, JWK{Kid: kid, Kty: "RSA", Use: "sig"})
+	}
+}
+
+// setJWKSTestConfig uses iss with a TTL and a stale window of an hour and a
+// minimum refresh interval of a minute.
+func setJWKSTestConfig(t *testing.T, issuer string) {
+	t.Helper()
+
+	setTestConfig(t, map[string]interface{}{
+		"oidcIssuer":             issuer,
+		"jwksCacheTTL":           time.Hour,
+		"jwksStaleTTL":           time.Hour,
+		"jwksMinRefreshInterval": time.Minute,
+	})
+}
+
+// age moves the fetch and refresh times of the cache back by d.
+func age(c *jwksCache, d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.fetchedAt = c.fetchedAt.Add(-d)
+	c.lastRefreshAt = c.lastRefreshAt.Add(-d)
+}
+
+func mustGetKey(t *testing.T, c *jwksCache, kid string) {
+	t.Helper()
+
+	if _, err := c.getKey(context.Background(), kid); err != nil {
+		t.Fatalf("getKey(%q): %v", kid, err)
+	}
+}
+
+func wantFetches(t *testing.T, iss *fakeIssuer, want int32) {
+	t.Helper()
+
+	if got := iss.fetches.Load(); got != want {
+		t.Fatalf("JWKS fetches = %d, want %d", got, want)
+	}
+}
+
+func TestJWKSCacheTTL(t *testing.T) {
+	iss := newFakeIssuer(t, "k1")
+	setJWKSTestConfig(t, iss.URL)
+
+	c := &jwksCache{}
+
+	mustGetKey(t, c, "k1")
+	mustGetKey(t, c, "k1")
+	wantFetches(t, iss, 1)
+
+	age(c, 61*time.Minute)
+
+	mustGetKey(t, c, "k1")
+	wantFetches(t, iss, 2)
+
+	if stats := c.stats(); stats.Hits != 3 || stats.Refreshes != 2 || stats.Keys != 1 {
+		t.Fatalf("stats = %+v", stats)
+	}
+}
+
+func TestJWKSCacheUnknownKid(t *testing.T) {
+	iss := newFakeIssuer(t, "k1")
+	setJWKSTestConfig(t, iss.URL)
+
+	c := &jwksCache{}
+
+	mustGetKey(t, c, "k1")
+
+	// the issuer rotates its keys right after the first fetch
+	iss.setKeys("k1", "k2")
+
+	if _, err := c.getKey(context.Background(), "k2"); !errors.Is(err, errJWKSKeyNotFound) {
+		t.Fatalf("error = %v, want %v", err, errJWKSKeyNotFound)
+	}
+
+	wantFetches(t, iss, 1)
+
+	age(c, 2*time.Minute)
+
+	mustGetKey(t, c, "k2")
+	wantFetches(t, iss, 2)
+
+	age(c, 2*time.Minute)
+
+	if _, err := c.getKey(context.Background(), "k3"); !errors.Is(err, errJWKSKeyNotFound) {
+		t.Fatalf("error = %v, want %v", err, errJWKSKeyNotFound)
+	}
+
+	wantFetches(t, iss, 3)
+
+	if stats := c.stats(); stats.Misses != 3 {
+		t.Fatalf("misses = %d, want 3", stats.Misses)
+	}
+}
+
+func TestJWKSCacheStale(t *testing.T) {
+	iss := newFakeIssuer(t, "k1")
+	setJWKSTestConfig(t, iss.URL)
+
+	c := &jwksCache{}
+
+	mustGetKey(t, c, "k1")
+
+	iss.down.Store(true)
+
+	// expired but within the stale window
+	age(c, 90*time.Minute)
+
+	mustGetKey(t, c, "k1")
+
+	if got := iss.discoveries.Load(); got != 2 {
+		t.Fatalf("discoveries = %d, want 2", got)
+	}
+
+	// the failed issuer is not asked again within the minimum interval
+	mustGetKey(t, c, "k1")
+
+	if got := iss.discoveries.Load(); got != 2 {
+		t.Fatalf("discoveries = %d, want 2", got)
+	}
+
+	if stats := c.stats(); stats.StaleServed != 2 || stats.RefreshErrors != 1 {
+		t.Fatalf("stats = %+v", stats)
+	}
+
+	// beyond the stale window
+	age(c, time.Hour)
+
+	if _, err := c.getKey(context.Background(), "k1"); err == nil {
+		t.Fatal("stale keys served beyond the stale window")
+	}
+
+	iss.down.Store(false)
+	age(c, 2*time.Minute)
+
+	mustGetKey(t, c, "k1")
+	wantFetches(t, iss, 2)
+}
+
+func TestJWKSCacheCoalescedRefresh(t *testing.T) {
+	iss := newFakeIssuer(t, "k1")
+	setJWKSTestConfig(t, iss.URL)
+
+	gate := make(chan struct{})
+
+	iss.mu.Lock()
+	iss.gate = gate
+	iss.mu.Unlock()
+
+	c := &jwksCache{}
+
+	var wg sync.WaitGroup
+
+	errs := make(chan error, 10)
+
+	for i := 0; i < cap(errs); i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			_, err := c.getKey(context.Background(), "k1")
+			errs <- err
+		}()
+	}
+
+	// let the other callers queue behind the running fetch
+	<-iss.fetching
+	time.Sleep(50 * time.Millisecond)
+	close(gate)
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("getKey: %v", err)
+		}
+	}
+
+	wantFetches(t, iss, 1)
+}
+
+func TestJWKSCacheIssuerChange(t *testing.T) {
+	first := newFakeIssuer(t, "k1")
+	second := newFakeIssuer(t, "k2")
+
+	setJWKSTestConfig(t, first.URL)
+
+	c := &jwksCache{}
+
+	mustGetKey(t, c, "k1")
+
+	setJWKSTestConfig(t, second.URL)
+
+	// the keys of the previous issuer are dropped, not served until the TTL
+	if _, err := c.getKey(context.Background(), "k1"); !errors.Is(err, errJWKSKeyNotFound) {
+		t.Fatalf("error = %v, want %v", err, errJWKSKeyNotFound)
+	}
+
+	mustGetKey(t, c, "k2")
+
+	wantFetches(t, first, 1)
+	wantFetches(t, second, 1)
+}
+
+func TestJWKSCacheRefreshOutlivesRequest(t *testing.T) {
+	iss := newFakeIssuer(t, "k1")
+	setJWKSTestConfig(t, iss.URL)
+
+	c := &jwksCache{}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := c.getKey(ctx, "k1"); err != nil {
+		t.Fatalf("getKey with a cancelled request: %v", err)
+	}
+}
@@ -0,0 +1,131 @@
+/**
+ * This work is licensed under Apache License, Version 2.0 or later.
+ * Please read and understand latest version of Licence.
+ */
+package webserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/kazimsarikaya/assesmentbarkinrl/internal/config"
+	"github.com/kazimsarikaya/assesmentbarkinrl/internal/logger"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+var (
+	kubeMu         sync.Mutex
+	kubeRestConfig *rest.Config
+	kubeClientset  kubernetes.Interface
+)
+
+// getKubeRestConfig returns the rest config of the process, loaded once from
+// the in-cluster service account or from ~/.kube/config.
+func getKubeRestConfig() (*rest.Config, error) {
+	kubeMu.Lock()
+	defer kubeMu.Unlock()
+
+	if kubeRestConfig != nil {
+		return kubeRestConfig, nil
+	}
+
+	// Try in-cluster config first, if it fails use kubeconfig from local
+	restConfig, err := rest.InClusterConfig()
+	if err != nil {
+		kubeconfig := filepath.Join(os.Getenv("HOME"), ".kube", "config")
+		restConfig, err = clientcmd.BuildConfigFromFlags("", kubeconfig)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get kubeconfig: %w", err)
+		}
+	}
+
+	if apiServer := config.GetConfig().GetKubeApiServer(); apiServer != "" {
+		restConfig.Host = apiServer
+	}
+
+	if caFile := config.GetConfig().GetKubeCAFile(); caFile != "" {
+		restConfig.CAFile = caFile
+		restConfig.CAData = nil
+	}
+
+	// pass the request id and trace of API calls to the Kubernetes API
+	restConfig.Wrap(func(rt http.RoundTripper) http.RoundTripper {
+		return &logger.RequestContextTransport{Base: rt}
+	})
+
+	kubeRestConfig = restConfig
+
+	return kubeRestConfig, nil
+}
+
+// getKubeClientset returns the clientset acting as the service account of the
+// process.
+func getKubeClientset() (kubernetes.Interface, error) {
+	restConfig, err := getKubeRestConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	kubeMu.Lock()
+	defer kubeMu.Unlock()
+
+	if kubeClientset == nil {
+		clientset, err := kubernetes.NewForConfig(restConfig)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create clientset: %w", err)
+		}
+
+		kubeClientset = clientset
+	}
+
+	return kubeClientset, nil
+}
+
+// kubeClientsetFor returns the clientset used to serve a request. When
+// impersonation is enabled the Kubernetes API is called as the OIDC user and
+// groups of the caller, so cluster RBAC decides what the caller may do.
+func kubeClientsetFor(ctx context.Context) (kubernetes.Interface, error) {
+	cfg := config.GetConfig()
+
+	if !cfg.GetKubeImpersonate() {
+		return getKubeClientset()
+	}
+
+	identity, ok := IdentityFromContext(ctx)
+	if !ok {
+		return nil, errors.New("impersonation is enabled but the request has no identity")
+	}
+
+	restConfig, err := getKubeRestConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	groups := make([]string, 0, len(identity.Groups))
+	for _, group := range identity.Groups {
+		groups = append(groups, cfg.GetKubeImpersonateGroupPrefix()+group)
+	}
+
+	impersonated := rest.CopyConfig(restConfig)
+	impersonated.Impersonate = rest.ImpersonationConfig{
+		UserName: cfg.GetKubeImpersonateUserPrefix() + identity.Username,
+		Groups:   groups,
+	}
+
+	slog.DebugContext(ctx, "Impersonating user", "user", impersonated.Impersonate.UserName, "groups", groups)
+
+	clientset, err := kubernetes.NewForConfig(impersonated)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create impersonating clientset: %w", err)
+	}
+
+	return clientset, nil
+}
@@ -0,0 +1,91 @@
+/**
+ * This work is licensed under Apache License, Version 2.0 or later.
+ * Please read and understand latest version of Licence.
+ */
+package webserver
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/kazimsarikaya/assesmentbarkinrl/internal/config"
+	"github.com/kazimsarikaya/assesmentbarkinrl/internal/logger"
+)
+
+// getLogLevels returns the levels of the process, the levels of other
+// replicas may differ.
+func getLogLevels(w http.ResponseWriter, r *http.Request, data map[string]interface{}) apiActionResult {
+	return func() {
+		if err := json.NewEncoder(w).Encode(logger.ModuleLevels().Status()); err != nil {
+			slog.ErrorContext(r.Context(), "Error encoding response", "error", err)
+		}
+	}
+}
+
+// logLevelOverride is the response of set_log_level. The level is changed
+// in the replica that served the call only.
+type logLevelOverride struct {
+	logger.Override
+	Replica string `json:"replica"`
+	Scope   string `json:"scope"`
+}
+
+// setLogLevel changes the level of a module, the default level when no
+// module is given, until the duration elapses. The duration defaults to
+// logLevelTimeout and is capped at logLevelMaxTimeout. Only the replica
+// serving the call changes its level.
+func setLogLevel(w http.ResponseWriter, r *http.Request, data map[string]interface{}) apiActionResult {
+	return func() {
+		cfg := config.GetConfig()
+
+		module, _ := data["module"].(string)
+		if module == "" {
+			module = logger.DefaultModule
+		}
+
+		if !logger.ModuleLevels().Known(module) {
+			sendError(w, r, errBadRequest("Unknown module "+module+", expected "+logger.DefaultModule+", one of "+
+				strings.Join(logger.Modules, ", ")+" or a module of logLevels"))
+			return
+		}
+
+		levelName, _ := data["level"].(string)
+
+		level, err := logger.ParseLevel(levelName)
+		if err != nil {
+			sendError(w, r, errBadRequest("Invalid level"))
+			return
+		}
+
+		duration := cfg.GetLogLevelTimeout()
+
+		if d, ok := data["duration"].(string); ok && d != "" {
+			duration, err = time.ParseDuration(d)
+			if err != nil || duration <= 0 {
+				sendError(w, r, errBadRequest("Invalid duration"))
+				return
+			}
+		}
+
+		if duration > cfg.GetLogLevelMaxTimeout() {
+			sendError(w, r, errBadRequest("Duration exceeds "+cfg.GetLogLevelMaxTimeout().String()))
+			return
+		}
+
+		override := logger.ModuleLevels().Override(module, level, duration)
+
+		replica, _ := os.Hostname()
+
+		slog.WarnContext(r.Context(), "Log level changed", "module", module, "log_level", level, "expires", override.Expires, "replica", replica)
+
+		response := logLevelOverride{Override: override, Replica: replica, Scope: "replica"}
+
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			slog.ErrorContext(r.Context(), "Error encoding response", "error", err)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+/**
+ * This work is licensed under Apache License, Version 2.0 or later.
+ * Please read and understand latest version of Licence.
+ */
+package webserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestSetLogLevel(t *testing.T) {
+	setTestConfig(t, map[string]interface{}{
+		"logLevelTimeout":    time.Minute,
+		"logLevelMaxTimeout": time.Hour,
+	})
+
+	hostname, _ := os.Hostname()
+
+	tests := []struct {
+		name       string
+		data       map[string]interface{}
+		wantStatus int
+		wantModule string
+	}{
+		{name: "default level", data: map[string]interface{}{"level": "debug", "duration": "10ms"}, wantStatus: http.StatusOK, wantModule: "default"},
+		{name: "known module", data: map[string]interface{}{"module": "scheduler", "level": "warn", "duration": "10ms"}, wantStatus: http.StatusOK, wantModule: "scheduler"},
+		{name: "unknown module", data: map[string]interface{}{"module": "webservr", "level": "debug"}, wantStatus: http.StatusBadRequest},
+		{name: "invalid level", data: map[string]interface{}{"module": "webserver", "level": "verbose"}, wantStatus: http.StatusBadRequest},
+		{name: "invalid duration", data: map[string]interface{}{"level": "debug", "duration": "-1m"}, wantStatus: http.StatusBadRequest},
+		{name: "duration above the maximum", data: map[string]interface{}{"level": "debug", "duration": "2h"}, wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/api", nil)
+			w := httptest.NewRecorder()
+
+			setLogLevel(w, r, tt.data)()
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			var response struct {
+				Module  string `json:"module"`
+				Replica string `json:"replica"`
+				Scope   string `json:"scope"`
+			}
+
+			if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+				t.Fatal(err)
+			}
+
+			if response.Module != tt.wantModule || response.Replica != hostname || response.Scope != "replica" {
+				t.Fatalf("response = %+v", response)
+			}
+		})
+	}
+}
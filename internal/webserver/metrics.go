@@ -0,0 +1,122 @@
+/**
+ * This work is licensed under Apache License, Version 2.0 or later.
+ * Please read and understand latest version of Licence.
+ */
+package webserver
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/gorilla/mux"
+	"github.com/kazimsarikaya/assesmentbarkinrl/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+var (
+	jwksHitsDesc = prometheus.NewDesc("app_jwks_cache_hits_total",
+		"Signing keys served from the JWKS cache.", nil, nil)
+	jwksMissesDesc = prometheus.NewDesc("app_jwks_cache_misses_total",
+		"Signing key lookups that needed a JWKS refresh.", nil, nil)
+	jwksRefreshesDesc = prometheus.NewDesc("app_jwks_cache_refreshes_total",
+		"JWKS refreshes.", nil, nil)
+	jwksRefreshErrorsDesc = prometheus.NewDesc("app_jwks_cache_refresh_errors_total",
+		"Failed JWKS refreshes.", nil, nil)
+	jwksStaleServedDesc = prometheus.NewDesc("app_jwks_cache_stale_served_total",
+		"Signing keys served from an expired JWKS.", nil, nil)
+	jwksKeysDesc = prometheus.NewDesc("app_jwks_cache_keys",
+		"Keys in the JWKS cache.", nil, nil)
+)
+
+// jwksCollector exports the counters of the JWKS cache.
+type jwksCollector struct{}
+
+func (jwksCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- jwksHitsDesc
+	ch <- jwksMissesDesc
+	ch <- jwksRefreshesDesc
+	ch <- jwksRefreshErrorsDesc
+	ch <- jwksStaleServedDesc
+	ch <- jwksKeysDesc
+}
+
+func (jwksCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := GetJWKSCacheStats()
+
+	ch <- prometheus.MustNewConstMetric(jwksHitsDesc, prometheus.CounterValue, float64(stats.Hits))
+	ch <- prometheus.MustNewConstMetric(jwksMissesDesc, prometheus.CounterValue, float64(stats.Misses))
+	ch <- prometheus.MustNewConstMetric(jwksRefreshesDesc, prometheus.CounterValue, float64(stats.Refreshes))
+	ch <- prometheus.MustNewConstMetric(jwksRefreshErrorsDesc, prometheus.CounterValue, float64(stats.RefreshErrors))
+	ch <- prometheus.MustNewConstMetric(jwksStaleServedDesc, prometheus.CounterValue, float64(stats.StaleServed))
+	ch <- prometheus.MustNewConstMetric(jwksKeysDesc, prometheus.GaugeValue, float64(stats.Keys))
+}
+
+func init() {
+	metrics.Registry.MustRegister(jwksCollector{})
+}
+
+// authFailureReason classifies an authentication error for the metrics.
+func authFailureReason(err error) string {
+	switch {
+	case errors.Is(err, errMissingAuthHeader):
+		return "missing_header"
+	case errors.Is(err, errInvalidAuthHeader):
+		return "invalid_header"
+	case errors.Is(err, jwt.ErrTokenExpired):
+		return "expired"
+	case errors.Is(err, jwt.ErrTokenNotValidYet), errors.Is(err, jwt.ErrTokenUsedBeforeIssued):
+		return "not_yet_valid"
+	case errors.Is(err, jwt.ErrTokenMalformed):
+		return "malformed"
+	case errors.Is(err, jwt.ErrTokenSignatureInvalid):
+		return "invalid_signature"
+	case errors.Is(err, jwt.ErrTokenUnverifiable):
+		return "unverifiable"
+	case errors.Is(err, errInvalidIssuer):
+		return "invalid_issuer"
+	case errors.Is(err, errInvalidAudience):
+		return "invalid_audience"
+	default:
+		return "invalid_token"
+	}
+}
+
+// metricsHandler counts the requests and their latency per route. The route
+// template is used so the label has a bounded number of values.
+func metricsHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := "unmatched"
+		if current := mux.CurrentRoute(r); current != nil {
+			if tmpl, err := current.GetPathTemplate(); err == nil {
+				route = tmpl
+			}
+		}
+
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		next.ServeHTTP(recorder, r)
+
+		metrics.HTTPRequests.WithLabelValues(route, r.Method, strconv.Itoa(recorder.status)).Inc()
+		metrics.HTTPRequestDuration.WithLabelValues(route, r.Method).Observe(time.Since(start).Seconds())
+	})
+}
+
+// instrumentAction starts the span of an API action and records its status
+// and latency when the returned function is called.
+func instrumentAction(w http.ResponseWriter, r *http.Request, action string) (http.ResponseWriter, *http.Request, func()) {
+	r, span := startActionSpan(r, action)
+
+	recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+	start := time.Now()
+
+	return recorder, r, func() {
+		metrics.ObserveAction(action, recorder.status, time.Since(start))
+		span.SetAttributes(attribute.Int("http.response.status_code", recorder.status))
+		span.End()
+	}
+}
@@ -0,0 +1,415 @@
+/**
+ * This work is licensed under Apache License, Version 2.0 or later.
+ * Please read and understand latest version of Licence.
+ */
+package webserver
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/kazimsarikaya/assesmentbarkinrl/internal/config"
+)
+
+// Request and response schemas of the API actions.
+var (
+	emptyRequestSchema = objectSchema("No parameters", nil)
+
+	// namespaceSchema is required by the actions on a single ConfigMap, a
+	// default would pick the namespace of a same-named one
+	namespaceSchema = kubeNamespaceSchema("Namespace")
+
+	versionSchema = objectSchema("Build information", map[string]*jsonSchema{
+		"version":    stringSchema("Application version"),
+		"build_time": stringSchema("Build time"),
+		"go_version": stringSchema("Go version used for the build"),
+	}, "version", "build_time", "go_version")
+
+	getConfigMapsRequestSchema = objectSchema("List ConfigMaps", map[string]*jsonSchema{
+		"namespace": kubeNamespaceSchema("Namespace, all namespaces when omitted"),
+	})
+
+	configMapRefSchema = objectSchema("ConfigMap reference", map[string]*jsonSchema{
+		"name":      stringSchema("ConfigMap name"),
+		"namespace": stringSchema("ConfigMap namespace"),
+	}, "name", "namespace")
+
+	configMapRequestSchema = objectSchema("Select a ConfigMap", map[string]*jsonSchema{
+		"name":      kubeNameSchema("ConfigMap name"),
+		"namespace": namespaceSchema,
+	}, "name", "namespace")
+
+	configMapSchema = objectSchema("ConfigMap", map[string]*jsonSchema{
+		"name":      stringSchema("ConfigMap name"),
+		"namespace": stringSchema("ConfigMap namespace"),
+		"data":      stringMapSchema("ConfigMap data"),
+	}, "name", "namespace", "data")
+
+	updateConfigMapRequestSchema = objectSchema("Replace the data of a ConfigMap", map[string]*jsonSchema{
+		"name":      kubeNameSchema("ConfigMap name"),
+		"namespace": namespaceSchema,
+		"data":      stringMapSchema("New ConfigMap data"),
+	}, "name", "namespace", "data")
+
+	statusSchema = objectSchema("Operation status", map[string]*jsonSchema{
+		"status": stringSchema("Result of the operation"),
+	}, "status")
+
+	auditLogRequestSchema = objectSchema("Query the audit log", map[string]*jsonSchema{
+		"username":     stringSchema("Only records of this user"),
+		"audit_action": stringSchema("Only records of this action"),
+		"namespace":    stringSchema("Only records for this namespace"),
+		"name":         stringSchema("Only records for this name"),
+		"outcome":      {Type: "string", Description: "Only records with this outcome", Enum: []interface{}{"success", "failure", "denied"}},
+		"since":        {Type: "string", Format: "date-time", Description: "Only records at or after this time"},
+		"limit":        {Type: "integer", Description: "Maximum number of records", Minimum: floatPtr(1), Maximum: floatPtr(10000)},
+	})
+
+	auditRecordSchema = objectSchema("Audit record", map[string]*jsonSchema{
+		"time":           {Type: "string", Format: "date-time"},
+		"subject":        stringSchema("OIDC subject"),
+		"username":       stringSchema("Username"),
+		"action":         stringSchema("Action"),
+		"namespace":      stringSchema("Target namespace"),
+		"name":           stringSchema("Target name"),
+		"payload_digest": stringSchema("sha256 of the request payload"),
+		"outcome":        stringSchema("success, failure or denied"),
+		"status":         {Type: "integer", Description: "HTTP status"},
+		"latency_ms":     {Type: "number", Description: "Latency in milliseconds"},
+	}, "time", "username", "action", "payload_digest", "outcome", "status", "latency_ms")
+
+	logLevelSchema = stringSchema("Log level, like DEBUG or INFO")
+
+	logLevelOverrideSchema = objectSchema("Log level changed at runtime", map[string]*jsonSchema{
+		"module":  stringSchema("Module, default for the default level"),
+		"level":   logLevelSchema,
+		"expires": {Type: "string", Format: "date-time", Description: "Time the previous level is restored"},
+	}, "module", "level", "expires")
+
+	setLogLevelResponseSchema = objectSchema("Log level changed in the replica that served the call", map[string]*jsonSchema{
+		"module":  stringSchema("Module, default for the default level"),
+		"level":   logLevelSchema,
+		"expires": {Type: "string", Format: "date-time", Description: "Time the previous level is restored"},
+		"replica": stringSchema("Host name of the replica whose level changed, other replicas keep theirs"),
+		"scope":   {Type: "string", Description: "Always replica", Enum: []interface{}{"replica"}},
+	}, "module", "level", "expires", "replica", "scope")
+
+	logLevelsSchema = objectSchema("Log levels", map[string]*jsonSchema{
+		"default":   logLevelSchema,
+		"modules":   stringMapSchema("Configured levels of the modules"),
+		"overrides": arraySchema("Levels changed at runtime", logLevelOverrideSchema),
+	}, "default", "modules", "overrides")
+
+	setLogLevelRequestSchema = objectSchema("Change a log level", map[string]*jsonSchema{
+		"module":   stringSchema("Module, a package like webserver or scheduler or a module of logLevels; the default level when omitted"),
+		"level":    {Type: "string", Description: "New level", Enum: []interface{}{"debug", "info", "warn", "error"}},
+		"duration": stringSchema("Duration until the previous level is restored, like 30m"),
+	}, "level")
+
+	meSchema = objectSchema("Caller identity", map[string]*jsonSchema{
+		"subject":     stringSchema("OIDC subject"),
+		"username":    stringSchema("Username"),
+		"email":       stringSchema("Email"),
+		"groups":      arraySchema("Groups", stringSchema("")),
+		"roles":       arraySchema("Roles", stringSchema("")),
+		"is_admin":    {Type: "boolean", Description: "Member of the admin group"},
+		"permissions": arraySchema("Actions the caller may call", stringSchema("")),
+	}, "subject", "username", "groups", "roles", "is_admin", "permissions")
+
+	clusterSchema = objectSchema("Cluster", map[string]*jsonSchema{
+		"namespace":        stringSchema("Namespace"),
+		"name":             stringSchema("Name"),
+		"data":             stringMapSchema("Cluster parameters"),
+		"resource_version": stringSchema("Resource version, usable with If-Match"),
+		"created_at":       {Type: "string", Format: "date-time"},
+	}, "namespace", "name", "data", "resource_version", "created_at")
+
+	createClusterRequestSchema = objectSchema("Create a cluster", map[string]*jsonSchema{
+		"namespace": kubeNamespaceSchema("Namespace"),
+		"name":      kubeNameSchema("Name"),
+		"data":      stringMapSchema("Cluster parameters"),
+	}, "namespace", "name")
+
+	updateClusterRequestSchema = objectSchema("Replace the parameters of a cluster", map[string]*jsonSchema{
+		"data":             stringMapSchema("Cluster parameters"),
+		"resource_version": stringSchema("Expected resource version"),
+	}, "data")
+
+	errorSchema = objectSchema("Error envelope", map[string]*jsonSchema{
+		"error": objectSchema("Error", map[string]*jsonSchema{
+			"status":  {Type: "integer", Description: "HTTP status code"},
+			"code":    stringSchema("Machine readable error code"),
+			"message": stringSchema("Human readable error message"),
+			"details": {Type: "object", Description: "Additional error details"},
+			"field_errors": arraySchema("Validation failures", objectSchema("Field error", map[string]*jsonSchema{
+				"field":   stringSchema("Path of the invalid field"),
+				"message": stringSchema("Validation failure"),
+			}, "field", "message")),
+			"request_id": stringSchema("Request id"),
+		}, "status", "code", "message"),
+	}, "error")
+)
+
+// actionTitle turns get_configmaps into GetConfigmaps for schema names.
+func actionTitle(action string) string {
+	parts := strings.Split(action, "_")
+
+	for i, part := range parts {
+		if part != "" {
+			parts[i] = strings.ToUpper(part[:1]) + part[1:]
+		}
+	}
+
+	return strings.Join(parts, "")
+}
+
+// actionRequestSchema returns the request schema of an action including the
+// action discriminator.
+func actionRequestSchema(name string, action securedApiAction) *jsonSchema {
+	request := action.request
+	if request == nil {
+		request = emptyRequestSchema
+	}
+
+	properties := map[string]*jsonSchema{
+		"action": {Type: "string", Enum: []interface{}{name}},
+	}
+
+	for key, prop := range request.Properties {
+		properties[key] = prop
+	}
+
+	schema := *request
+	schema.Properties = properties
+	schema.Required = append([]string{"action"}, request.Required...)
+
+	return &schema
+}
+
+// validateActionData validates the data of a request, without the action key,
+// against the request schema of the action.
+func validateActionData(action securedApiAction, data map[string]interface{}) []fieldError {
+	if action.request == nil {
+		return nil
+	}
+
+	params := make(map[string]interface{}, len(data))
+
+	for key, value := range data {
+		if key != "action" {
+			params[key] = value
+		}
+	}
+
+	return action.request.validate("", params)
+}
+
+func jsonContent(schema *jsonSchema) map[string]interface{} {
+	return map[string]interface{}{
+		"application/json": map[string]interface{}{"schema": schema},
+	}
+}
+
+func schemaRef(name string) *jsonSchema {
+	return &jsonSchema{Ref: "#/components/schemas/" + name}
+}
+
+func restOperation(summary string, request *jsonSchema, status string, response *jsonSchema, params ...string) map[string]interface{} {
+	op := map[string]interface{}{
+		"summary":  summary,
+		"security": []map[string][]string{{"bearer": {}}},
+	}
+
+	parameters := []map[string]interface{}{}
+	for _, p := range params {
+		parameters = append(parameters, map[string]interface{}{
+			"name": p, "in": "path", "required": true, "schema": stringSchema(""),
+		})
+	}
+
+	if len(parameters) > 0 {
+		op["parameters"] = parameters
+	}
+
+	if request != nil {
+		op["requestBody"] = map[string]interface{}{"required": true, "content": jsonContent(request)}
+	}
+
+	responses := map[string]interface{}{
+		"default": map[string]interface{}{"description": "Error", "content": jsonContent(schemaRef("Error"))},
+	}
+
+	if response != nil {
+		responses[status] = map[string]interface{}{"description": summary, "content": jsonContent(response)}
+	} else {
+		responses[status] = map[string]interface{}{"description": summary}
+	}
+
+	op["responses"] = responses
+
+	return op
+}
+
+// buildOpenAPIDocument generates the OpenAPI 3.1 document from the action
+// registry and the REST routes.
+func buildOpenAPIDocument() map[string]interface{} {
+	names := make([]string, 0, len(apiActions))
+	for name := range apiActions {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	schemas := map[string]interface{}{
+		"Error":   errorSchema,
+		"Cluster": clusterSchema,
+	}
+
+	requestRefs := []*jsonSchema{}
+	responseRefs := []*jsonSchema{}
+	mapping := map[string]string{}
+	actions := map[string]interface{}{}
+
+	for _, name := range names {
+		action := apiActions[name]
+		title := actionTitle(name)
+
+		schemas[title+"Request"] = actionRequestSchema(name, action)
+		requestRefs = append(requestRefs, schemaRef(title+"Request"))
+		mapping[name] = "#/components/schemas/" + title + "Request"
+
+		entry := map[string]interface{}{
+			"request":    "#/components/schemas/" + title + "Request",
+			"summary":    action.summary,
+			"needs_auth": action.needAuth,
+			"admin_only": action.adminOnly,
+			"mutating":   action.mutating,
+		}
+
+		if action.response != nil {
+			schemas[title+"Response"] = action.response
+			responseRefs = append(responseRefs, schemaRef(title+"Response"))
+			entry["response"] = "#/components/schemas/" + title + "Response"
+		}
+
+		actions[name] = entry
+	}
+
+	actionOperation := func(request map[string]interface{}) map[string]interface{} {
+		op := map[string]interface{}{
+			"summary":     "Call an API action",
+			"description": "The action field selects the operation, see x-actions for the schemas of each action.",
+			"security":    []map[string][]string{{"bearer": {}}, {}},
+			"responses": map[string]interface{}{
+				"200":     map[string]interface{}{"description": "Action result", "content": jsonContent(&jsonSchema{OneOf: responseRefs})},
+				"default": map[string]interface{}{"description": "Error", "content": jsonContent(schemaRef("Error"))},
+			},
+		}
+
+		for k, v := range request {
+			op[k] = v
+		}
+
+		return op
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.1.0",
+		"info": map[string]interface{}{
+			"title":   "app API",
+			"version": config.GetConfig().GetVersion(),
+		},
+		"paths": map[string]interface{}{
+			"/api": map[string]interface{}{
+				"post": actionOperation(map[string]interface{}{
+					"operationId": "callAction",
+					"requestBody": map[string]interface{}{
+						"required": true,
+						"content": map[string]interface{}{
+							"application/json": map[string]interface{}{
+								"schema": map[string]interface{}{
+									"oneOf":         requestRefs,
+									"discriminator": map[string]interface{}{"propertyName": "action", "mapping": mapping},
+								},
+							},
+						},
+					},
+				}),
+				"get": actionOperation(map[string]interface{}{
+					"operationId": "callActionByQuery",
+					"parameters": []map[string]interface{}{{
+						"name": "data", "in": "query", "required": true,
+						"description": "JSON encoded action request",
+						"content":     jsonContent(&jsonSchema{OneOf: requestRefs}),
+					}},
+				}),
+			},
+			"/api/v1/clusters": map[string]interface{}{
+				"get":  restOperation("List clusters", nil, "200", arraySchema("Clusters", schemaRef("Cluster"))),
+				"post": restOperation("Create a cluster", createClusterRequestSchema, "201", schemaRef("Cluster")),
+			},
+			"/api/v1/clusters/{namespace}": map[string]interface{}{
+				"get": restOperation("List clusters of a namespace", nil, "200", arraySchema("Clusters", schemaRef("Cluster")), "namespace"),
+			},
+			"/api/v1/clusters/{namespace}/{name}": map[string]interface{}{
+				"get":    restOperation("Get a cluster", nil, "200", schemaRef("Cluster"), "namespace", "name"),
+				"put":    restOperation("Update a cluster", updateClusterRequestSchema, "200", schemaRef("Cluster"), "namespace", "name"),
+				"delete": restOperation("Delete a cluster", nil, "204", nil, "namespace", "name"),
+			},
+		},
+		"components": map[string]interface{}{
+			"schemas": schemas,
+			"securitySchemes": map[string]interface{}{
+				"bearer": map[string]interface{}{"type": "http", "scheme": "bearer", "bearerFormat": "JWT"},
+			},
+		},
+		"x-actions": actions,
+	}
+}
+
+// OpenAPIHandler serves the OpenAPI document of the API.
+func OpenAPIHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if err := json.NewEncoder(w).Encode(buildOpenAPIDocument()); err != nil {
+		slog.ErrorContext(r.Context(), "Error encoding response", "error", err)
+	}
+}
+
+// ActionSchemaHandler serves the JSON Schema of one action. The document
+// validates the request and carries the response schema in $defs.
+func ActionSchemaHandler(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimSuffix(mux.Vars(r)["action"], ".json")
+
+	action, ok := apiActions[name]
+	if !ok {
+		sendError(w, r, errNotFound("action not found"))
+		return
+	}
+
+	defs := map[string]interface{}{
+		"request": actionRequestSchema(name, action),
+	}
+
+	if action.response != nil {
+		defs["response"] = action.response
+	}
+
+	w.Header().Set("Content-Type", "application/schema+json")
+
+	err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"$schema": "https://json-schema.org/draft/2020-12/schema",
+		"$id":     "/api/schemas/" + name + ".json",
+		"title":   actionTitle(name),
+		"$ref":    "#/$defs/request",
+		"$defs":   defs,
+	})
+
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error encoding response", "error", err)
+	}
+}
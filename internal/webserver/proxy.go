@@ -0,0 +1,109 @@
+/**
+ * This work is licensed under Apache License, Version 2.0 or later.
+ * Please read and understand latest version of Licence.
+ */
+package webserver
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/handlers"
+	"github.com/kazimsarikaya/assesmentbarkinrl/internal/config"
+)
+
+// trustedProxyHeaders applies the forwarded headers only to requests whose
+// socket peer is one of the trustedProxies. Any client can send the headers,
+// honouring them from others would let it pick the address it is rate
+// limited and logged by.
+func trustedProxyHeaders(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxies := parseTrustedProxies(config.GetConfig().GetTrustedProxies())
+
+		peer := net.ParseIP(remoteHost(r.RemoteAddr))
+		if peer == nil || !containsIP(proxies, peer) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		client := forwardedClient(r, peer, proxies)
+
+		// ProxyHeaders takes the scheme and the host, the client address is
+		// replaced afterwards as ProxyHeaders uses the first, client supplied
+		// X-Forwarded-For entry
+		handlers.ProxyHeaders(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r.RemoteAddr = client
+			next.ServeHTTP(w, r)
+		})).ServeHTTP(w, r)
+	})
+}
+
+// forwardedClient walks X-Forwarded-For from the nearest hop and returns the
+// first address that is not a trusted proxy. Entries left of it were written
+// by the client and are ignored. X-Real-IP is used without X-Forwarded-For.
+func forwardedClient(r *http.Request, peer net.IP, proxies []*net.IPNet) string {
+	client := peer
+
+	var hops []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(header, ",")...)
+	}
+
+	if len(hops) == 0 {
+		if ip := net.ParseIP(strings.TrimSpace(r.Header.Get("X-Real-IP"))); ip != nil {
+			client = ip
+		}
+
+		return client.String()
+	}
+
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := net.ParseIP(strings.TrimSpace(hops[i]))
+		if ip == nil {
+			break
+		}
+
+		client = ip
+
+		if !containsIP(proxies, ip) {
+			break
+		}
+	}
+
+	return client.String()
+}
+
+// parseTrustedProxies parses the trustedProxies option, Validate has rejected
+// invalid entries.
+func parseTrustedProxies(cidrs []string) []*net.IPNet {
+	var result []*net.IPNet
+
+	for _, cidr := range cidrs {
+		if _, ipNet, err := net.ParseCIDR(cidr); err == nil {
+			result = append(result, ipNet)
+		}
+	}
+
+	return result
+}
+
+func containsIP(nets []*net.IPNet, ip net.IP) bool {
+	for _, ipNet := range nets {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// remoteHost strips the port of a RemoteAddr, forwarded addresses have none.
+func remoteHost(remoteAddr string) string {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return remoteAddr
+	}
+
+	return host
+}
@@ -0,0 +1,251 @@
+/**
+ * This work is licensed under Apache License, Version 2.0 or later.
+ * Please read and understand latest version of Licence.
+ */
+package webserver
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/kazimsarikaya/assesmentbarkinrl/internal/config"
+	"golang.org/x/time/rate"
+)
+
+// limiterIdleTimeout is how long the buckets of a silent client are kept.
+const limiterIdleTimeout = 10 * time.Minute
+
+// maxLimiterClients bounds the tracked clients, the least recently seen
+// client is dropped for a new one when idle clients do not make room.
+var maxLimiterClients = 10000
+
+// Buckets of a client.
+const (
+	bucketRead = iota
+	bucketWrite
+	// bucketIP is taken before authentication, it bounds the token
+	// validations of a remote address
+	bucketIP
+)
+
+type clientLimiter struct {
+	read     *rate.Limiter
+	write    *rate.Limiter
+	ip       *rate.Limiter
+	lastSeen time.Time
+}
+
+// rateLimiter keeps a read and a mutating token bucket for every client.
+// Clients are the authenticated username or the remote ip. Every remote ip
+// also has a bucket checked before authentication.
+type rateLimiter struct {
+	mu        sync.Mutex
+	clients   map[string]*clientLimiter
+	lastSweep time.Time
+}
+
+var apiRateLimiter = &rateLimiter{clients: map[string]*clientLimiter{}}
+
+func newLimiter(rps float64, burst int) *rate.Limiter {
+	if rps <= 0 {
+		return rate.NewLimiter(rate.Inf, 0)
+	}
+
+	if burst < 1 {
+		burst = 1
+	}
+
+	return rate.NewLimiter(rate.Limit(rps), burst)
+}
+
+// setLimit reconfigures a bucket like newLimiter, keeping its tokens.
+func setLimit(limiter *rate.Limiter, rps float64, burst int) {
+	if rps <= 0 {
+		limiter.SetLimit(rate.Inf)
+		return
+	}
+
+	if burst < 1 {
+		burst = 1
+	}
+
+	limiter.SetLimit(rate.Limit(rps))
+	limiter.SetBurst(burst)
+}
+
+// sweep drops clients that were not seen within limiterIdleTimeout, at most
+// once a minute unless force is set.
+func (rl *rateLimiter) sweep(now time.Time, force bool) {
+	if !force && now.Sub(rl.lastSweep) < time.Minute {
+		return
+	}
+
+	rl.lastSweep = now
+
+	for key, client := range rl.clients {
+		if now.Sub(client.lastSeen) > limiterIdleTimeout {
+			delete(rl.clients, key)
+		}
+	}
+}
+
+// evictOldest drops the least recently seen client.
+func (rl *rateLimiter) evictOldest() {
+	var oldestKey string
+
+	var oldest time.Time
+
+	for key, client := range rl.clients {
+		if oldestKey == "" || client.lastSeen.Before(oldest) {
+			oldestKey, oldest = key, client.lastSeen
+		}
+	}
+
+	delete(rl.clients, oldestKey)
+}
+
+// allow takes a token from a bucket of the client. When the bucket is empty
+// it returns false and the time after which a retry succeeds.
+func (rl *rateLimiter) allow(key string, bucket int) (bool, time.Duration) {
+	cfg := config.GetConfig()
+	now := time.Now()
+
+	rl.mu.Lock()
+
+	rl.sweep(now, false)
+
+	client, ok := rl.clients[key]
+	if !ok {
+		if len(rl.clients) >= maxLimiterClients {
+			rl.sweep(now, true)
+		}
+
+		if len(rl.clients) >= maxLimiterClients {
+			rl.evictOldest()
+		}
+
+		client = &clientLimiter{
+			read:  newLimiter(cfg.GetRateLimitReadRPS(), cfg.GetRateLimitReadBurst()),
+			write: newLimiter(cfg.GetRateLimitWriteRPS(), cfg.GetRateLimitWriteBurst()),
+			ip:    newLimiter(cfg.GetRateLimitIPRPS(), cfg.GetRateLimitIPBurst()),
+		}
+		rl.clients[key] = client
+	}
+
+	client.lastSeen = now
+
+	rl.mu.Unlock()
+
+	limiter := client.read
+
+	switch bucket {
+	case bucketWrite:
+		limiter = client.write
+	case bucketIP:
+		limiter = client.ip
+	}
+
+	reservation := limiter.ReserveN(now, 1)
+	if !reservation.OK() {
+		return false, time.Second
+	}
+
+	if delay := reservation.DelayFrom(now); delay > 0 {
+		reservation.CancelAt(now)
+		return false, delay
+	}
+
+	return true, 0
+}
+
+// clientKey identifies the caller for rate limiting. trustedProxyHeaders has
+// already replaced RemoteAddr with the forwarded address when the peer is a
+// trusted proxy, otherwise it is the socket peer.
+func clientKey(r *http.Request, identity *Identity) string {
+	if identity != nil && identity.Username != "" {
+		return "user:" + identity.Username
+	}
+
+	return ipKey(r)
+}
+
+func ipKey(r *http.Request) string {
+	return "ip:" + remoteHost(r.RemoteAddr)
+}
+
+// checkRateLimit sends 429 with Retry-After and returns false when the client
+// has exhausted its budget.
+func checkRateLimit(w http.ResponseWriter, r *http.Request, identity *Identity, mutating bool) bool {
+	return checkBucket(w, r, clientKey(r, identity), actionBucket(mutating))
+}
+
+// takeRateLimit takes a token like checkRateLimit but returns the error
+// instead of sending it, for the sub-actions of a batch.
+func takeRateLimit(r *http.Request, identity *Identity, mutating bool) *apiError {
+	allowed, retryAfter := apiRateLimiter.allow(clientKey(r, identity), actionBucket(mutating))
+	if allowed {
+		return nil
+	}
+
+	return errRateLimited(retryAfter)
+}
+
+func actionBucket(mutating bool) int {
+	if mutating {
+		return bucketWrite
+	}
+
+	return bucketRead
+}
+
+// checkIPRateLimit is checkRateLimit for the bucket of the remote address,
+// taken before the token is validated so bad tokens are throttled too.
+func checkIPRateLimit(w http.ResponseWriter, r *http.Request) bool {
+	return checkBucket(w, r, ipKey(r), bucketIP)
+}
+
+func checkBucket(w http.ResponseWriter, r *http.Request, key string, bucket int) bool {
+	allowed, retryAfter := apiRateLimiter.allow(key, bucket)
+	if allowed {
+		return true
+	}
+
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfterSeconds(retryAfter)))
+
+	sendError(w, r, errRateLimited(retryAfter))
+
+	return false
+}
+
+func errRateLimited(retryAfter time.Duration) *apiError {
+	apiErr := newAPIError(http.StatusTooManyRequests, codeRateLimited, "rate limit exceeded")
+	apiErr.Details = map[string]int{"retry_after": retryAfterSeconds(retryAfter)}
+
+	return apiErr
+}
+
+func retryAfterSeconds(retryAfter time.Duration) int {
+	return int(math.Ceil(retryAfter.Seconds()))
+}
+
+// limitBody caps the request body at the configured maximum size.
+func limitBody(w http.ResponseWriter, r *http.Request) {
+	if maxSize := config.GetConfig().GetApiMaxBodySize(); maxSize > 0 && r.Body != nil {
+		r.Body = http.MaxBytesReader(w, r.Body, maxSize)
+	}
+}
+
+// bodyError maps a body read error to 413 when the body is too large.
+func bodyError(err error) *apiError {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return newAPIError(http.StatusRequestEntityTooLarge, codePayloadTooLarge,
+			"request body is larger than "+strconv.FormatInt(maxBytesErr.Limit, 10)+" bytes")
+	}
+
+	return errBadRequest(err.Error())
+}
@@ -0,0 +1,329 @@
+/**
+ * This work is licensed under Apache License, Version 2.0 or later.
+ * Please read and understand latest version of Licence.
+ */
+package webserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// setRateLimitTestConfig gives every bucket a burst of two and a refill of one
+// token a minute, and a fresh limiter.
+func setRateLimitTestConfig(t *testing.T) {
+	t.Helper()
+
+	setTestConfig(t, map[string]interface{}{
+		"rateLimitReadRPS":    1.0 / 60,
+		"rateLimitReadBurst":  2,
+		"rateLimitWriteRPS":   1.0 / 60,
+		"rateLimitWriteBurst": 2,
+		"rateLimitIPRPS":      1.0 / 60,
+		"rateLimitIPBurst":    2,
+	})
+
+	previous := apiRateLimiter
+	apiRateLimiter = &rateLimiter{clients: map[string]*clientLimiter{}}
+
+	t.Cleanup(func() { apiRateLimiter = previous })
+}
+
+func newRateLimitRequest(remoteAddr string) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, "/api", nil)
+	r.RemoteAddr = remoteAddr
+
+	return r
+}
+
+func TestCheckRateLimit(t *testing.T) {
+	alice := &Identity{Username: "alice"}
+
+	type call struct {
+		remoteAddr string
+		identity   *Identity
+		mutating   bool
+		want       bool
+	}
+
+	tests := []struct {
+		name  string
+		calls []call
+	}{
+		{
+			name: "read bucket",
+			calls: []call{
+				{"10.0.0.1:1000", alice, false, true},
+				{"10.0.0.1:1000", alice, false, true},
+				{"10.0.0.1:1000", alice, false, false},
+			},
+		},
+		{
+			name: "write bucket is separate",
+			calls: []call{
+				{"10.0.0.1:1000", alice, false, true},
+				{"10.0.0.1:1000", alice, false, true},
+				{"10.0.0.1:1000", alice, true, true},
+				{"10.0.0.1:1000", alice, true, true},
+				{"10.0.0.1:1000", alice, true, false},
+			},
+		},
+		{
+			name: "users are keyed by name, not address",
+			calls: []call{
+				{"10.0.0.1:1000", alice, false, true},
+				{"10.0.0.2:1000", alice, false, true},
+				{"10.0.0.3:1000", alice, false, false},
+				{"10.0.0.3:1000", &Identity{Username: "bob"}, false, true},
+			},
+		},
+		{
+			name: "anonymous callers are keyed by address without port",
+			calls: []call{
+				{"10.0.0.1:1000", nil, false, true},
+				{"10.0.0.1:2000", nil, false, true},
+				{"10.0.0.1:3000", nil, false, false},
+				{"10.0.0.2:1000", nil, false, true},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setRateLimitTestConfig(t)
+
+			for i, c := range tt.calls {
+				w := httptest.NewRecorder()
+
+				if got := checkRateLimit(w, newRateLimitRequest(c.remoteAddr), c.identity, c.mutating); got != c.want {
+					t.Fatalf("call %d: allowed = %v, want %v", i, got, c.want)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckRateLimitResponse(t *testing.T) {
+	setRateLimitTestConfig(t)
+
+	r := newRateLimitRequest("10.0.0.1:1000")
+
+	for i := 0; i < 2; i++ {
+		if !checkIPRateLimit(httptest.NewRecorder(), r) {
+			t.Fatalf("call %d rejected within the burst", i)
+		}
+	}
+
+	w := httptest.NewRecorder()
+
+	if checkIPRateLimit(w, r) {
+		t.Fatal("call beyond the burst allowed")
+	}
+
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusTooManyRequests)
+	}
+
+	// a token a minute
+	if got := w.Header().Get("Retry-After"); got != "60" {
+		t.Fatalf("Retry-After = %q, want 60", got)
+	}
+
+	var body struct {
+		Error apiError `json:"error"`
+	}
+
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+
+	if body.Error.Code != codeRateLimited {
+		t.Fatalf("code = %q, want %q", body.Error.Code, codeRateLimited)
+	}
+
+	// the authenticated buckets of the same address are not used up
+	if !checkRateLimit(httptest.NewRecorder(), r, nil, false) {
+		t.Fatal("read bucket drained by the ip bucket")
+	}
+}
+
+func TestRateLimitDisabled(t *testing.T) {
+	setRateLimitTestConfig(t)
+	setTestConfig(t, map[string]interface{}{"rateLimitReadRPS": 0.0})
+
+	for i := 0; i < 10; i++ {
+		if allowed, _ := apiRateLimiter.allow("user:alice", bucketRead); !allowed {
+			t.Fatalf("call %d rejected without a read limit", i)
+		}
+	}
+}
+
+func TestRateLimiterSweep(t *testing.T) {
+	setRateLimitTestConfig(t)
+
+	rl := apiRateLimiter
+
+	rl.allow("user:idle", bucketRead)
+	rl.allow("user:active", bucketRead)
+
+	rl.mu.Lock()
+	rl.clients["user:idle"].lastSeen = time.Now().Add(-limiterIdleTimeout - time.Minute)
+	rl.lastSweep = time.Now().Add(-2 * time.Minute)
+	rl.mu.Unlock()
+
+	rl.allow("user:active", bucketRead)
+
+	if _, ok := rl.clients["user:idle"]; ok {
+		t.Fatal("idle client kept")
+	}
+
+	if _, ok := rl.clients["user:active"]; !ok {
+		t.Fatal("active client dropped")
+	}
+}
+
+func TestRateLimiterCap(t *testing.T) {
+	setRateLimitTestConfig(t)
+
+	previous := maxLimiterClients
+	maxLimiterClients = 3
+
+	t.Cleanup(func() { maxLimiterClients = previous })
+
+	rl := apiRateLimiter
+
+	for i := 0; i < 10; i++ {
+		rl.allow(fmt.Sprintf("ip:10.0.0.%d", i), bucketIP)
+
+		// distinct lastSeen times
+		rl.mu.Lock()
+		for _, client := range rl.clients {
+			client.lastSeen = client.lastSeen.Add(-time.Second)
+		}
+		rl.mu.Unlock()
+	}
+
+	if len(rl.clients) != 3 {
+		t.Fatalf("clients = %d, want 3", len(rl.clients))
+	}
+
+	for _, key := range []string{"ip:10.0.0.7", "ip:10.0.0.8", "ip:10.0.0.9"} {
+		if _, ok := rl.clients[key]; !ok {
+			t.Fatalf("recent client %s dropped", key)
+		}
+	}
+}
+
+func TestTrustedProxyHeaders(t *testing.T) {
+	tests := []struct {
+		name       string
+		proxies    []string
+		remoteAddr string
+		headers    map[string]string
+		want       string
+	}{
+		{
+			name:       "spoofed header from an untrusted peer",
+			remoteAddr: "203.0.113.7:4000",
+			headers:    map[string]string{"X-Forwarded-For": "198.51.100.1"},
+			want:       "203.0.113.7:4000",
+		},
+		{
+			name:       "spoofed X-Real-IP from an untrusted peer",
+			proxies:    []string{"10.0.0.0/8"},
+			remoteAddr: "203.0.113.7:4000",
+			headers:    map[string]string{"X-Real-IP": "198.51.100.1"},
+			want:       "203.0.113.7:4000",
+		},
+		{
+			name:       "trusted proxy",
+			proxies:    []string{"10.0.0.0/8"},
+			remoteAddr: "10.1.2.3:4000",
+			headers:    map[string]string{"X-Forwarded-For": "198.51.100.1"},
+			want:       "198.51.100.1",
+		},
+		{
+			name:       "client supplied entries before the proxy",
+			proxies:    []string{"10.0.0.0/8"},
+			remoteAddr: "10.1.2.3:4000",
+			headers:    map[string]string{"X-Forwarded-For": "192.0.2.99, 198.51.100.1"},
+			want:       "198.51.100.1",
+		},
+		{
+			name:       "chained trusted proxies",
+			proxies:    []string{"10.0.0.0/8"},
+			remoteAddr: "10.1.2.3:4000",
+			headers:    map[string]string{"X-Forwarded-For": "198.51.100.1, 10.9.9.9"},
+			want:       "198.51.100.1",
+		},
+		{
+			name:       "X-Real-IP from a trusted proxy",
+			proxies:    []string{"10.0.0.0/8"},
+			remoteAddr: "10.1.2.3:4000",
+			headers:    map[string]string{"X-Real-IP": "198.51.100.1"},
+			want:       "198.51.100.1",
+		},
+		{
+			name:       "trusted proxy without headers",
+			proxies:    []string{"10.0.0.0/8"},
+			remoteAddr: "10.1.2.3:4000",
+			want:       "10.1.2.3",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setRateLimitTestConfig(t)
+			setTestConfig(t, map[string]interface{}{"trustedProxies": tt.proxies})
+
+			var got string
+
+			handler := trustedProxyHeaders(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = r.RemoteAddr
+			}))
+
+			r := newRateLimitRequest(tt.remoteAddr)
+			for key, value := range tt.headers {
+				r.Header.Set(key, value)
+			}
+
+			handler.ServeHTTP(httptest.NewRecorder(), r)
+
+			if got != tt.want {
+				t.Fatalf("RemoteAddr = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSpoofedForwardedForSharesBucket(t *testing.T) {
+	setRateLimitTestConfig(t)
+
+	allowed := 0
+
+	handler := trustedProxyHeaders(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if checkIPRateLimit(w, r) {
+			allowed++
+		}
+	}))
+
+	// a fresh forwarded address on every call does not give a fresh bucket
+	for i := 0; i < 5; i++ {
+		r := newRateLimitRequest("203.0.113.7:4000")
+		r.Header.Set("X-Forwarded-For", fmt.Sprintf("198.51.100.%d", i))
+
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	if allowed != 2 {
+		t.Fatalf("allowed = %d, want the burst of 2", allowed)
+	}
+
+	if len(apiRateLimiter.clients) != 1 {
+		t.Fatalf("clients = %d, want 1", len(apiRateLimiter.clients))
+	}
+}
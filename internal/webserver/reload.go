@@ -0,0 +1,76 @@
+/**
+ * This work is licensed under Apache License, Version 2.0 or later.
+ * Please read and understand latest version of Licence.
+ */
+package webserver
+
+import (
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/kazimsarikaya/assesmentbarkinrl/internal/config"
+)
+
+func init() {
+	config.Subscribe(onConfigChange)
+}
+
+// onConfigChange applies reloaded options to the state built from them.
+// Options read per request need nothing.
+func onConfigChange(old, next config.Config, changed []string) {
+	changedAny := func(keys ...string) bool {
+		for _, key := range keys {
+			if slices.Contains(changed, key) {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	if changedAny("oidcIssuer") {
+		slog.Info("OIDC issuer changed, dropping cached keys", "old", old.GetOidcIssuer(), "new", next.GetOidcIssuer())
+
+		keyCache.invalidate()
+		oidcHealth.invalidate()
+	}
+
+	if changedAny("rateLimitReadRPS", "rateLimitReadBurst", "rateLimitWriteRPS", "rateLimitWriteBurst", "rateLimitIPRPS", "rateLimitIPBurst") {
+		apiRateLimiter.configure(next)
+	}
+}
+
+// invalidate drops the cached keys, the next token triggers a refresh.
+func (c *jwksCache) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.issuer = ""
+	c.oidcConfig = nil
+	c.keys = nil
+	c.fetchedAt = time.Time{}
+	c.lastRefreshAt = time.Time{}
+	c.lastRefreshErr = nil
+}
+
+func (c *oidcCheck) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.checkedAt = time.Time{}
+	c.lastErr = nil
+}
+
+// configure applies new rates to the buckets of the known clients, keeping
+// their tokens.
+func (rl *rateLimiter) configure(cfg config.Config) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	for _, client := range rl.clients {
+		setLimit(client.read, cfg.GetRateLimitReadRPS(), cfg.GetRateLimitReadBurst())
+		setLimit(client.write, cfg.GetRateLimitWriteRPS(), cfg.GetRateLimitWriteBurst())
+		setLimit(client.ip, cfg.GetRateLimitIPRPS(), cfg.GetRateLimitIPBurst())
+	}
+}
@@ -0,0 +1,342 @@
+/**
+ * This work is licensed under Apache License, Version 2.0 or later.
+ * Please read and understand latest version of Licence.
+ */
+package webserver
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/kazimsarikaya/assesmentbarkinrl/internal/logger"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// restHandler serves a REST route. data holds the decoded JSON body merged
+// with the route variables.
+type restHandler func(w http.ResponseWriter, r *http.Request, data map[string]interface{})
+
+// registerRestRoutes adds the versioned resource routes to the api router.
+func registerRestRoutes(apiRouter *mux.Router) {
+	v1 := apiRouter.PathPrefix("/v1").Subrouter()
+
+	v1.HandleFunc("/clusters", restEndpoint("list_clusters", false, nil, listClustersHandler)).Methods(http.MethodGet)
+	v1.HandleFunc("/clusters", restEndpoint("create_cluster", true, createClusterRequestSchema, createClusterHandler)).Methods(http.MethodPost)
+	v1.HandleFunc("/clusters/{namespace}", restEndpoint("list_clusters", false, nil, listClustersHandler)).Methods(http.MethodGet)
+	v1.HandleFunc("/clusters/{namespace}/{name}", restEndpoint("get_cluster", false, nil, getClusterHandler)).Methods(http.MethodGet)
+	v1.HandleFunc("/clusters/{namespace}/{name}", restEndpoint("update_cluster", true, updateClusterRequestSchema, updateClusterHandler)).Methods(http.MethodPut)
+	v1.HandleFunc("/clusters/{namespace}/{name}", restEndpoint("delete_cluster", true, nil, deleteClusterHandler)).Methods(http.MethodDelete)
+}
+
+// routeVarSchemas validate the route variables.
+var routeVarSchemas = map[string]*jsonSchema{
+	"namespace": kubeNamespaceSchema("Namespace"),
+	"name":      kubeNameSchema("Name"),
+}
+
+// validateRestData checks the body against the request schema of the route,
+// an empty object when it has none, and the route variables against
+// routeVarSchemas, like ApiHandler validates the data of actions.
+func validateRestData(request *jsonSchema, body map[string]interface{}, vars map[string]string) []fieldError {
+	if request == nil {
+		request = emptyRequestSchema
+	}
+
+	errs := request.validate("", body)
+
+	for key, value := range vars {
+		if schema, ok := routeVarSchemas[key]; ok {
+			errs = append(errs, schema.validate(key, value)...)
+		}
+	}
+
+	return errs
+}
+
+// restEndpoint decodes the request, authenticates the caller, validates the
+// body against request and audits mutating routes the same way ApiHandler
+// does for actions.
+func restEndpoint(action string, mutating bool, request *jsonSchema, handler restHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		data := map[string]interface{}{}
+		body := map[string]interface{}{}
+
+		if r.Body != nil && r.ContentLength != 0 && r.Method != http.MethodGet {
+			limitBody(w, r)
+
+			// only JSON bodies, they cannot be posted cross-site without a preflight
+			if mediaType, err := requestMediaType(r); err != nil || mediaType != mediaTypeJSON {
+				sendError(w, r, newAPIError(http.StatusUnsupportedMediaType, codeUnsupportedMediaType, "Content-Type must be "+mediaTypeJSON))
+				return
+			}
+
+			buf, err := io.ReadAll(r.Body)
+			if err != nil {
+				sendError(w, r, bodyError(err))
+				return
+			}
+
+			if len(buf) > 0 {
+				if err := json.Unmarshal(buf, &body); err != nil {
+					sendError(w, r, errBadRequest(err.Error()))
+					return
+				}
+			}
+		}
+
+		for key, value := range body {
+			data[key] = value
+		}
+
+		for key, value := range mux.Vars(r) {
+			data[key] = value
+		}
+
+		w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+
+		w, r, done := instrumentAction(w, r, action)
+		defer done()
+
+		if mutating {
+			recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+			w = recorder
+			start := time.Now()
+
+			defer func() {
+				auditApiAction(r, action, data, recorder.status, time.Since(start))
+			}()
+		}
+
+		if !checkIPRateLimit(w, r) {
+			return
+		}
+
+		identity, err := authenticateRequest(r)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Authentication failed", "error", err)
+			sendError(w, r, errUnauthenticated(err.Error()))
+			return
+		}
+
+		logger.SetRequestUsername(r.Context(), identity.Username)
+		r = r.WithContext(withIdentity(r.Context(), identity))
+
+		if !checkRateLimit(w, r, identity, mutating) {
+			return
+		}
+
+		if errs := validateRestData(request, body, mux.Vars(r)); len(errs) > 0 {
+			sendError(w, r, errValidation(errs))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		if mutating {
+			withIdempotency(w, r, action, data, func(w http.ResponseWriter) {
+				handler(w, r, data)
+			})
+			return
+		}
+
+		handler(w, r, data)
+	}
+}
+
+// setETag sends the resource version of cm as its entity tag, usable with
+// If-Match.
+func setETag(w http.ResponseWriter, cm *corev1.ConfigMap) {
+	w.Header().Set("ETag", strconv.Quote(cm.ResourceVersion))
+}
+
+// ifMatchVersion returns the resource version of the If-Match header. Quoted
+// and weak entity tags are accepted, "*" and a missing header expect none.
+func ifMatchVersion(r *http.Request) string {
+	value := strings.TrimSpace(r.Header.Get("If-Match"))
+	if value == "*" {
+		return ""
+	}
+
+	value = strings.TrimPrefix(value, "W/")
+
+	if unquoted, err := strconv.Unquote(value); err == nil {
+		return unquoted
+	}
+
+	return value
+}
+
+func writeJSON(w http.ResponseWriter, r *http.Request, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.ErrorContext(r.Context(), "Error encoding response", "error", err)
+	}
+}
+
+func listClustersHandler(w http.ResponseWriter, r *http.Request, data map[string]interface{}) {
+	namespace, _ := data["namespace"].(string)
+	if namespace == "" {
+		namespace = r.URL.Query().Get("namespace")
+	}
+
+	service, err := newClusterService(r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error creating clientset", "error", err)
+		sendError(w, r, errInternal("Clientset error"))
+		return
+	}
+
+	cms, err := service.listConfigMaps(r.Context(), namespace, true)
+	if err != nil {
+		sendError(w, r, kubeAPIError(r.Context(), err))
+		return
+	}
+
+	result := []clusterView{}
+	for i := range cms {
+		result = append(result, newClusterView(&cms[i]))
+	}
+
+	writeJSON(w, r, http.StatusOK, result)
+}
+
+func getClusterHandler(w http.ResponseWriter, r *http.Request, data map[string]interface{}) {
+	namespace, _ := data["namespace"].(string)
+	name, _ := data["name"].(string)
+
+	service, err := newClusterService(r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error creating clientset", "error", err)
+		sendError(w, r, errInternal("Clientset error"))
+		return
+	}
+
+	cm, err := service.getConfigMap(r.Context(), namespace, name)
+	if err != nil {
+		sendError(w, r, kubeAPIError(r.Context(), err))
+		return
+	}
+
+	if !isClusterConfigMap(cm) {
+		sendError(w, r, errNotFound("cluster not found"))
+		return
+	}
+
+	setETag(w, cm)
+	writeJSON(w, r, http.StatusOK, newClusterView(cm))
+}
+
+func createClusterHandler(w http.ResponseWriter, r *http.Request, data map[string]interface{}) {
+	namespace, _ := data["namespace"].(string)
+	name, _ := data["name"].(string)
+
+	if namespace == "" || name == "" {
+		sendError(w, r, errBadRequest("namespace and name are required"))
+		return
+	}
+
+	dataMap := map[string]string{}
+	if rawData, ok := data["data"]; ok {
+		if dataMap, ok = stringMap(rawData); !ok {
+			sendError(w, r, errBadRequest("Invalid data format"))
+			return
+		}
+	}
+
+	service, err := newClusterService(r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error creating clientset", "error", err)
+		sendError(w, r, errInternal("Clientset error"))
+		return
+	}
+
+	cm, err := service.createCluster(r.Context(), namespace, name, dataMap)
+	if err != nil {
+		sendError(w, r, kubeAPIError(r.Context(), err))
+		return
+	}
+
+	w.Header().Set("Location", "/api/v1/clusters/"+cm.Namespace+"/"+cm.Name)
+	setETag(w, cm)
+	writeJSON(w, r, http.StatusCreated, newClusterView(cm))
+}
+
+func updateClusterHandler(w http.ResponseWriter, r *http.Request, data map[string]interface{}) {
+	namespace, _ := data["namespace"].(string)
+	name, _ := data["name"].(string)
+
+	dataMap, ok := stringMap(data["data"])
+	if !ok {
+		sendError(w, r, errBadRequest("Missing or invalid data"))
+		return
+	}
+
+	// Optimistic concurrency with the resource version or If-Match.
+	resourceVersion, _ := data["resource_version"].(string)
+	if ifMatch := ifMatchVersion(r); ifMatch != "" {
+		resourceVersion = ifMatch
+	}
+
+	service, err := newClusterService(r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error creating clientset", "error", err)
+		sendError(w, r, errInternal("Clientset error"))
+		return
+	}
+
+	cm, err := service.getConfigMap(r.Context(), namespace, name)
+	if err == nil && !isClusterConfigMap(cm) {
+		sendError(w, r, errNotFound("cluster not found"))
+		return
+	}
+
+	if err == nil {
+		cm, err = service.updateConfigMap(r.Context(), cm, dataMap, resourceVersion)
+	}
+
+	if err != nil {
+		sendError(w, r, kubeAPIError(r.Context(), err))
+		return
+	}
+
+	setETag(w, cm)
+	writeJSON(w, r, http.StatusOK, newClusterView(cm))
+}
+
+func deleteClusterHandler(w http.ResponseWriter, r *http.Request, data map[string]interface{}) {
+	namespace, _ := data["namespace"].(string)
+	name, _ := data["name"].(string)
+
+	service, err := newClusterService(r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error creating clientset", "error", err)
+		sendError(w, r, errInternal("Clientset error"))
+		return
+	}
+
+	cm, err := service.getConfigMap(r.Context(), namespace, name)
+	if err == nil && !isClusterConfigMap(cm) {
+		sendError(w, r, errNotFound("cluster not found"))
+		return
+	}
+
+	// the delete fails when the cluster changed since the read or If-Match
+	if err == nil {
+		err = service.deleteConfigMap(r.Context(), cm, ifMatchVersion(r))
+	}
+
+	if err != nil {
+		sendError(w, r, kubeAPIError(r.Context(), err))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
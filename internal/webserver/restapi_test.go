@@ -0,0 +1,99 @@
+/**
+ * This work is licensed under Apache License, Version 2.0 or later.
+ * Please read and understand latest version of Licence.
+ */
+package webserver
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestIfMatchVersion(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{header: "", want: ""},
+		{header: "*", want: ""},
+		{header: `"123"`, want: "123"},
+		{header: `W/"123"`, want: "123"},
+		{header: ` "123" `, want: "123"},
+		{header: "123", want: "123"},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest("PUT", "/api/v1/clusters/team/db", nil)
+		if tt.header != "" {
+			r.Header.Set("If-Match", tt.header)
+		}
+
+		if got := ifMatchVersion(r); got != tt.want {
+			t.Errorf("ifMatchVersion(%q) = %q, want %q", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestValidateRestData(t *testing.T) {
+	tests := []struct {
+		name    string
+		request *jsonSchema
+		body    map[string]interface{}
+		vars    map[string]string
+		want    []string
+	}{
+		{
+			name:    "valid update",
+			request: updateClusterRequestSchema,
+			body:    map[string]interface{}{"data": map[string]interface{}{"size": "1"}},
+			vars:    map[string]string{"namespace": "team", "name": "db"},
+		},
+		{
+			name:    "missing data",
+			request: updateClusterRequestSchema,
+			body:    map[string]interface{}{},
+			vars:    map[string]string{"namespace": "team", "name": "db"},
+			want:    []string{"data"},
+		},
+		{
+			name:    "invalid route variables",
+			request: nil,
+			body:    map[string]interface{}{},
+			vars:    map[string]string{"namespace": "Team_A", "name": "-db"},
+			want:    []string{"namespace", "name"},
+		},
+		{
+			name:    "body without schema",
+			request: nil,
+			body:    map[string]interface{}{"force": true},
+			want:    []string{"force"},
+		},
+		{
+			name:    "create without name",
+			request: createClusterRequestSchema,
+			body:    map[string]interface{}{"namespace": "team", "data": map[string]interface{}{"size": 1}},
+			want:    []string{"name", "data.size"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := validateRestData(tt.request, tt.body, tt.vars)
+
+			fields := map[string]bool{}
+			for _, err := range errs {
+				fields[err.Field] = true
+			}
+
+			if len(errs) != len(tt.want) {
+				t.Fatalf("errors = %+v, want fields %v", errs, tt.want)
+			}
+
+			for _, field := range tt.want {
+				if !fields[field] {
+					t.Fatalf("errors = %+v, want fields %v", errs, tt.want)
+				}
+			}
+		})
+	}
+}
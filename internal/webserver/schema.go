@@ -0,0 +1,285 @@
+/**
+ * This work is licensed under Apache License, Version 2.0 or later.
+ * Please read and understand latest version of Licence.
+ */
+package webserver
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// jsonSchema is the subset of JSON Schema used to describe and validate
+// action payloads. It marshals to a valid JSON Schema / OpenAPI 3.1 schema.
+type jsonSchema struct {
+	Ref                  string                 `json:"$ref,omitempty"`
+	Type                 string                 `json:"type,omitempty"`
+	Description          string                 `json:"description,omitempty"`
+	Format               string                 `json:"format,omitempty"`
+	Properties           map[string]*jsonSchema `json:"properties,omitempty"`
+	Required             []string               `json:"required,omitempty"`
+	AdditionalProperties interface{}            `json:"additionalProperties,omitempty"`
+	Items                *jsonSchema            `json:"items,omitempty"`
+	Enum                 []interface{}          `json:"enum,omitempty"`
+	MinLength            *int                   `json:"minLength,omitempty"`
+	MaxLength            *int                   `json:"maxLength,omitempty"`
+	Pattern              string                 `json:"pattern,omitempty"`
+	Minimum              *float64               `json:"minimum,omitempty"`
+	Maximum              *float64               `json:"maximum,omitempty"`
+	MaxItems             *int                   `json:"maxItems,omitempty"`
+	// OneOf is only used for documentation, validate ignores it.
+	OneOf []*jsonSchema `json:"oneOf,omitempty"`
+}
+
+// fieldError is a validation failure of a single field.
+type fieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func intPtr(v int) *int {
+	return &v
+}
+
+func floatPtr(v float64) *float64 {
+	return &v
+}
+
+func objectSchema(description string, properties map[string]*jsonSchema, required ...string) *jsonSchema {
+	if properties == nil {
+		properties = map[string]*jsonSchema{}
+	}
+
+	return &jsonSchema{
+		Type:                 "object",
+		Description:          description,
+		Properties:           properties,
+		Required:             required,
+		AdditionalProperties: false,
+	}
+}
+
+func stringSchema(description string) *jsonSchema {
+	return &jsonSchema{Type: "string", Description: description}
+}
+
+func arraySchema(description string, items *jsonSchema) *jsonSchema {
+	return &jsonSchema{Type: "array", Description: description, Items: items}
+}
+
+func stringMapSchema(description string) *jsonSchema {
+	return &jsonSchema{Type: "object", Description: description, AdditionalProperties: &jsonSchema{Type: "string"}}
+}
+
+// kubeNameSchema matches a DNS-1123 subdomain, the name format of ConfigMaps.
+func kubeNameSchema(description string) *jsonSchema {
+	return &jsonSchema{
+		Type:        "string",
+		Description: description,
+		MinLength:   intPtr(1),
+		MaxLength:   intPtr(253),
+		Pattern:     `^[a-z0-9]([-a-z0-9.]*[a-z0-9])?$`,
+	}
+}
+
+// kubeNamespaceSchema matches a DNS-1123 label, the name format of
+// namespaces.
+func kubeNamespaceSchema(description string) *jsonSchema {
+	return &jsonSchema{
+		Type:        "string",
+		Description: description,
+		MinLength:   intPtr(1),
+		MaxLength:   intPtr(63),
+		Pattern:     `^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`,
+	}
+}
+
+var patternCache sync.Map
+
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	if re, ok := patternCache.Load(pattern); ok {
+		return re.(*regexp.Regexp), nil
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	patternCache.Store(pattern, re)
+
+	return re, nil
+}
+
+// inEnum compares scalars only, maps and slices are never part of an enum.
+func inEnum(enum []interface{}, v interface{}) bool {
+	switch v.(type) {
+	case string, float64, bool:
+		return slices.Contains(enum, v)
+	default:
+		return false
+	}
+}
+
+func joinField(path, key string) string {
+	if path == "" {
+		return key
+	}
+
+	return path + "." + key
+}
+
+// validate checks v against the schema and returns all field errors. Numbers
+// and booleans are also accepted as strings since form posts carry only
+// strings.
+func (s *jsonSchema) validate(path string, v interface{}) []fieldError {
+	if s == nil {
+		return nil
+	}
+
+	errs := []fieldError{}
+
+	fail := func(format string, args ...interface{}) []fieldError {
+		return append(errs, fieldError{Field: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if len(s.Enum) > 0 && !inEnum(s.Enum, v) {
+		return fail("must be one of %v", s.Enum)
+	}
+
+	switch s.Type {
+	case "object":
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return fail("must be an object")
+		}
+
+		for _, key := range s.Required {
+			if _, ok := obj[key]; !ok {
+				errs = append(errs, fieldError{Field: joinField(path, key), Message: "is required"})
+			}
+		}
+
+		keys := make([]string, 0, len(obj))
+		for key := range obj {
+			keys = append(keys, key)
+		}
+
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			if prop, ok := s.Properties[key]; ok {
+				errs = append(errs, prop.validate(joinField(path, key), obj[key])...)
+				continue
+			}
+
+			switch additional := s.AdditionalProperties.(type) {
+			case bool:
+				if !additional {
+					errs = append(errs, fieldError{Field: joinField(path, key), Message: "is not allowed"})
+				}
+			case *jsonSchema:
+				errs = append(errs, additional.validate(joinField(path, key), obj[key])...)
+			}
+		}
+
+		return errs
+	case "array":
+		arr, ok := v.([]interface{})
+		if !ok {
+			return fail("must be an array")
+		}
+
+		if s.MaxItems != nil && len(arr) > *s.MaxItems {
+			return fail("must have at most %d items", *s.MaxItems)
+		}
+
+		for i, item := range arr {
+			errs = append(errs, s.Items.validate(path+"["+strconv.Itoa(i)+"]", item)...)
+		}
+
+		return errs
+	case "string":
+		str, ok := v.(string)
+		if !ok {
+			return fail("must be a string")
+		}
+
+		if s.MinLength != nil && len(str) < *s.MinLength {
+			return fail("must be at least %d characters", *s.MinLength)
+		}
+
+		if s.MaxLength != nil && len(str) > *s.MaxLength {
+			return fail("must be at most %d characters", *s.MaxLength)
+		}
+
+		if s.Pattern != "" {
+			re, err := compilePattern(s.Pattern)
+			if err != nil || !re.MatchString(str) {
+				return fail("must match %s", s.Pattern)
+			}
+		}
+
+		if s.Format == "date-time" {
+			if _, err := time.Parse(time.RFC3339, str); err != nil {
+				return fail("must be an RFC3339 date-time")
+			}
+		}
+
+		return errs
+	case "integer", "number":
+		typeName := "a number"
+		if s.Type == "integer" {
+			typeName = "an integer"
+		}
+
+		var num float64
+
+		switch n := v.(type) {
+		case float64:
+			num = n
+		case string:
+			parsed, err := strconv.ParseFloat(n, 64)
+			if err != nil {
+				return fail("must be %s", typeName)
+			}
+
+			num = parsed
+		default:
+			return fail("must be %s", typeName)
+		}
+
+		if s.Type == "integer" && num != float64(int64(num)) {
+			return fail("must be an integer")
+		}
+
+		if s.Minimum != nil && num < *s.Minimum {
+			return fail("must be at least %v", *s.Minimum)
+		}
+
+		if s.Maximum != nil && num > *s.Maximum {
+			return fail("must be at most %v", *s.Maximum)
+		}
+
+		return errs
+	case "boolean":
+		switch b := v.(type) {
+		case bool:
+		case string:
+			if _, err := strconv.ParseBool(b); err != nil {
+				return fail("must be a boolean")
+			}
+		default:
+			return fail("must be a boolean")
+		}
+
+		return errs
+	}
+
+	return errs
+}
@@ -0,0 +1,123 @@
+/**
+ * This work is licensed under Apache License, Version 2.0 or later.
+ * Please read and understand latest version of Licence.
+ */
+package webserver
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestJSONSchemaValidate(t *testing.T) {
+	member := objectSchema("Member", map[string]*jsonSchema{
+		"name": kubeNameSchema("Name"),
+		"tags": arraySchema("Tags", stringSchema("Tag")),
+	}, "name")
+
+	schema := objectSchema("Request", map[string]*jsonSchema{
+		"namespace": kubeNamespaceSchema("Namespace"),
+		"name":      kubeNameSchema("Name"),
+		"data":      stringMapSchema("Data"),
+		"limit":     {Type: "integer", Minimum: floatPtr(1), Maximum: floatPtr(10)},
+		"ratio":     {Type: "number"},
+		"force":     {Type: "boolean"},
+		"outcome":   {Type: "string", Enum: []interface{}{"success", "failure"}},
+		"since":     {Type: "string", Format: "date-time"},
+		"members":   {Type: "array", Items: member, MaxItems: intPtr(2)},
+		"matrix":    arraySchema("Matrix", arraySchema("Row", &jsonSchema{Type: "integer"})),
+	}, "name")
+
+	tests := []struct {
+		name    string
+		payload string
+		want    []fieldError
+	}{
+		{name: "valid", payload: `{"name":"db.example","namespace":"team-a","data":{"k":"v"},"limit":3,"ratio":0.5,"force":true,"outcome":"success","since":"2024-01-02T03:04:05Z","members":[{"name":"a","tags":["x"]}],"matrix":[[1,2],[3]]}`},
+		{name: "form strings", payload: `{"name":"a","limit":"3","ratio":"0.5","force":"false"}`},
+		{name: "missing required", payload: `{}`, want: []fieldError{{Field: "name", Message: "is required"}}},
+		{name: "not an object", payload: `"name"`, want: []fieldError{{Field: "", Message: "must be an object"}}},
+		{name: "additional property", payload: `{"name":"a","extra":1}`, want: []fieldError{{Field: "extra", Message: "is not allowed"}}},
+		{name: "string map value", payload: `{"name":"a","data":{"k":1}}`, want: []fieldError{{Field: "data.k", Message: "must be a string"}}},
+		{name: "string type", payload: `{"name":5}`, want: []fieldError{{Field: "name", Message: "must be a string"}}},
+		{name: "integer fraction", payload: `{"name":"a","limit":1.5}`, want: []fieldError{{Field: "limit", Message: "must be an integer"}}},
+		{name: "integer string", payload: `{"name":"a","limit":"many"}`, want: []fieldError{{Field: "limit", Message: "must be an integer"}}},
+		{name: "integer range", payload: `{"name":"a","limit":11}`, want: []fieldError{{Field: "limit", Message: "must be at most 10"}}},
+		{name: "number type", payload: `{"name":"a","ratio":[]}`, want: []fieldError{{Field: "ratio", Message: "must be a number"}}},
+		{name: "boolean type", payload: `{"name":"a","force":"yes please"}`, want: []fieldError{{Field: "force", Message: "must be a boolean"}}},
+		{name: "enum", payload: `{"name":"a","outcome":"denied"}`, want: []fieldError{{Field: "outcome", Message: "must be one of [success failure]"}}},
+		{name: "date-time", payload: `{"name":"a","since":"yesterday"}`, want: []fieldError{{Field: "since", Message: "must be an RFC3339 date-time"}}},
+		{name: "array type", payload: `{"name":"a","members":{}}`, want: []fieldError{{Field: "members", Message: "must be an array"}}},
+		{name: "max items", payload: `{"name":"a","members":[{"name":"a"},{"name":"b"},{"name":"c"}]}`, want: []fieldError{{Field: "members", Message: "must have at most 2 items"}}},
+		{
+			name:    "nested array items",
+			payload: `{"name":"a","members":[{"name":"a","tags":["x",1]},{"tags":[]}],"matrix":[[1],[2,"x"]]}`,
+			want: []fieldError{
+				{Field: "matrix[1][1]", Message: "must be an integer"},
+				{Field: "members[0].tags[1]", Message: "must be a string"},
+				{Field: "members[1].name", Message: "is required"},
+			},
+		},
+		{
+			name:    "all errors reported",
+			payload: `{"namespace":"Team","limit":0,"extra":true}`,
+			want: []fieldError{
+				{Field: "name", Message: "is required"},
+				{Field: "extra", Message: "is not allowed"},
+				{Field: "limit", Message: "must be at least 1"},
+				{Field: "namespace", Message: "must match ^[a-z0-9]([-a-z0-9]*[a-z0-9])?$"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var payload interface{}
+			if err := json.Unmarshal([]byte(tt.payload), &payload); err != nil {
+				t.Fatal(err)
+			}
+
+			got := schema.validate("", payload)
+
+			if len(got) == 0 && len(tt.want) == 0 {
+				return
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("errors = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKubeNameSchemas(t *testing.T) {
+	tests := []struct {
+		value     string
+		name      bool
+		namespace bool
+	}{
+		{value: "default", name: true, namespace: true},
+		{value: "team-a1", name: true, namespace: true},
+		{value: "db.example.org", name: true, namespace: false},
+		{value: strings.Repeat("a", 63), name: true, namespace: true},
+		{value: strings.Repeat("a", 64), name: true, namespace: false},
+		{value: strings.Repeat("a", 254), name: false, namespace: false},
+		{value: "", name: false, namespace: false},
+		{value: "-team", name: false, namespace: false},
+		{value: "team-", name: false, namespace: false},
+		{value: "Team", name: false, namespace: false},
+		{value: "team_a", name: false, namespace: false},
+	}
+
+	for _, tt := range tests {
+		if got := len(kubeNameSchema("").validate("name", tt.value)) == 0; got != tt.name {
+			t.Errorf("kubeNameSchema(%q) valid = %v, want %v", tt.value, got, tt.name)
+		}
+
+		if got := len(kubeNamespaceSchema("").validate("namespace", tt.value)) == 0; got != tt.namespace {
+			t.Errorf("kubeNamespaceSchema(%q) valid = %v, want %v", tt.value, got, tt.namespace)
+		}
+	}
+}
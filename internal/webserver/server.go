@@ -6,15 +6,20 @@ package webserver
 
 import (
 	"compress/gzip"
+	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net"
 	"net/http"
 	"os"
+	"sync"
 	"time"
 
 	"github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
+	"github.com/kazimsarikaya/assesmentbarkinrl/internal/audit"
 	"github.com/kazimsarikaya/assesmentbarkinrl/internal/config"
 	"github.com/kazimsarikaya/assesmentbarkinrl/internal/logger"
 	"github.com/kazimsarikaya/assesmentbarkinrl/internal/static"
@@ -22,10 +27,27 @@ import (
 	"golang.org/x/net/http2/h2c"
 )
 
-func StartWebServer() (*http.Server, error) {
+// StartWebServer serves until ShutdownWebServer is called. ctx is cancelled
+// when the process stops; it ends event streams and informers right away while
+// other requests run until the drain deadline.
+func StartWebServer(ctx context.Context) (*http.Server, error) {
 	var listener net.Listener
 	var err error
 
+	cfg := config.GetConfig()
+
+	err = audit.Setup(cfg.GetAuditSink(), cfg.GetAuditFile(), cfg.GetAuditMaxSize(), cfg.GetAuditMaxBackups(), cfg.GetAuditStoreSize())
+	if err != nil {
+		slog.Error("Error setting up audit log", "error", err)
+		return nil, err
+	}
+
+	err = setupIdempotency()
+	if err != nil {
+		slog.Error("Error setting up idempotency store", "error", err)
+		return nil, err
+	}
+
 	listener, err = net.Listen("tcp", fmt.Sprintf(":%d", config.GetConfig().GetServerPort()))
 
 	if err != nil {
@@ -50,22 +72,45 @@ func StartWebServer() (*http.Server, error) {
 
 	apiRouter.HandleFunc("", ApiHandler).Methods(http.MethodGet, http.MethodPost)
 
+	apiRouter.HandleFunc("/events", EventsHandler).Methods(http.MethodGet)
+
+	registerRestRoutes(apiRouter)
+
+	apiRouter.HandleFunc("/openapi.json", OpenAPIHandler).Methods(http.MethodGet)
+	apiRouter.HandleFunc("/schemas/{action}", ActionSchemaHandler).Methods(http.MethodGet)
+
 	apiRouter.Use(func(next http.Handler) http.Handler {
 		return handlers.CompressHandlerLevel(next, gzip.BestCompression)
 	})
 
+	// Probes, also served on the metrics port
+	AddOIDCHealthCheck()
+
+	for path, handler := range HealthChecker().Routes() {
+		r.Handle(path, handler).Methods(http.MethodGet)
+	}
+
 	// Static files
 	r.PathPrefix("/").HandlerFunc(SPAHandler)
 
 	// 404 middleware with logging using combined logger
-	r.NotFoundHandler = handlers.CustomLoggingHandler(
+	r.NotFoundHandler = logger.RequestContextHandler(logger.HttpLoggingHandler(
 		os.Stdout,
-		http.HandlerFunc(NotFoundHandler),
-		logger.HttpLogFormater)
+		http.HandlerFunc(NotFoundHandler)))
+
+	// Request id and trace context middleware, before logging so the access
+	// log carries them
+	r.Use(logger.RequestContextHandler)
+
+	// Tracing middleware, the server span of the request
+	r.Use(tracingHandler)
+
+	// Metrics middleware, request count and latency per route
+	r.Use(metricsHandler)
 
 	// Logging middleware
 	r.Use(func(next http.Handler) http.Handler {
-		return handlers.CustomLoggingHandler(os.Stdout, next, logger.HttpLogFormater)
+		return logger.HttpLoggingHandler(os.Stdout, next)
 	})
 
 	// Recover middleware
@@ -76,27 +121,120 @@ func StartWebServer() (*http.Server, error) {
 		)(next)
 	})
 
-	// Proxy headers middleware
-	r.Use(handlers.ProxyHeaders)
+	// Proxy headers middleware, only for the trusted proxies
+	r.Use(trustedProxyHeaders)
 
 	h2s := &http2.Server{}
-	h2cr := h2c.NewHandler(r, h2s)
+
+	// plaintext HTTP/2 (h2c) unless TLS is configured
+	var handler http.Handler = h2c.NewHandler(r, h2s)
+
+	var reloader *certReloader
+
+	if cfg.GetTlsCertFile() != "" {
+		reloader, err = newCertReloader(cfg.GetTlsCertFile(), cfg.GetTlsKeyFile(), cfg.GetTlsClientCAFile())
+		if err != nil {
+			listener.Close()
+			return nil, err
+		}
+
+		if err = reloader.watch(ctx); err != nil {
+			listener.Close()
+			return nil, err
+		}
+
+		handler = r
+	}
+
+	lifecycleMu.Lock()
+	serving = reloader
+	lifecycleMu.Unlock()
+
+	registerServerJobs()
+
+	requestCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+
+	lifecycleMu.Lock()
+	serverCtx = ctx
+	cancelRequests = cancel
+	lifecycleMu.Unlock()
 
 	srv := &http.Server{
+		BaseContext:  func(net.Listener) context.Context { return requestCtx },
 		WriteTimeout: time.Second * 15,
 		ReadTimeout:  time.Second * 15,
 		IdleTimeout:  time.Second * 60,
-		Handler:      h2cr, // Pass our instance of gorilla/mux in.
+		Handler:      handler, // Pass our instance of gorilla/mux in.
 		ErrorLog:     logger.DefaultErrorLogger,
 	}
 
+	if reloader != nil {
+		srv.TLSConfig = reloader.tlsConfig()
+
+		if err = http2.ConfigureServer(srv, h2s); err != nil {
+			listener.Close()
+			return nil, err
+		}
+
+		if cfg.GetHttpRedirectPort() != 0 {
+			if err = serveRedirect(ctx, cfg.GetHttpRedirectPort(), cfg.GetServerPort()); err != nil {
+				listener.Close()
+				return nil, err
+			}
+		}
+
+		listener = tls.NewListener(listener, srv.TLSConfig)
+	}
+
 	go func() {
-		if err := srv.Serve(listener); err != nil {
+		if err := srv.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
 			slog.Error("Error starting server", "error", err)
 		}
 	}()
 
-	slog.Info("Web server started")
+	slog.Info("Web server started", "tls", reloader != nil)
 
 	return srv, nil
 }
+
+var (
+	lifecycleMu    sync.Mutex
+	serverCtx                         = context.Background()
+	cancelRequests context.CancelFunc = func() {}
+	serving        *certReloader
+)
+
+// serverContext returns the context of the running server, done once the
+// process stops.
+func serverContext() context.Context {
+	lifecycleMu.Lock()
+	defer lifecycleMu.Unlock()
+
+	return serverCtx
+}
+
+// servingCerts returns the certificates of the running server, nil when it
+// serves plaintext.
+func servingCerts() *certReloader {
+	lifecycleMu.Lock()
+	defer lifecycleMu.Unlock()
+
+	return serving
+}
+
+// ShutdownWebServer fails the readiness probe and waits for in-flight requests
+// until ctx is done, then cancels the remaining ones.
+func ShutdownWebServer(ctx context.Context, srv *http.Server) error {
+	HealthChecker().Drain()
+
+	err := srv.Shutdown(ctx)
+	if err != nil {
+		lifecycleMu.Lock()
+		cancelRequests()
+		lifecycleMu.Unlock()
+
+		return errors.Join(err, srv.Close())
+	}
+
+	return nil
+}
@@ -0,0 +1,230 @@
+/**
+ * This work is licensed under Apache License, Version 2.0 or later.
+ * Please read and understand latest version of Licence.
+ */
+package webserver
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// certReloadDelay groups the file events of one certificate rotation.
+const certReloadDelay = time.Second
+
+// certReloader serves the certificate and client CAs from files and reloads
+// them when they change, like on a cert-manager rotation. A failed reload
+// keeps the previous certificate.
+type certReloader struct {
+	certFile string
+	keyFile  string
+	caFile   string
+
+	mu        sync.RWMutex
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+}
+
+func newCertReloader(certFile, keyFile, caFile string) (*certReloader, error) {
+	if keyFile == "" {
+		return nil, errors.New("TLS key file not set")
+	}
+
+	c := &certReloader{certFile: certFile, keyFile: keyFile, caFile: caFile}
+
+	if err := c.load(); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func (c *certReloader) load() error {
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+
+	var clientCAs *x509.CertPool
+
+	if c.caFile != "" {
+		pem, err := os.ReadFile(c.caFile)
+		if err != nil {
+			return fmt.Errorf("failed to read client CA file: %w", err)
+		}
+
+		clientCAs = x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(pem) {
+			return errors.New("no certificates found in client CA file")
+		}
+	}
+
+	c.mu.Lock()
+	c.cert = &cert
+	c.clientCAs = clientCAs
+	c.mu.Unlock()
+
+	if cert.Leaf != nil {
+		slog.Info("TLS certificate loaded", "subject", cert.Leaf.Subject.String(), "not_after", cert.Leaf.NotAfter)
+	}
+
+	return nil
+}
+
+// notAfter returns the expiry of the current certificate.
+func (c *certReloader) notAfter() (time.Time, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.cert.Leaf != nil {
+		return c.cert.Leaf.NotAfter, nil
+	}
+
+	cert, err := x509.ParseCertificate(c.cert.Certificate[0])
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return cert.NotAfter, nil
+}
+
+// tlsConfig returns a config that always hands out the latest certificate.
+// HTTP/2 is negotiated with ALPN.
+func (c *certReloader) tlsConfig() *tls.Config {
+	base := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		NextProtos: []string{"h2", "http/1.1"},
+	}
+
+	cfg := base.Clone()
+	cfg.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		c.mu.RLock()
+		defer c.mu.RUnlock()
+
+		clientCfg := base.Clone()
+		clientCfg.Certificates = []tls.Certificate{*c.cert}
+
+		if c.clientCAs != nil {
+			clientCfg.ClientCAs = c.clientCAs
+			clientCfg.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+
+		return clientCfg, nil
+	}
+
+	return cfg
+}
+
+// watch reloads the files until ctx is done. The directories are watched,
+// mounted secrets are replaced by swapping a symlink instead of writing the
+// files.
+func (c *certReloader) watch(ctx context.Context) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+
+	dirs := map[string]bool{}
+
+	for _, file := range []string{c.certFile, c.keyFile, c.caFile} {
+		if file != "" {
+			dirs[filepath.Dir(file)] = true
+		}
+	}
+
+	for dir := range dirs {
+		if err := watcher.Add(dir); err != nil {
+			watcher.Close()
+			return fmt.Errorf("failed to watch %s: %w", dir, err)
+		}
+	}
+
+	go func() {
+		defer watcher.Close()
+
+		var reload <-chan time.Time
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+
+				slog.Debug("TLS file event", "name", event.Name, "op", event.Op.String())
+
+				reload = time.After(certReloadDelay)
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+
+				slog.Error("Error watching TLS files", "error", err)
+			case <-reload:
+				if err := c.load(); err != nil {
+					slog.Error("Error reloading TLS certificate, keeping the previous one", "error", err)
+				}
+			}
+		}
+	}()
+
+	return nil
+}
+
+// serveRedirect redirects plaintext requests on port to the HTTPS server
+// until ctx is done.
+func serveRedirect(ctx context.Context, port, httpsPort int) error {
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		return err
+	}
+
+	srv := &http.Server{
+		ReadHeaderTimeout: 10 * time.Second,
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			host := r.Host
+			if h, _, err := net.SplitHostPort(host); err == nil {
+				host = h
+			}
+
+			if httpsPort != 443 {
+				host = net.JoinHostPort(host, strconv.Itoa(httpsPort))
+			}
+
+			http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+		}),
+	}
+
+	go func() {
+		if err := srv.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("Error serving redirects", "error", err)
+		}
+	}()
+
+	go func() {
+		<-ctx.Done()
+
+		if err := srv.Close(); err != nil {
+			slog.Error("Error closing redirect server", "error", err)
+		}
+	}()
+
+	slog.Info("HTTPS redirect server started", "port", port)
+
+	return nil
+}
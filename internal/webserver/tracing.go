@@ -0,0 +1,83 @@
+/**
+ * This work is licensed under Apache License, Version 2.0 or later.
+ * Please read and understand latest version of Licence.
+ */
+package webserver
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/kazimsarikaya/assesmentbarkinrl/internal/logger"
+	"github.com/kazimsarikaya/assesmentbarkinrl/internal/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracingHandler starts the server span of a request. The request context
+// takes the ids of the span so logs and responses match the exported trace.
+func tracingHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !tracing.Enabled() {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		route := r.URL.Path
+		if current := mux.CurrentRoute(r); current != nil {
+			if tmpl, err := current.GetPathTemplate(); err == nil {
+				route = tmpl
+			}
+		}
+
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := tracing.StartServer(ctx, r.Method+" "+route,
+			attribute.String("http.request.method", r.Method),
+			attribute.String("http.route", route),
+			attribute.String("url.path", r.URL.Path),
+		)
+		defer span.End()
+
+		if rc := logger.RequestContextFromContext(ctx); rc != nil {
+			sc := span.SpanContext()
+
+			rc.TraceID = sc.TraceID().String()
+			rc.SpanID = sc.SpanID().String()
+			rc.TraceFlags = sc.TraceFlags().String()
+
+			w.Header().Set(logger.TraceParentHeader, rc.TraceParent())
+			span.SetAttributes(attribute.String("request_id", rc.RequestID))
+		}
+
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		next.ServeHTTP(recorder, r.WithContext(ctx))
+
+		span.SetAttributes(attribute.Int("http.response.status_code", recorder.status))
+
+		if recorder.status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, strconv.Itoa(recorder.status))
+		}
+	})
+}
+
+// startActionSpan starts the span of an API action.
+func startActionSpan(r *http.Request, action string) (*http.Request, trace.Span) {
+	ctx, span := tracing.Start(r.Context(), "api."+action, attribute.String("api.action", action))
+
+	return r.WithContext(ctx), span
+}
+
+// startKubeSpan starts the span of a Kubernetes call.
+func startKubeSpan(ctx context.Context, verb, resource, namespace, name string) (context.Context, trace.Span) {
+	return tracing.Start(ctx, "kube."+verb,
+		attribute.String("k8s.resource", resource),
+		attribute.String("k8s.namespace.name", namespace),
+		attribute.String("k8s.object.name", name),
+	)
+}
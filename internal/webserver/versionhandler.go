@@ -18,14 +18,14 @@ func getVersion(w http.ResponseWriter, r *http.Request, data map[string]interfac
 		json, err := json.Marshal(map[string]interface{}{"version": config.GetConfig().GetVersion(), "build_time": config.GetConfig().GetBuildTime(), "go_version": config.GetConfig().GetGoVersion()})
 
 		if err != nil {
-			sendError(w, err.Error(), http.StatusInternalServerError)
+			sendError(w, r, errInternal(err.Error()))
 			return
 		}
 
 		_, err = w.Write(json)
 
 		if err != nil {
-			slog.Error("Error writing response", "error", err)
+			slog.ErrorContext(r.Context(), "Error writing response", "error", err)
 
 		}
 	}
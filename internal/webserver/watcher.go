@@ -1,210 +1,688 @@
+/**
+ * This work is licensed under Apache License, Version 2.0 or later.
+ * Please read and understand latest version of Licence.
+ */
 package webserver
 
 import (
 	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"os"
-	"path/filepath"
+	"io"
+	"log/slog"
+	"slices"
 	"strings"
+	"sync"
 	"text/template"
 	"time"
 
+	"github.com/kazimsarikaya/assesmentbarkinrl/internal/config"
+	"github.com/kazimsarikaya/assesmentbarkinrl/internal/metrics"
+	"github.com/kazimsarikaya/assesmentbarkinrl/internal/scheduler"
+	"github.com/kazimsarikaya/assesmentbarkinrl/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
 	appsv1 "k8s.io/api/apps/v1"
 	autoscalingv1 "k8s.io/api/autoscaling/v1"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/yaml"
-	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/rest"
-	"k8s.io/client-go/tools/clientcmd"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
 )
 
 const (
 	annotationKey = "example.org/postgres-cluster"
-	templatePath  = "docs/templates/db.yaml"
-	outputPath    = "/tmp/generated-db.yaml"
+	// clusterOwnerAnnotation names the cluster ConfigMap of a StatefulSet
+	clusterOwnerAnnotation = "example.org/postgres-cluster-name"
+	// serviceAccountKey of a cluster ConfigMap overrides watcherServiceAccount
+	serviceAccountKey = "serviceAccount"
+
+	clusterQueueName = "clusters"
+)
+
+// Phases of the clusters managed by the watcher.
+const (
+	phaseProvisioning = "Provisioning"
+	phaseReady        = "Ready"
+	phaseFailed       = "Failed"
+	phaseTerminating  = "Terminating"
+)
+
+var clusterPhases = []string{phaseProvisioning, phaseReady, phaseFailed, phaseTerminating}
+
+// Teardown phases in order, used as checkpoints and metric labels.
+const (
+	teardownScaleDown = "scale_down"
+	teardownWaitPods  = "wait_pods"
+	teardownDelete    = "delete"
 )
 
-// Watches ConfigMaps and applies/removes resources based on their lifecycle events.
-func WatchConfigMaps() error {
-	var config *rest.Config
-	var err error
+var teardownPhases = []string{teardownScaleDown, teardownWaitPods, teardownDelete}
 
-	// Try in-cluster config first, if it fails use kubeconfig from local
-	config, err = rest.InClusterConfig()
+// clusterController reconciles cluster ConfigMaps taken from a workqueue.
+// Informer events only enqueue keys, so a cluster is never reconciled by two
+// workers at once and failures are retried with backoff.
+type clusterController struct {
+	clientset kubernetes.Interface
+	// listers are keyed by the watched namespace, metav1.NamespaceAll when
+	// all namespaces are watched
+	listers map[string]corelisters.ConfigMapLister
+	queue   workqueue.RateLimitingInterface
+
+	checkpoints *teardownCheckpoints
+
+	mu     sync.Mutex
+	phases map[string]string
+}
+
+// WatchConfigMaps watches ConfigMaps of the configured namespaces and
+// applies/removes resources based on their lifecycle events until ctx is done.
+// Queued and running reconciles are then given the wait duration to finish
+// before they are cancelled; interrupted teardowns resume from their
+// checkpoint on the next start.
+func WatchConfigMaps(ctx context.Context) error {
+	cfg := config.GetConfig()
+
+	clientset, err := getKubeClientset()
 	if err != nil {
-		kubeconfig := filepath.Join(os.Getenv("HOME"), ".kube", "config")
-		config, err = clientcmd.BuildConfigFromFlags("", kubeconfig)
+		return err
+	}
+
+	namespaces := cfg.GetWatcherNamespaces()
+	if len(namespaces) == 0 {
+		namespaces = []string{metav1.NamespaceAll}
+	}
+
+	c := &clusterController{
+		clientset: clientset,
+		listers:   map[string]corelisters.ConfigMapLister{},
+		queue: workqueue.NewRateLimitingQueueWithConfig(workqueue.DefaultControllerRateLimiter(),
+			workqueue.RateLimitingQueueConfig{Name: clusterQueueName}),
+		checkpoints: newTeardownCheckpoints(clientset, cfg.GetCheckpointNamespace(), cfg.GetCheckpointConfigMap()),
+		phases:      map[string]string{},
+	}
+	defer c.queue.ShutDown()
+
+	for _, namespace := range namespaces {
+		factory, err := newWatcherInformerFactory(namespace, cfg.GetWatcherResyncPeriod())
+		if err != nil {
+			return err
+		}
+
+		informer := factory.Core().V1().ConfigMaps()
+		c.listers[namespace] = informer.Lister()
+
+		// resyncs are enqueued too, they repair drift of the cluster resources
+		_, err = informer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+			AddFunc:    func(obj interface{}) { c.enqueue(ctx, obj) },
+			UpdateFunc: func(_, newObj interface{}) { c.enqueue(ctx, newObj) },
+			DeleteFunc: func(obj interface{}) { c.enqueue(ctx, obj) },
+		})
 		if err != nil {
-			return fmt.Errorf("failed to get kubeconfig: %w", err)
+			return fmt.Errorf("failed to add configmap handler: %w", err)
+		}
+
+		if err := syncInformers(ctx, factory); err != nil {
+			return err
 		}
 	}
 
-	clientset, err := kubernetes.NewForConfig(config)
+	scheduler.Default().Register(scheduler.Job{
+		Name:     "orphan_gc",
+		Interval: func() time.Duration { return config.GetConfig().GetOrphanGCInterval() },
+		Jitter:   func() float64 { return config.GetConfig().GetJobJitter() },
+		Run:      c.collectOrphans,
+	})
+
+	registerCredentialCheck()
+
+	interrupted, err := c.checkpoints.load(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to create clientset: %w", err)
+		return fmt.Errorf("failed to load teardown checkpoints: %w", err)
+	}
+
+	for _, key := range interrupted {
+		namespace, _, _ := cache.SplitMetaNamespaceKey(key)
+		if _, ok := c.configMaps(namespace); !ok {
+			continue
+		}
+
+		slog.InfoContext(ctx, "Resuming interrupted teardown", "key", key, "phase", c.checkpoints.phase(key))
+		c.queue.Add(key)
+	}
+
+	workers := cfg.GetWatcherWorkers()
+
+	slog.InfoContext(ctx, "Watching ConfigMaps", "namespaces", cfg.GetWatcherNamespaces(), "workers", workers)
+
+	// reconciles outlive ctx until the drain deadline
+	workCtx, cancelWork := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelWork()
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+			c.runWorker(workCtx)
+		}()
+	}
+
+	workerDone := make(chan struct{})
+
+	go func() {
+		wg.Wait()
+		close(workerDone)
+	}()
+
+	<-ctx.Done()
+
+	HealthChecker().Drain()
+
+	slog.InfoContext(ctx, "Draining watcher", "queued", c.queue.Len(), "wait", cfg.GetWait())
+
+	// retries are dropped from now on, the remaining items are processed
+	go c.queue.ShutDownWithDrain()
+
+	select {
+	case <-workerDone:
+	case <-time.After(cfg.GetWait()):
+		slog.WarnContext(ctx, "Watcher not drained in time, cancelling reconciles")
+		cancelWork()
+		<-workerDone
+	}
+
+	slog.InfoContext(ctx, "Watcher stopped")
+
+	return nil
+}
+
+// collectOrphans enqueues clusters whose StatefulSets outlived their
+// ConfigMap, like when a delete event was missed while no watcher ran. The
+// reconcile then tears them down.
+func (c *clusterController) collectOrphans(ctx context.Context) error {
+	var errs []error
+
+	for namespace := range c.listers {
+		statefulSets, err := c.clientset.AppsV1().StatefulSets(namespace).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		for _, sts := range statefulSets.Items {
+			owner, ok := sts.Annotations[clusterOwnerAnnotation]
+			if !ok {
+				continue
+			}
+
+			configMaps, _ := c.configMaps(sts.Namespace)
+
+			_, err := configMaps.Get(owner)
+			if !apierrors.IsNotFound(err) {
+				continue
+			}
+
+			key := sts.Namespace + "/" + owner
+
+			slog.InfoContext(ctx, "Tearing down orphaned cluster", "key", key, "statefulset", sts.Name)
+			c.queue.Add(key)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// configMaps returns the ConfigMap lister of namespace, false when the
+// namespace is not watched.
+func (c *clusterController) configMaps(namespace string) (corelisters.ConfigMapNamespaceLister, bool) {
+	if lister, ok := c.listers[metav1.NamespaceAll]; ok {
+		return lister.ConfigMaps(namespace), true
+	}
+
+	lister, ok := c.listers[namespace]
+	if !ok {
+		return nil, false
+	}
+
+	return lister.ConfigMaps(namespace), true
+}
+
+// enqueue adds the key of a cluster ConfigMap, deleted ones included, to the
+// queue.
+func (c *clusterController) enqueue(ctx context.Context, obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+
+	cm, ok := obj.(*corev1.ConfigMap)
+	if !ok || !isClusterConfigMap(cm) {
+		return
 	}
 
-	// List existing ConfigMaps at startup
-	cms, err := clientset.CoreV1().ConfigMaps("").List(context.TODO(), metav1.ListOptions{})
+	key, err := cache.MetaNamespaceKeyFunc(cm)
 	if err != nil {
-		fmt.Println("ConfigMap list error:", err)
+		slog.ErrorContext(ctx, "Error getting ConfigMap key", "error", err)
+		return
+	}
+
+	c.queue.Add(key)
+}
+
+func (c *clusterController) runWorker(ctx context.Context) {
+	for c.processNextItem(ctx) {
+	}
+}
+
+func (c *clusterController) processNextItem(ctx context.Context) bool {
+	item, shutdown := c.queue.Get()
+	if shutdown {
+		return false
+	}
+	defer c.queue.Done(item)
+
+	key := item.(string)
+
+	if err := c.reconcile(ctx, key); err != nil {
+		slog.ErrorContext(ctx, "Error reconciling cluster", "key", key, "retries", c.queue.NumRequeues(item), "error", err)
+		c.queue.AddRateLimited(item)
+
+		return true
+	}
+
+	c.queue.Forget(item)
+
+	return true
+}
+
+// setPhase records the phase of a cluster, an empty phase forgets it.
+func (c *clusterController) setPhase(key, phase string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if phase == "" {
+		delete(c.phases, key)
 	} else {
-		fmt.Println("Existing ConfigMaps:")
-		for _, cm := range cms.Items {
-			fmt.Println("-", cm.Name)
-		}
+		c.phases[key] = phase
+	}
+
+	counts := map[string]int{}
+	for _, p := range c.phases {
+		counts[p]++
 	}
 
-	// Start watching ConfigMaps
-	w, err := clientset.CoreV1().ConfigMaps("").Watch(context.TODO(), metav1.ListOptions{})
+	for _, p := range clusterPhases {
+		metrics.ManagedClusters.WithLabelValues(p).Set(float64(counts[p]))
+	}
+}
+
+// reconcile provisions an existing cluster ConfigMap and tears down the
+// resources of a deleted one.
+func (c *clusterController) reconcile(ctx context.Context, key string) error {
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
 	if err != nil {
-		return fmt.Errorf("failed to watch configmaps: %w", err)
+		return nil
 	}
-	fmt.Println("Watching ConfigMaps...")
 
-	for event := range w.ResultChan() {
-		cm, ok := event.Object.(*corev1.ConfigMap)
-		if !ok {
-			continue
+	configMaps, ok := c.configMaps(namespace)
+	if !ok {
+		return nil
+	}
+
+	cm, err := configMaps.Get(name)
+	if apierrors.IsNotFound(err) {
+		ctx, span := tracing.Start(ctx, "watcher.teardown",
+			attribute.String("k8s.namespace.name", namespace),
+			attribute.String("k8s.object.name", name),
+		)
+
+		c.setPhase(key, phaseTerminating)
+
+		var statefulSets []string
+
+		statefulSets, err = clusterStatefulSets(ctx, c.clientset, namespace, name)
+		if err == nil {
+			err = teardownCluster(ctx, c.clientset, namespace, name, statefulSets, c.checkpoints.phase(key), func(phase string) {
+				c.checkpoints.save(ctx, key, phase)
+			})
 		}
-		switch event.Type {
-		case watch.Added, watch.Modified:
-			// If the ConfigMap has the required annotation, process it
-			if val, ok := cm.GetAnnotations()[annotationKey]; ok && val == "true" {
-				// Load the template from a specific ConfigMap
-				cfgMap, err := clientset.CoreV1().ConfigMaps("template-namespace").Get(context.TODO(), "db-template", metav1.GetOptions{})
-				if err != nil {
-					fmt.Println("Template ConfigMap get error:", err)
-					continue
-				}
-				templateStr, ok := cfgMap.Data["db.yaml"]
-				if !ok {
-					fmt.Println("Template not found in ConfigMap")
-					continue
-				}
-				// Parse the template
-				tpl, err := template.New("resource").Parse(templateStr)
-				if err != nil {
-					fmt.Println("Template parse error:", err)
-					continue
-				}
-				data := map[string]interface{}{
-					"CLUSTERNAME": "mycluster",
-					"NAMESPACE":   "default",
-					"SANAME":      "my-service-account",
-				}
-				var buf bytes.Buffer
-				if err := tpl.Execute(&buf, data); err != nil {
-					fmt.Println("Template execute error:", err)
-					continue
-				}
-				content := buf.String()
-				// Write the processed template to a file
-				if err := os.WriteFile(outputPath, []byte(content), 0644); err != nil {
-					fmt.Println("Write error:", err)
-					continue
+		tracing.End(span, err)
+
+		if err == nil {
+			c.checkpoints.save(ctx, key, "")
+			c.setPhase(key, "")
+		}
+
+		return err
+	}
+
+	if err != nil {
+		return err
+	}
+
+	// the cluster was recreated before its teardown finished
+	if c.checkpoints.phase(key) != "" {
+		c.checkpoints.save(ctx, key, "")
+	}
+
+	// the annotation was removed, the cluster is no longer managed
+	if !isClusterConfigMap(cm) {
+		c.setPhase(key, "")
+		return nil
+	}
+
+	ctx, span := tracing.Start(ctx, "watcher.reconcile",
+		attribute.String("k8s.namespace.name", namespace),
+		attribute.String("k8s.object.name", name),
+	)
+
+	c.setPhase(key, phaseProvisioning)
+
+	err = reconcileCluster(ctx, c.clientset, cm)
+	tracing.End(span, err)
+
+	if err != nil {
+		c.setPhase(key, phaseFailed)
+		return err
+	}
+
+	c.setPhase(key, phaseReady)
+
+	return nil
+}
+
+// renderTemplate loads the cluster template from the configured ConfigMap and
+// renders it for the cluster ConfigMap cm.
+func renderTemplate(ctx context.Context, clientset kubernetes.Interface, cm *corev1.ConfigMap) (content string, err error) {
+	ctx, span := tracing.Start(ctx, "watcher.render_template")
+	defer func() { tracing.End(span, err) }()
+
+	cfg := config.GetConfig()
+
+	cfgMap, err := clientset.CoreV1().ConfigMaps(cfg.GetWatcherTemplateNamespace()).Get(ctx, cfg.GetWatcherTemplateConfigMap(), metav1.GetOptions{})
+	if err != nil {
+		return "", fmt.Errorf("failed to get template ConfigMap: %w", err)
+	}
+
+	templateStr, ok := cfgMap.Data[cfg.GetWatcherTemplateKey()]
+	if !ok {
+		return "", fmt.Errorf("template %s not found in ConfigMap", cfg.GetWatcherTemplateKey())
+	}
+
+	// Parse the template
+	tpl, err := template.New("resource").Parse(templateStr)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse template: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := tpl.Execute(&buf, templateData(cm)); err != nil {
+		return "", fmt.Errorf("failed to execute template: %w", err)
+	}
+
+	return buf.String(), nil
+}
+
+// templateData returns the values the cluster template is rendered with.
+func templateData(cm *corev1.ConfigMap) map[string]interface{} {
+	serviceAccount := cm.Data[serviceAccountKey]
+	if serviceAccount == "" {
+		serviceAccount = config.GetConfig().GetWatcherServiceAccount()
+	}
+
+	return map[string]interface{}{
+		"CLUSTERNAME": cm.Name,
+		"NAMESPACE":   cm.Namespace,
+		"SANAME":      serviceAccount,
+	}
+}
+
+// reconcileCluster renders the template and applies its StatefulSets. Other
+// documents of the template, like Services or Secrets, are skipped.
+func reconcileCluster(ctx context.Context, clientset kubernetes.Interface, cm *corev1.ConfigMap) error {
+	var content string
+
+	err := metrics.ObservePhase("render", func() error {
+		var err error
+		content, err = renderTemplate(ctx, clientset, cm)
+
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	return metrics.ObservePhase("apply", func() error {
+		var errs []error
+
+		// Parse YAML to resource object(s)
+		decoder := yaml.NewYAMLOrJSONDecoder(strings.NewReader(content), 4096)
+
+		for {
+			var doc json.RawMessage
+			if err := decoder.Decode(&doc); err != nil {
+				if errors.Is(err, io.EOF) {
+					break
 				}
-				// Parse YAML to resource object(s)
-				decoder := yaml.NewYAMLOrJSONDecoder(strings.NewReader(content), 4096)
-				for {
-					var statefulSet appsv1.StatefulSet
-					if err := decoder.Decode(&statefulSet); err != nil {
-						if err.Error() == "EOF" {
-							break
-						}
-						fmt.Println("YAML decode error:", err)
-						continue
-					}
-					// Apply resource: check if it exists, create if not, update if exists
-					_, err := clientset.AppsV1().StatefulSets(cm.Namespace).Get(context.TODO(), statefulSet.Name, metav1.GetOptions{})
-					if err != nil {
-						// Create if not exists
-						_, err = clientset.AppsV1().StatefulSets(cm.Namespace).Create(context.TODO(), &statefulSet, metav1.CreateOptions{})
-						if err != nil {
-							fmt.Println("StatefulSet create error:", err)
-							continue
-						}
-						fmt.Println("StatefulSet created:", statefulSet.Name)
-					} else {
-						// Update if exists
-						_, err = clientset.AppsV1().StatefulSets(cm.Namespace).Update(context.TODO(), &statefulSet, metav1.UpdateOptions{})
-						if err != nil {
-							fmt.Println("StatefulSet update error:", err)
-							continue
-						}
-						fmt.Println("StatefulSet updated:", statefulSet.Name)
-					}
+
+				return fmt.Errorf("failed to decode template: %w", err)
+			}
+
+			var typeMeta metav1.TypeMeta
+			if err := json.Unmarshal(doc, &typeMeta); err != nil {
+				return fmt.Errorf("failed to decode template: %w", err)
+			}
+
+			if typeMeta.Kind != "StatefulSet" {
+				if typeMeta.Kind != "" {
+					slog.DebugContext(ctx, "Skipping template document", "kind", typeMeta.Kind)
 				}
+
+				continue
+			}
+
+			var statefulSet appsv1.StatefulSet
+			if err := json.Unmarshal(doc, &statefulSet); err != nil {
+				return fmt.Errorf("failed to decode StatefulSet: %w", err)
+			}
+
+			// lets the orphan collector find StatefulSets of deleted clusters
+			if statefulSet.Annotations == nil {
+				statefulSet.Annotations = map[string]string{}
 			}
-		case watch.Deleted:
-			// When a ConfigMap is deleted, remove related resources
-			name := cm.Name
-			namespace := cm.Namespace
-			fmt.Printf("ConfigMap deleted: %s/%s\n", namespace, name)
-
-			// Scale down the StatefulSet to 0 replicas before deleting
-			_, err := clientset.AppsV1().StatefulSets(namespace).UpdateScale(context.TODO(), name, &autoscalingv1.Scale{
-				ObjectMeta: metav1.ObjectMeta{
-					Name:      name,
-					Namespace: namespace,
-				},
-				Spec: autoscalingv1.ScaleSpec{
-					Replicas: 0,
-				},
-			}, metav1.UpdateOptions{})
-			if err != nil {
-				fmt.Println("StatefulSet scale error:", err)
+
+			statefulSet.Annotations[clusterOwnerAnnotation] = cm.Name
+
+			if err := applyStatefulSet(ctx, clientset, cm.Namespace, &statefulSet); err != nil {
+				errs = append(errs, err)
 			}
+		}
+
+		return errors.Join(errs...)
+	})
+}
+
+// applyStatefulSet creates the StatefulSet if it does not exist, updates it
+// otherwise.
+func applyStatefulSet(ctx context.Context, clientset kubernetes.Interface, namespace string, statefulSet *appsv1.StatefulSet) (err error) {
+	ctx, span := startKubeSpan(ctx, "apply", "statefulsets", namespace, statefulSet.Name)
+	defer func() { tracing.End(span, err) }()
 
-			// Wait until all pods are terminated
-			waitForPodsTerminated := func() {
-				for {
-					podList, err := clientset.CoreV1().Pods(namespace).List(context.TODO(), metav1.ListOptions{
-						LabelSelector: fmt.Sprintf("statefulset.kubernetes.io/pod-name in (%s-0)", name),
-					})
-					if err != nil {
-						fmt.Println("Pod list error:", err)
-						break
-					}
-					if len(podList.Items) == 0 {
-						fmt.Println("All pods terminated.")
-						break
-					}
-					fmt.Printf("Waiting for pods to terminate... (%d remaining)\n", len(podList.Items))
-					// wait for 2 seconds before checking again
-					time.Sleep(2 * time.Second)
+	statefulSets := clientset.AppsV1().StatefulSets(namespace)
+
+	current, err := statefulSets.Get(ctx, statefulSet.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		if _, err = statefulSets.Create(ctx, statefulSet, metav1.CreateOptions{}); err != nil {
+			return fmt.Errorf("failed to create StatefulSet %s: %w", statefulSet.Name, err)
+		}
+
+		slog.InfoContext(ctx, "StatefulSet created", "namespace", namespace, "name", statefulSet.Name)
+
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to get StatefulSet %s: %w", statefulSet.Name, err)
+	}
+
+	statefulSet.ResourceVersion = current.ResourceVersion
+
+	if _, err = statefulSets.Update(ctx, statefulSet, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("failed to update StatefulSet %s: %w", statefulSet.Name, err)
+	}
+
+	slog.InfoContext(ctx, "StatefulSet updated", "namespace", namespace, "name", statefulSet.Name)
+
+	return nil
+}
+
+// clusterStatefulSets returns the names of the StatefulSets of the cluster
+// ConfigMap name, found by their owner annotation. Clusters without annotated
+// StatefulSets were provisioned before the annotation existed, their
+// StatefulSet is named like the ConfigMap.
+func clusterStatefulSets(ctx context.Context, clientset kubernetes.Interface, namespace, name string) ([]string, error) {
+	statefulSets, err := clientset.AppsV1().StatefulSets(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list StatefulSets: %w", err)
+	}
+
+	var names []string
+
+	for _, sts := range statefulSets.Items {
+		if sts.Annotations[clusterOwnerAnnotation] == name {
+			names = append(names, sts.Name)
+		}
+	}
+
+	if len(names) == 0 {
+		names = []string{name}
+	}
+
+	return names, nil
+}
+
+// teardownCluster scales the StatefulSets of a deleted cluster down, waits for
+// their pods and deletes the StatefulSets and the Services and PVCs named like
+// them. Phases before resume are skipped, checkpoint is called when a phase is
+// entered.
+func teardownCluster(ctx context.Context, clientset kubernetes.Interface, namespace, name string, statefulSets []string, resume string, checkpoint func(phase string)) error {
+	slog.InfoContext(ctx, "ConfigMap deleted, tearing down cluster", "namespace", namespace, "name", name, "statefulsets", statefulSets, "resume", resume)
+
+	done := func(phase string) bool {
+		return slices.Index(teardownPhases, phase) < slices.Index(teardownPhases, resume)
+	}
+
+	// Scale down the StatefulSets to 0 replicas before deleting
+	if !done(teardownScaleDown) {
+		checkpoint(teardownScaleDown)
+
+		_ = metrics.ObservePhase(teardownScaleDown, func() (err error) {
+			ctx, span := tracing.Start(ctx, "teardown.scale_down")
+			defer func() { tracing.End(span, err) }()
+
+			var errs []error
+
+			for _, sts := range statefulSets {
+				_, err := clientset.AppsV1().StatefulSets(namespace).UpdateScale(ctx, sts, &autoscalingv1.Scale{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      sts,
+						Namespace: namespace,
+					},
+					Spec: autoscalingv1.ScaleSpec{
+						Replicas: 0,
+					},
+				}, metav1.UpdateOptions{})
+				if err != nil && !apierrors.IsNotFound(err) {
+					slog.WarnContext(ctx, "Error scaling StatefulSet down", "namespace", namespace, "name", sts, "error", err)
+					errs = append(errs, err)
 				}
 			}
-			waitForPodsTerminated()
-
-			// Delete StatefulSet, Service, and PVC with the same name, retry if error
-			maxRetries := 3
-			deleteWithRetry := func(delFunc func() error, desc string) {
-				for i := 0; i < maxRetries; i++ {
-					if err := delFunc(); err != nil {
-						fmt.Printf("%s delete error (try %d/%d): %v\n", desc, i+1, maxRetries, err)
-						if i == maxRetries-1 {
-							fmt.Printf("%s delete failed after retries, not correctable.\n", desc)
-						}
-					} else {
-						fmt.Printf("%s deleted successfully.\n", desc)
-						break
-					}
+
+			return errors.Join(errs...)
+		})
+	}
+
+	// Wait until all pods are terminated
+	if !done(teardownWaitPods) {
+		checkpoint(teardownWaitPods)
+
+		err := metrics.ObservePhase(teardownWaitPods, func() (err error) {
+			ctx, span := tracing.Start(ctx, "teardown.wait_pods")
+			defer func() { tracing.End(span, err) }()
+
+			for {
+				pods := make([]string, 0, len(statefulSets))
+				for _, sts := range statefulSets {
+					pods = append(pods, sts+"-0")
+				}
+
+				podList, err := clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{
+					LabelSelector: fmt.Sprintf("statefulset.kubernetes.io/pod-name in (%s)", strings.Join(pods, ",")),
+				})
+				if err != nil {
+					return fmt.Errorf("failed to list pods: %w", err)
+				}
+
+				if len(podList.Items) == 0 {
+					return nil
+				}
+
+				slog.InfoContext(ctx, "Waiting for pods to terminate", "namespace", namespace, "name", name, "remaining", len(podList.Items))
+
+				// wait for 2 seconds before checking again
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-time.After(2 * time.Second):
 				}
 			}
-			deleteWithRetry(func() error {
-				return clientset.AppsV1().StatefulSets(namespace).Delete(context.TODO(), name, metav1.DeleteOptions{})
-			}, "StatefulSet")
-			deleteWithRetry(func() error {
-				return clientset.CoreV1().Services(namespace).Delete(context.TODO(), name, metav1.DeleteOptions{})
-			}, "Service")
-			deleteWithRetry(func() error {
-				return clientset.CoreV1().PersistentVolumeClaims(namespace).Delete(context.TODO(), name, metav1.DeleteOptions{})
-			}, "PVC")
+		})
+		if err != nil {
+			return err
 		}
 	}
-	return nil
+
+	checkpoint(teardownDelete)
+
+	// Delete the StatefulSets and the Services and PVCs with the same names,
+	// missing ones are already gone
+	return metrics.ObservePhase(teardownDelete, func() error {
+		deleteResource := func(resource, name string, delFunc func(ctx context.Context) error) (err error) {
+			ctx, span := startKubeSpan(ctx, "delete", resource, namespace, name)
+			defer func() { tracing.End(span, err) }()
+
+			if err = delFunc(ctx); err != nil && !apierrors.IsNotFound(err) {
+				return fmt.Errorf("failed to delete %s %s: %w", resource, name, err)
+			}
+
+			slog.InfoContext(ctx, "Resource deleted", "resource", resource, "namespace", namespace, "name", name)
+
+			return nil
+		}
+
+		var errs []error
+
+		for _, sts := range statefulSets {
+			errs = append(errs,
+				deleteResource("statefulsets", sts, func(ctx context.Context) error {
+					return clientset.AppsV1().StatefulSets(namespace).Delete(ctx, sts, metav1.DeleteOptions{})
+				}),
+				deleteResource("services", sts, func(ctx context.Context) error {
+					return clientset.CoreV1().Services(namespace).Delete(ctx, sts, metav1.DeleteOptions{})
+				}),
+				deleteResource("persistentvolumeclaims", sts, func(ctx context.Context) error {
+					return clientset.CoreV1().PersistentVolumeClaims(namespace).Delete(ctx, sts, metav1.DeleteOptions{})
+				}),
+			)
+		}
+
+		return errors.Join(errs...)
+	})
 }
@@ -0,0 +1,41 @@
+/**
+ * This work is licensed under Apache License, Version 2.0 or later.
+ * Please read and understand latest version of Licence.
+ */
+package webserver
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestTemplateData(t *testing.T) {
+	setTestConfig(t, map[string]interface{}{"watcherServiceAccount": "postgres"})
+
+	tests := []struct {
+		name   string
+		data   map[string]string
+		wantSA string
+	}{
+		{name: "configured service account", data: nil, wantSA: "postgres"},
+		{name: "empty override", data: map[string]string{serviceAccountKey: ""}, wantSA: "postgres"},
+		{name: "cluster service account", data: map[string]string{serviceAccountKey: "team-db"}, wantSA: "team-db"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cm := &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "team", Name: "mycluster"},
+				Data:       tt.data,
+			}
+
+			data := templateData(cm)
+
+			if data["CLUSTERNAME"] != "mycluster" || data["NAMESPACE"] != "team" || data["SANAME"] != tt.wantSA {
+				t.Fatalf("templateData = %v, want SANAME %q", data, tt.wantSA)
+			}
+		})
+	}
+}